@@ -0,0 +1,65 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+var (
+	agentListen string
+	agentToken  string
+)
+
+func runAgentCommandFunc(cmd *cobra.Command, args []string) {
+	nodeCommandWork()
+	initialGlobalProps(func() {
+		if cmd.Flags().Changed("listen") {
+			globalProps.Set(prop.AgentListen, agentListen)
+		}
+		if cmd.Flags().Changed("token") {
+			globalProps.Set(prop.AgentToken, agentToken)
+		}
+	})
+
+	agent, err := nodectrl.NewAgent(globalProps)
+	if err != nil {
+		fmt.Printf("Error starting the agent [%v]\n", err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Agent listening on %v\n", agent.Addr())
+
+	<-globalContext.Done()
+	agent.Close()
+}
+
+func newAgentCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "agent",
+		Short: "Run the workloads of leaders as a follower",
+		Args:  cobra.NoArgs,
+		Run:   runAgentCommandFunc,
+	}
+
+	initNodeCommand(m)
+	m.Flags().StringVar(&agentListen, "listen", prop.AgentListenDefault, "Address to serve the leaders on - can also be specified as the \"agent.listen\" property")
+	m.Flags().StringVar(&agentToken, "token", "", "Secret the leaders must send - can also be specified as the \"agent.token\" property")
+	return m
+}
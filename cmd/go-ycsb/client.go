@@ -16,10 +16,13 @@ package main
 import (
 	"fmt"
 	"github.com/pingcap/go-ycsb/pkg/client"
+	"github.com/pingcap/go-ycsb/pkg/control"
 	"github.com/pingcap/go-ycsb/pkg/measurement"
 	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/workload"
 	"github.com/pingcap/go-ycsb/pkg/ycsbchecker"
+	"os"
 	"strconv"
 	"time"
 
@@ -39,10 +42,36 @@ func runNodeCommandFunc(cmd *cobra.Command, args []string, wt string) {
 func runCoreWorkloadCommandFunc() {
 	fmt.Println("***************** properties *****************")
 	for key, value := range globalProps.Map() {
+		if key == prop.ControlToken || key == prop.AgentToken {
+			value = "<hidden>"
+		}
 		fmt.Printf("\"%s\"=\"%s\"\n", key, value)
 	}
 	fmt.Println("**********************************************")
 
+	//Follower Setup
+	runCtx := globalContext
+	var leader *control.Leader
+	var follower *control.Follower
+	var runStart time.Time
+	if globalProps.GetString(prop.ControlLeader, "") != "" {
+		var err error
+		follower, runCtx, err = joinLeader()
+		if err != nil {
+			fmt.Printf("Error joining the leader [%v]\n", err.Error())
+			return
+		}
+	} else if followerSrc := globalProps.GetString(prop.FollowerList, ""); followerSrc != "" {
+		var err error
+		leader, runStart, err = startFollowers(followerSrc)
+		if err != nil {
+			fmt.Printf("Error starting followers [%v]\n", err.Error())
+		} else {
+			fmt.Printf("Starting the run at %v\n", runStart)
+			control.SleepUntil(globalContext, runStart)
+		}
+	}
+
 	var err error
 	if !nodectrl.NodesParsed() {
 		nodeSrc := globalProps.GetString(prop.Cluster, "")
@@ -51,7 +80,7 @@ func runCoreWorkloadCommandFunc() {
 	if err == nil {
 		eventSrc := globalProps.GetString(prop.Events, "")
 		if eventSrc != "" {
-			err = workload.StartEventWorkload(eventSrc)
+			err = workload.StartEventWorkload(runCtx, eventSrc, globalProps)
 			if err != nil {
 				fmt.Printf("Error creating workload events [%v]\n", err.Error())
 			}
@@ -60,42 +89,82 @@ func runCoreWorkloadCommandFunc() {
 		fmt.Printf("Error parsing node info [%v]\n", err.Error())
 	}
 
-	//Follower Setup
-	followerSrc := globalProps.GetString(prop.FollowerList, "")
-	if followerSrc != "" {
-		err = nodectrl.ParseFollowerList(followerSrc)
-		if err == nil {
-			nodectrl.StartFollowers(dbName, currentWork)
-		}
-	}
-
 	//Run the client
 	c := client.NewClient(globalProps, globalWorkload, globalDB)
 	start := time.Now()
-	c.Run(globalContext)
+	c.Run(runCtx)
 
 	fmt.Printf("Run finished, takes %s\n", time.Now().Sub(start))
-	measurementType, _ := globalProps.Get(prop.MeasurementType)
-	if measurementType == "raw" {
-		measurement.RawOutput()
-	} else {
+	workload.StopEventWorkload()
+	if err = workload.RecoverFaults(); err != nil {
+		fmt.Printf("Error recovering faults [%v]\n", err.Error())
+	}
+	workload.CloseActionLog()
+	nodectrl.CloseConnections()
+	if measurement.RawEnabled(globalProps) {
+		measurement.RawClose()
+	}
+	if measurement.HistogramEnabled(globalProps) {
 		measurement.Output()
 	}
+	if (leader != nil || follower != nil) && measurement.HistogramCollected(globalProps) {
+		if err = measurement.WriteRunSummary(globalProps); err != nil {
+			fmt.Printf("Error writing the histogram summary [%v]\n", err.Error())
+		}
+	}
+	measurement.CloseTimeline()
+
+	var report *measurement.ClusterReport
+	if follower != nil {
+		if err = follower.Done(runCtx.Err()); err != nil {
+			fmt.Printf("Error reporting the completion to the leader [%v]\n", err.Error())
+		}
+	}
+	if leader != nil {
+		report = finishFollowers(leader, runStart)
+	}
 
 	checkerType := globalProps.GetString(prop.Checker, "")
 	filePrefix := globalProps.GetString(prop.CSVFileName, "")
 	if checkerType != "" {
-		err = ycsbchecker.RunChecker(checkerType, filePrefix)
+		// the checker of a leader checks the history of the whole cluster
+		var files []measurement.RawFile
+		var err error
+		if report != nil {
+			files = report.RawFiles()
+		} else {
+			files, err = measurement.RawFiles(globalProps)
+		}
+		if err == nil {
+			err = ycsbchecker.RunChecker(checkerType, filePrefix, files)
+		}
+		if err != nil {
+			fmt.Printf("Error running the %v checker [%v]\n", checkerType, err.Error())
+		}
 	}
 
-	if nodectrl.FollowersStarted() {
-		nodectrl.GetFollowersFiles()
+	workload.ReportEvents(globalProps.GetString(prop.OutputStyle, util.OutputStylePlain))
+
+	if invalid := workload.InvalidRun(); len(invalid) > 0 {
+		invalidRuns++
+		fmt.Println("RUN INVALID, actions did not meet their expectation:")
+		for _, reason := range invalid {
+			fmt.Printf("  %v\n", reason)
+		}
+	}
+}
+
+// exitIfInvalid exits with status 1 once all the workloads ran if any of them is invalid
+func exitIfInvalid() {
+	if invalidRuns > 0 {
+		fmt.Printf("%v invalid runs\n", invalidRuns)
+		os.Exit(1)
 	}
 }
 
 func runClientCommandFunc(cmd *cobra.Command, args []string, doTransactions bool, command string) {
 	time.Sleep(30 * time.Second)
-	dbName := args[0]
+	dbName = args[0]
 
 	initialGlobal(dbName, func() {
 		doTransFlag := "true"
@@ -122,6 +191,8 @@ func runClientCommandFunc(cmd *cobra.Command, args []string, doTransactions bool
 		if cmd.Flags().Changed("interval") {
 			globalProps.Set(prop.LogInterval, strconv.Itoa(reportInterval))
 		}
+
+		partitionWorkload()
 	})
 
 	workType := globalProps.GetString(prop.Workload, "core")
@@ -129,8 +200,7 @@ func runClientCommandFunc(cmd *cobra.Command, args []string, doTransactions bool
 	switch workType {
 	case "core":
 		runCoreWorkloadCommandFunc()
-	case "startnodes":
-	case "stopnodes":
+	case "startnodes", "stopnodes":
 		runNodeCommandFunc(cmd, args, workType)
 	default:
 		fmt.Printf("[ERROR] Invalid workload property value: %v\n", workType)
@@ -149,6 +219,7 @@ func runLoadCommandFunc(cmd *cobra.Command, args []string) {
 		currentWork = ""
 		runClientCommandFunc(cmd, args, false, "load")
 	}
+	exitIfInvalid()
 }
 
 func runTransCommandFunc(cmd *cobra.Command, args []string) {
@@ -164,9 +235,19 @@ func runTransCommandFunc(cmd *cobra.Command, args []string) {
 		currentWork = ""
 		runClientCommandFunc(cmd, args, true, "run")
 	}
+	exitIfInvalid()
+}
+
+// nodeCommandWork selects the property file of the startnodes and stopnodes
+// commands, unless they run as the workload of a run command
+func nodeCommandWork() {
+	if currentWork == "" && len(propertyFiles) > 0 {
+		currentWork = propertyFiles[0]
+	}
 }
 
 func runStartNodesCommandFunc(cmd *cobra.Command, args []string) {
+	nodeCommandWork()
 	initialGlobalProps(func() {})
 
 	fmt.Println("***************** properties *****************")
@@ -175,12 +256,20 @@ func runStartNodesCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	fmt.Println("**********************************************")
 
-	nodectrl.ParseNodeList(globalProps.GetString(prop.Cluster, "./cluster.json"))
-	nodectrl.StartNodes()
-	return
+	err := nodectrl.ParseNodeList(globalProps.GetString(prop.Cluster, "./cluster.json"))
+	if err == nil {
+		var results []nodectrl.NodeResult
+		results, err = nodectrl.StartNodes()
+		reportNodeResults(results)
+	}
+	if err != nil {
+		fmt.Printf("Error starting nodes [%v]\n", err.Error())
+	}
+	nodectrl.CloseConnections()
 }
 
 func runStopNodesCommandFunc(cmd *cobra.Command, args []string) {
+	nodeCommandWork()
 	initialGlobalProps(func() {})
 
 	fmt.Println("***************** properties *****************")
@@ -189,14 +278,23 @@ func runStopNodesCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	fmt.Println("**********************************************")
 
+	var err error
 	if !nodectrl.NodesParsed() {
-		nodectrl.ParseNodeList(globalProps.GetString(prop.Cluster, "./cluster.json"))
+		err = nodectrl.ParseNodeList(globalProps.GetString(prop.Cluster, "./cluster.json"))
+	}
+	if err == nil {
+		var results []nodectrl.NodeResult
+		results, err = nodectrl.StopNodes()
+		reportNodeResults(results)
+	}
+	if err != nil {
+		fmt.Printf("Error stopping nodes [%v]\n", err.Error())
 	}
-	nodectrl.StopNodes()
-	return
+	nodectrl.CloseConnections()
 }
 
 var (
+	invalidRuns    int
 	threadsArg     int
 	targetArg      int
 	reportInterval int
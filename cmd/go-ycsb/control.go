@@ -0,0 +1,163 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/control"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/workload"
+)
+
+// stopDoneTimeout bounds the wait for the followers of a canceled run, which
+// is force exited 10s after the signal
+const stopDoneTimeout = 5 * time.Second
+
+// globalFollowerParts are the properties of the share of the workload of the
+// followers, by their index in the followers file
+var globalFollowerParts []map[string]string
+
+// partitionWorkload splits the workload between the leader and the followers
+// of the followers file, and keeps the share of the leader in the properties
+func partitionWorkload() {
+	globalFollowerParts = nil
+	followerSrc := globalProps.GetString(prop.FollowerList, "")
+	if followerSrc == "" || globalProps.GetString(prop.ControlLeader, "") != "" ||
+		!globalProps.GetBool(prop.ControlPartition, prop.ControlPartitionDefault) {
+		return
+	}
+	list, err := nodectrl.ReadFollowerList(followerSrc)
+	if err != nil {
+		// reported when the followers are started
+		return
+	}
+
+	parts := len(list.Followers) + 1
+	for i, f := range list.Followers {
+		part := workload.Partition(globalProps, i+1, parts)
+		fmt.Printf("Follower %v share: %v\n", f.Id, formatPart(part))
+		globalFollowerParts = append(globalFollowerParts, part)
+	}
+	part := workload.Partition(globalProps, 0, parts)
+	fmt.Printf("Leader share: %v\n", formatPart(part))
+	for key, value := range part {
+		globalProps.Set(key, value)
+	}
+}
+
+func formatPart(part map[string]string) string {
+	keys := make([]string, 0, len(part))
+	for key := range part {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	s := make([]string, 0, len(keys))
+	for _, key := range keys {
+		s = append(s, key+"="+part[key])
+	}
+	return strings.Join(s, " ")
+}
+
+// startFollowers launches the followers of the followers file and holds them
+// at the start barrier with the leader. It returns the leader side of the
+// control channel and the shared start time.
+func startFollowers(followerSrc string) (*control.Leader, time.Time, error) {
+	if err := nodectrl.ParseFollowerList(followerSrc); err != nil {
+		return nil, time.Time{}, err
+	}
+	leader, err := control.NewLeader(globalProps)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	go func() {
+		<-globalContext.Done()
+		leader.Stop()
+	}()
+
+	launched := nodectrl.StartFollowers(dbName, currentWork, func(i int) map[string]string {
+		props := map[string]string{
+			prop.ControlLeader: fmt.Sprintf(":%d", leader.Port()),
+			prop.ControlToken:  leader.Token(),
+			prop.RunID:         globalProps.GetString(prop.RunID, ""),
+		}
+		if i < len(globalFollowerParts) {
+			for key, value := range globalFollowerParts[i] {
+				props[key] = value
+			}
+		}
+		return props
+	})
+	leader.Expect(launched...)
+
+	timeout := time.Duration(globalProps.GetInt64(prop.ControlBarrierTimeout, prop.ControlBarrierTimeoutDefault)) * time.Second
+	start, err := leader.Barrier(globalContext, timeout)
+	if err != nil {
+		leader.Close()
+		return nil, time.Time{}, err
+	}
+	return leader, start, nil
+}
+
+// finishFollowers waits for the followers to report their completion,
+// downloads the files of their run and merges them with the run of the
+// leader into the report of the cluster
+func finishFollowers(leader *control.Leader, start time.Time) *measurement.ClusterReport {
+	timeout := time.Duration(globalProps.GetInt64(prop.ControlDoneTimeout, prop.ControlDoneTimeoutDefault)) * time.Second
+	if globalContext.Err() != nil {
+		timeout = stopDoneTimeout
+	}
+	finished := leader.WaitDone(context.Background(), timeout)
+	leader.Report(globalProps.GetString(prop.OutputStyle, util.OutputStylePlain))
+	leader.Close()
+	nodectrl.GetFollowersFiles(finished, func(id string) []string {
+		return measurement.RunFilePrefixes(globalProps, id)
+	})
+
+	report := measurement.NewClusterReport(globalProps, start)
+	runs := append([]string{globalProps.GetString(prop.FollowerName, "primary")}, finished...)
+	for _, id := range runs {
+		offset, uncertainty := leader.Clock(id)
+		if err := report.Add(id, offset, uncertainty); err != nil {
+			fmt.Printf("Error reading the measurements of %v [%v]\n", id, err.Error())
+		}
+	}
+	report.Output()
+	if err := report.WriteClocks(); err != nil {
+		fmt.Printf("Error writing the clock offsets [%v]\n", err.Error())
+	}
+	return report
+}
+
+// joinLeader waits at the start barrier of the leader of control.leader, and
+// returns the follower side of the control channel and the run context,
+// canceled when the leader stops the run
+func joinLeader() (*control.Follower, context.Context, error) {
+	follower := control.NewFollower(globalProps)
+	start, err := follower.Ready(globalContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	runCtx := follower.Run(globalContext)
+	fmt.Printf("Starting the run at %v\n", start)
+	control.SleepUntil(runCtx, start)
+	return follower, runCtx, nil
+}
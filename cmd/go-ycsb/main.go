@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/pingcap/go-ycsb/pkg/client"
 	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
@@ -108,6 +109,11 @@ func initialGlobal(dbName string, onProperties func()) {
 	if onProperties != nil {
 		onProperties()
 	}
+	nodectrl.Configure(globalProps)
+
+	if globalProps.GetBool(prop.Prometheus, prop.PrometheusDefault) {
+		measurement.EnablePrometheus()
+	}
 
 	addr := globalProps.GetString(prop.DebugPprof, prop.DebugPprofDefault)
 	go func() {
@@ -137,16 +143,21 @@ func initialGlobal(dbName string, onProperties func()) {
 		util.Fatalf("create db %s failed %v", dbName, err)
 	}
 
-	measurementType, ok := globalProps.Get(prop.MeasurementType)
-	if !ok {
-		measurementType = "raw"
-		globalProps.Set(prop.MeasurementType, "raw")
+	if _, ok := globalProps.Get(prop.RunID); !ok {
+		globalProps.Set(prop.RunID, time.Now().Format(prop.RunIDLayout))
+	}
+
+	if _, ok := globalProps.Get(prop.MeasurementType); !ok {
+		globalProps.Set(prop.MeasurementType, measurement.TypeRaw)
 	}
-	if measurementType == "raw" {
+	histogram := measurement.HistogramCollected(globalProps)
+	if histogram {
+		measurement.InitMeasure(globalProps)
+	}
+	if measurement.RawEnabled(globalProps) {
 		measurement.RawInitMeasure(globalProps)
-		globalDB = client.RawWrapper{DB: globalDB}
+		globalDB = client.RawWrapper{DB: globalDB, Histogram: histogram}
 	} else {
-		measurement.InitMeasure(globalProps)
 		globalDB = client.DbWrapper{DB: globalDB}
 	}
 }
@@ -168,6 +179,7 @@ func initialGlobalProps(onProperties func()) {
 	if onProperties != nil {
 		onProperties()
 	}
+	nodectrl.Configure(globalProps)
 
 	addr := globalProps.GetString(prop.DebugPprof, prop.DebugPprofDefault)
 	go func() {
@@ -215,6 +227,9 @@ func main() {
 		newRunCommand(),
 		newStartNodesCommand(),
 		newStopNodesCommand(),
+		newValidateCommand(),
+		newNodesCommand(),
+		newAgentCommand(),
 	)
 
 	cobra.EnablePrefixMatching = true
@@ -0,0 +1,106 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+var nodeResultHeader = []string{"Node", "Operation", "Duration", "Exit", "Error"}
+
+// reportNodeResults prints the results of an operation on the nodes
+func reportNodeResults(results []nodectrl.NodeResult) {
+	if len(results) == 0 {
+		return
+	}
+	lines := make([][]string, 0, len(results))
+	for _, r := range results {
+		errStr := ""
+		if r.Err != nil {
+			errStr = r.Err.Error()
+		}
+		lines = append(lines, []string{r.Node, r.Operation, r.Duration.Round(time.Millisecond).String(),
+			strconv.Itoa(r.ExitStatus), errStr})
+	}
+
+	fmt.Println("Node results:")
+	switch globalProps.GetString(prop.OutputStyle, util.OutputStylePlain) {
+	case util.OutputStyleJson:
+		util.RenderJson(nodeResultHeader, lines)
+	case util.OutputStyleTable:
+		util.RenderTable(nodeResultHeader, lines)
+	default:
+		util.RenderString("%-10s - %s\n", nodeResultHeader, lines)
+	}
+}
+
+var nodesStatusHeader = []string{"Node", "Transport", "Host", "PID", "State", "Alive", "Updated", "Command"}
+
+func runNodesStatusCommandFunc(cmd *cobra.Command, args []string) {
+	nodeCommandWork()
+	initialGlobalProps(func() {})
+
+	err := nodectrl.ParseNodeList(globalProps.GetString(prop.Cluster, "./cluster.json"))
+	if err != nil {
+		fmt.Printf("Error reading nodes [%v]\n", err.Error())
+		os.Exit(1)
+	}
+	defer nodectrl.CloseConnections()
+
+	var lines [][]string
+	for _, status := range nodectrl.NodesStatus() {
+		updated := ""
+		if !status.Updated.IsZero() {
+			updated = status.Updated.Format(time.RFC3339)
+		}
+		lines = append(lines, []string{status.Id, status.Transport, status.Host, status.Pid,
+			status.State, status.Alive, updated, status.Command})
+	}
+
+	switch globalProps.GetString(prop.OutputStyle, util.OutputStylePlain) {
+	case util.OutputStyleJson:
+		util.RenderJson(nodesStatusHeader, lines)
+	case util.OutputStyleTable:
+		util.RenderTable(nodesStatusHeader, lines)
+	default:
+		util.RenderString("%-10s - %s\n", nodesStatusHeader, lines)
+	}
+}
+
+func newNodesCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "nodes",
+		Short: "Inspect the nodes of the cluster file",
+	}
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show the recorded state of every node and whether its process is alive",
+		Args:  cobra.NoArgs,
+		Run:   runNodesStatusCommandFunc,
+	}
+	initNodeCommand(status)
+
+	m.AddCommand(status)
+	return m
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"github.com/spf13/cobra"
+
+	"github.com/pingcap/go-ycsb/pkg/client"
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/workload"
+)
+
+const (
+	checkPassed  = "ok"
+	checkFailed  = "FAILED"
+	checkSkipped = "skipped"
+)
+
+var validationHeader = []string{"Source", "Check", "Result", "Detail"}
+
+// validation collects the results of the validate command.
+type validation struct {
+	lines  [][]string
+	failed int
+}
+
+// check records the result of a check and returns whether it passed.
+func (v *validation) check(source, check string, err error) bool {
+	if err != nil {
+		v.failed++
+		v.lines = append(v.lines, []string{source, check, checkFailed, err.Error()})
+		return false
+	}
+	v.lines = append(v.lines, []string{source, check, checkPassed, ""})
+	return true
+}
+
+func (v *validation) skip(source, check, reason string) {
+	v.lines = append(v.lines, []string{source, check, checkSkipped, reason})
+}
+
+// loadProperties loads the property file, empty for none, and applies the
+// -p values on top of it.
+func loadProperties(work string) (*properties.Properties, error) {
+	p := properties.NewProperties()
+	if work != "" {
+		var err error
+		if p, err = properties.LoadFile(work, properties.UTF8); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range propertyValues {
+		seps := strings.SplitN(value, "=", 2)
+		if len(seps) != 2 {
+			return nil, fmt.Errorf("bad property: `%s`, expected format `name=value`", value)
+		}
+		p.Set(seps[0], seps[1])
+	}
+	return p, nil
+}
+
+// validateWorkload parses the files referenced by the properties of a
+// workload and checks them against each other.
+func validateWorkload(v *validation, work string) *properties.Properties {
+	source := work
+	if source == "" {
+		source = "-p"
+	}
+	p, err := loadProperties(work)
+	if !v.check(source, "properties", err) {
+		return nil
+	}
+	nodectrl.Configure(p)
+
+	if src := p.GetString(prop.LoadProfile, ""); src != "" {
+		_, err = client.ParseLoadProfile(src)
+		v.check(src, "load profile", err)
+	}
+
+	var nodeIds []string
+	clusterValid := false
+	if src := p.GetString(prop.Cluster, ""); src != "" {
+		nodes, err := nodectrl.ReadNodeList(src)
+		if clusterValid = v.check(src, "cluster file", err); clusterValid {
+			for i := range nodes.Nodes {
+				node := &nodes.Nodes[i]
+				nodeIds = append(nodeIds, node.Id)
+				if node.Local() {
+					v.check(src, fmt.Sprintf("node %v local", node.Id), node.CheckReachable())
+				} else if v.check(src, fmt.Sprintf("node %v config", node.Id), node.CheckConfig()) {
+					validateSSH(v, src, "node "+node.Id, node.CheckReachable)
+				}
+			}
+		}
+	}
+
+	if src := p.GetString(prop.Events, ""); src != "" {
+		events, err := workload.ReadEventList(src)
+		if v.check(src, "events file", err) {
+			if p.GetString(prop.Cluster, "") != "" && !clusterValid {
+				v.skip(src, "event nodes", "invalid cluster file")
+			} else {
+				problems := workload.CheckEvents(events, nodeIds, p)
+				for _, problem := range problems {
+					v.check(src, "event nodes and triggers", errors.New(problem))
+				}
+				if len(problems) == 0 {
+					v.check(src, "event nodes and triggers", nil)
+				}
+			}
+		}
+	}
+
+	if src := p.GetString(prop.FollowerList, ""); src != "" {
+		followers, err := nodectrl.ReadFollowerList(src)
+		if v.check(src, "followers file", err) {
+			for i := range followers.Followers {
+				f := &followers.Followers[i]
+				if v.check(src, fmt.Sprintf("follower %v config", f.Id), f.CheckConfig()) {
+					validateSSH(v, src, "follower "+f.Id, f.CheckSSH)
+				}
+			}
+		}
+	}
+	return p
+}
+
+func validateSSH(v *validation, source, target string, check func() error) {
+	if !validateReachability {
+		v.skip(source, target+" ssh", "use --ssh")
+		return
+	}
+	v.check(source, target+" ssh", check())
+}
+
+func runValidateCommandFunc(cmd *cobra.Command, args []string) {
+	works := propertyFiles
+	if len(works) == 0 {
+		works = []string{""}
+	}
+
+	v := &validation{}
+	outputStyle := util.OutputStylePlain
+	for _, work := range works {
+		if p := validateWorkload(v, work); p != nil {
+			outputStyle = p.GetString(prop.OutputStyle, outputStyle)
+		}
+	}
+
+	switch outputStyle {
+	case util.OutputStyleJson:
+		util.RenderJson(validationHeader, v.lines)
+	case util.OutputStyleTable:
+		util.RenderTable(validationHeader, v.lines)
+	default:
+		util.RenderString("%-20s - %s\n", validationHeader, v.lines)
+	}
+
+	if v.failed > 0 {
+		fmt.Printf("Validation failed: %d of %d checks\n", v.failed, len(v.lines))
+		os.Exit(1)
+	}
+	fmt.Println("Validation passed")
+}
+
+var validateReachability bool
+
+func newValidateCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the property, cluster, events and followers files of workloads without running them",
+		Args:  cobra.NoArgs,
+		Run:   runValidateCommandFunc,
+	}
+
+	initNodeCommand(m)
+	m.Flags().BoolVar(&validateReachability, "ssh", false, "Also log in to every node and follower over SSH, or reach the agent of agent followers")
+	return m
+}
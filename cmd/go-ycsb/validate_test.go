@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeWorkload writes the files of a workload to a directory, $DIR standing
+// for it in their content, and returns the path of the property file
+func writeWorkload(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		content = strings.ReplaceAll(content, "$DIR", dir)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "workload")
+}
+
+func TestValidateWorkload(t *testing.T) {
+	events := `{"events": [{"time": 1, "actions": [{"nodeid": "n1", "cmd": "true"}]}]}`
+	for _, tt := range []struct {
+		name  string
+		files map[string]string
+		// the result of each check of the files, in order
+		want [][2]string
+	}{
+		{
+			name:  "missing property file",
+			files: map[string]string{},
+			want:  [][2]string{{"properties", checkFailed}},
+		},
+		{
+			name: "valid files",
+			files: map[string]string{
+				"workload":     "loadprofile=$DIR/profile.json\nevents=$DIR/events.json\n",
+				"profile.json": `{"profile": [{"time": 5, "target": 100}]}`,
+				"events.json":  `{"events": [{"time": 1, "actions": [{"nodeid": "*", "cmd": "true"}]}]}`,
+			},
+			want: [][2]string{
+				{"properties", checkPassed},
+				{"load profile", checkPassed},
+				{"events file", checkPassed},
+				// a random node without cluster file
+				{"event nodes and triggers", checkFailed},
+			},
+		},
+		{
+			name: "invalid files",
+			files: map[string]string{
+				"workload":     "loadprofile=$DIR/profile.json\nevents=$DIR/events.json\nfollowerlist=$DIR/followers.json\n",
+				"profile.json": `{"profile": [{"time": -1, "target": 100}]}`,
+				"events.json":  `{"events": [`,
+			},
+			want: [][2]string{
+				{"properties", checkPassed},
+				{"load profile", checkFailed},
+				{"events file", checkFailed},
+				{"followers file", checkFailed},
+			},
+		},
+		{
+			name: "events of an invalid cluster",
+			files: map[string]string{
+				"workload":     "cluster=$DIR/cluster.json\nevents=$DIR/events.json\n",
+				"cluster.json": `{"nodes": [{"nodeID": "n1"}, {"nodeID": "n1"}]}`,
+				"events.json":  events,
+			},
+			want: [][2]string{
+				{"properties", checkPassed},
+				{"cluster file", checkFailed},
+				{"events file", checkPassed},
+				{"event nodes", checkSkipped},
+			},
+		},
+		{
+			name: "events of unknown nodes",
+			files: map[string]string{
+				"workload":    "events=$DIR/events.json\noperationcount=0\n",
+				"events.json": `{"events": [{"time": 1, "afterpercent": 50, "actions": [{"nodeid": "n1", "cmd": "true"}]}]}`,
+			},
+			want: [][2]string{
+				{"properties", checkPassed},
+				{"events file", checkPassed},
+				{"event nodes and triggers", checkFailed},
+				{"event nodes and triggers", checkFailed},
+			},
+		},
+	} {
+		v := &validation{}
+		validateWorkload(v, writeWorkload(t, tt.files))
+		var got [][2]string
+		failed := 0
+		for _, line := range v.lines {
+			got = append(got, [2]string{line[1], line[2]})
+			if line[2] == checkFailed {
+				failed++
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: want checks %v, got %v", tt.name, tt.want, v.lines)
+		}
+		if v.failed != failed {
+			t.Errorf("%v: want %v failed checks counted, got %v", tt.name, failed, v.failed)
+		}
+	}
+}
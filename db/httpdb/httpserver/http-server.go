@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
 	"strings"
@@ -84,7 +85,11 @@ func domainParameters(w http.ResponseWriter, req *http.Request) {
 }
 
 func main() {
+	// several servers on different ports can run as the local nodes of a cluster
+	addr := flag.String("addr", ":8090", "address to listen on")
+	flag.Parse()
+
 	http.HandleFunc("/", domainParameters)
 
-	http.ListenAndServe(":8090", nil)
+	http.ListenAndServe(*addr, nil)
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/magiconair/properties"
 	"github.com/pingcap/go-ycsb/pkg/measurement"
 	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
@@ -40,6 +41,9 @@ type worker struct {
 	threadID        int
 	targetOpsTickNs int64
 	opsDone         int64
+	// intended is the scheduled start of the next operation, used to
+	// correct coordinated omission in throttled runs.
+	intended measurement.IntendedStart
 }
 
 func newWorker(p *properties.Properties, threadID int, threadCount int, workload ycsb.Workload, db ycsb.DB) *worker {
@@ -109,6 +113,36 @@ func (w *worker) throttle(ctx context.Context, startTime time.Time) {
 	}
 }
 
+// doOperation executes one transaction or insert, or one batch of them,
+// and returns the number of operations done.
+func (w *worker) doOperation(ctx context.Context) int {
+	var err error
+	opsCount := 1
+	measurement.BeginOperation()
+	if w.doTransactions {
+		if w.doBatch {
+			err = w.workload.DoBatchTransaction(ctx, w.batchSize, w.workDB)
+			opsCount = w.batchSize
+		} else {
+			err = w.workload.DoTransaction(ctx, w.workDB)
+		}
+	} else {
+		if w.doBatch {
+			err = w.workload.DoBatchInsert(ctx, w.batchSize, w.workDB)
+			opsCount = w.batchSize
+		} else {
+			err = w.workload.DoInsert(ctx, w.workDB)
+		}
+	}
+	measurement.EndOperation()
+	measurement.AddOperations(opsCount)
+
+	if err != nil && !w.p.GetBool(prop.Silence, prop.SilenceDefault) {
+		fmt.Printf("operation err: %v\n", err)
+	}
+	return opsCount
+}
+
 // run - worker executes a number of operations
 func (w *worker) run(ctx context.Context) {
 	// spread the thread operation out so they don't all hit the DB at the same time
@@ -116,32 +150,24 @@ func (w *worker) run(ctx context.Context) {
 		time.Sleep(time.Duration(rand.Int63n(w.targetOpsTickNs)))
 	}
 
+	ctx = measurement.WithIntendedStart(ctx, &w.intended)
 	startTime := time.Now()
+	scheduled := measurement.IsWarmUpFinished()
 
 	for w.opCount == 0 || w.opsDone < w.opCount {
-		var err error
-		opsCount := 1
-		if w.doTransactions {
-			if w.doBatch {
-				err = w.workload.DoBatchTransaction(ctx, w.batchSize, w.workDB)
-				opsCount = w.batchSize
-			} else {
-				err = w.workload.DoTransaction(ctx, w.workDB)
-			}
-		} else {
-			if w.doBatch {
-				err = w.workload.DoBatchInsert(ctx, w.batchSize, w.workDB)
-				opsCount = w.batchSize
-			} else {
-				err = w.workload.DoInsert(ctx, w.workDB)
-			}
+		if !scheduled && measurement.IsWarmUpFinished() {
+			// The schedule starts when the warm-up ends, otherwise the
+			// operations not done during the warm-up are made up in a burst.
+			scheduled = true
+			startTime = time.Now()
 		}
-
-		if err != nil && !w.p.GetBool(prop.Silence, prop.SilenceDefault) {
-			fmt.Printf("operation err: %v\n", err)
+		if scheduled && w.targetOpsTickNs > 0 {
+			w.intended.Set(startTime.Add(time.Duration(w.opsDone * w.targetOpsTickNs)))
 		}
 
-		if measurement.IsWarmUpFinished() {
+		opsCount := w.doOperation(ctx)
+
+		if scheduled {
 			w.opsDone += int64(opsCount)
 			w.throttle(ctx, startTime)
 		}
@@ -154,31 +180,41 @@ func (w *worker) run(ctx context.Context) {
 	}
 }
 
+// runProfile executes operations paced by the load profile until the
+// operation count or the load duration is reached.
+func (w *worker) runProfile(ctx context.Context, pc *pacer) {
+	ctx = measurement.WithIntendedStart(ctx, &w.intended)
+	ops := 1
+	if w.doBatch {
+		ops = w.batchSize
+	}
+
+	for {
+		slot, ok := pc.reserve(ctx, w.threadID, ops)
+		if !ok {
+			return
+		}
+		w.intended.Set(slot)
+		w.doOperation(ctx)
+	}
+}
+
+// runDuration executes operations until the load duration is over, or the
+// run is canceled, e.g. by the leader of a distributed run.
 func (w *worker) runDuration(ctx context.Context, startTime time.Time) {
 	timeLeft := (time.Duration(w.loadDuration) * time.Second) - time.Now().Sub(startTime)
+	if timeLeft <= 0 {
+		return
+	}
 	t := time.NewTicker(timeLeft)
+	defer t.Stop()
 
 	for {
-		var err error
-		if w.doTransactions {
-			if w.doBatch {
-				err = w.workload.DoBatchTransaction(ctx, w.batchSize, w.workDB)
-			} else {
-				err = w.workload.DoTransaction(ctx, w.workDB)
-			}
-		} else {
-			if w.doBatch {
-				err = w.workload.DoBatchInsert(ctx, w.batchSize, w.workDB)
-			} else {
-				err = w.workload.DoInsert(ctx, w.workDB)
-			}
-		}
-
-		if err != nil && !w.p.GetBool(prop.Silence, prop.SilenceDefault) {
-			fmt.Printf("operation err: %v\n", err)
-		}
+		w.doOperation(ctx)
 
 		select {
+		case <-ctx.Done():
+			return
 		case <-t.C:
 			return
 		default:
@@ -202,15 +238,37 @@ func NewClient(p *properties.Properties, workload ycsb.Workload, db ycsb.DB) *Cl
 // Run runs the workload to the target DB, and blocks until all workers end.
 func (c *Client) Run(ctx context.Context) {
 	var wg sync.WaitGroup
-	threadCount := c.p.GetInt(prop.ThreadCount, 1)
 	interval := c.p.GetInt64(prop.LogInterval, 10)
-	outputThreads := int64(c.p.GetInt(prop.LoadDuration, prop.LoadDurationDef)) / interval
-	totalThreads := threadCount
-	if c.p.GetString(prop.LoadMode, prop.LoadModeDef) == "duration" {
-		totalThreads = totalThreads + int(outputThreads)
+
+	var profile *LoadProfile
+	if profileSrc := c.p.GetString(prop.LoadProfile, ""); profileSrc != "" {
+		var err error
+		if profile, err = ParseLoadProfile(profileSrc); err != nil {
+			util.Fatalf("parse load profile %s failed %v", profileSrc, err)
+		}
+	}
+	schedule := newLoadSchedule(c.p, profile, time.Now())
+	// the profile counts from the start of the events clock
+	clockStart, err := measurement.ClockStart(c.p)
+	if err != nil {
+		util.Fatalf("start load profile failed %v", err)
+	}
+	select {
+	case <-clockStart:
+		schedule.startClock(time.Now())
+	default:
+		go func() {
+			select {
+			case <-clockStart:
+				schedule.startClock(time.Now())
+			case <-ctx.Done():
+			}
+		}()
 	}
+	// workers above the active thread count of the profile are parked
+	threadCount := schedule.maxThreads
 
-	wg.Add(totalThreads)
+	wg.Add(threadCount)
 	measureCtx, measureCancel := context.WithCancel(ctx)
 	measureCh := make(chan struct{}, 1)
 
@@ -219,13 +277,15 @@ func (c *Client) Run(ctx context.Context) {
 			measureCh <- struct{}{}
 		}()
 		//function to call output based on the measurement type for the log interval
-		mtype := c.p.GetString(prop.MeasurementType, "raw")
+		raw := measurement.RawEnabled(c.p)
+		histogram := measurement.HistogramCollected(c.p)
 		measureFunc := func() {
-			defer wg.Done()
-			if mtype == "raw" {
+			measurement.SetActiveLoad(schedule.active())
+			if raw {
 				measurement.RawOutput()
-			} else {
-				measurement.Output()
+			}
+			if histogram {
+				measurement.IntervalOutput()
 			}
 		}
 		// load stage no need to warm up
@@ -253,7 +313,23 @@ func (c *Client) Run(ctx context.Context) {
 		}
 	}()
 
-	if c.p.GetString(prop.LoadMode, prop.LoadModeDef) == "operationcount" {
+	var open *openLoop
+	switch loadMode := c.p.GetString(prop.LoadMode, prop.LoadModeDef); {
+	case profile != nil && loadMode != prop.LoadModeOpenLoop:
+		pc := newPacer(c.p, schedule)
+		for i := 0; i < threadCount; i++ {
+			go func(threadId int) {
+				defer wg.Done()
+
+				w := newWorker(c.p, threadId, threadCount, c.workload, c.db)
+				ctx := c.workload.InitThread(ctx, threadId, threadCount)
+				ctx = c.db.InitThread(ctx, threadId, threadCount)
+				w.runProfile(ctx, pc)
+				c.db.CleanupThread(ctx)
+				c.workload.CleanupThread(ctx)
+			}(i)
+		}
+	case loadMode == prop.LoadModeDef:
 		for i := 0; i < threadCount; i++ {
 			go func(threadId int) {
 				defer wg.Done()
@@ -266,7 +342,21 @@ func (c *Client) Run(ctx context.Context) {
 				c.workload.CleanupThread(ctx)
 			}(i)
 		}
-	} else {
+	case loadMode == prop.LoadModeOpenLoop:
+		open = newOpenLoop(c.p, schedule, c.workload, c.db)
+		for i := 0; i < threadCount; i++ {
+			go func(threadId int) {
+				defer wg.Done()
+
+				ctx := c.workload.InitThread(ctx, threadId, threadCount)
+				ctx = c.db.InitThread(ctx, threadId, threadCount)
+				open.execute(ctx, threadId)
+				c.db.CleanupThread(ctx)
+				c.workload.CleanupThread(ctx)
+			}(i)
+		}
+		open.dispatch(ctx)
+	default:
 		startTime := time.Now()
 		for i := 0; i < threadCount; i++ {
 			go func(threadId int) {
@@ -283,6 +373,9 @@ func (c *Client) Run(ctx context.Context) {
 	}
 
 	wg.Wait()
+	if open != nil {
+		open.report()
+	}
 	if !c.p.GetBool(prop.DoTransactions, true) {
 		// when loading is finished, try to analyze table if possible.
 		if analyzeDB, ok := c.db.(ycsb.AnalyzeDB); ok {
@@ -27,13 +27,17 @@ type DbWrapper struct {
 	DB ycsb.DB
 }
 
-func measure(start time.Time, end time.Time, op string, key string, values []interface{}, err error) {
+func measure(ctx context.Context, start time.Time, end time.Time, op string, key string, values []interface{}, err error) {
 	if err != nil {
-		measurement.Measure(fmt.Sprintf("%s_ERROR", op), start, end, key, values)
-		return
+		op = fmt.Sprintf("%s_ERROR", op)
 	}
 
-	measurement.Measure(op, start, end, key, values)
+	if measurement.OpLatencyEnabled() {
+		measurement.Measure(op, start, end, key, values)
+	}
+	if intended, ok := measurement.IntendedLatencyStart(ctx, start); ok {
+		measurement.Measure(measurement.IntendedPrefix+op, intended, end, key, values)
+	}
 }
 
 func (db DbWrapper) Close() error {
@@ -56,17 +60,40 @@ func (db DbWrapper) Read(ctx context.Context, table string, key string, fields [
 	for _, dbVal := range dbRead {
 		tempVals = append(tempVals, dbVal)
 	}
-	measure(start, end, "READ", key, tempVals, err)
+	measure(ctx, start, end, "READ", key, tempVals, err)
 
 	return dbRead, err
 }
 
 func (db DbWrapper) BatchRead(ctx context.Context, table string, keys []string, fields []string) (_ []map[string][]byte, err error) {
-	return nil, nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		var res []map[string][]byte
+		for _, key := range keys {
+			values, err := db.Read(ctx, table, key, fields)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, values)
+		}
+		return res, nil
+	}
+
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "BATCH_READ", "", nil, err)
+	}()
+
+	return batchDB.BatchRead(ctx, table, keys, fields)
 }
 
 func (db DbWrapper) Scan(ctx context.Context, table string, startKey string, count int, fields []string) (_ []map[string][]byte, err error) {
-	return nil, nil
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "SCAN", startKey, nil, err)
+	}()
+
+	return db.DB.Scan(ctx, table, startKey, count, fields)
 }
 
 func (db DbWrapper) Update(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
@@ -77,14 +104,30 @@ func (db DbWrapper) Update(ctx context.Context, table string, key string, values
 	}
 
 	defer func() {
-		measure(start, time.Now(), "UPDATE", key, tempVals, err)
+		measure(ctx, start, time.Now(), "UPDATE", key, tempVals, err)
 	}()
 
 	return db.DB.Update(ctx, table, key, values)
 }
 
 func (db DbWrapper) BatchUpdate(ctx context.Context, table string, keys []string, values []map[string][]byte) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for i := range keys {
+			err = db.Update(ctx, table, keys[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "BATCH_UPDATE", "", nil, err)
+	}()
+
+	return batchDB.BatchUpdate(ctx, table, keys, values)
 }
 
 func (db DbWrapper) Insert(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
@@ -95,24 +138,71 @@ func (db DbWrapper) Insert(ctx context.Context, table string, key string, values
 	}
 
 	defer func() {
-		measure(start, time.Now(), "INSERT", key, tempVals, err)
+		measure(ctx, start, time.Now(), "INSERT", key, tempVals, err)
 	}()
 
 	return db.DB.Insert(ctx, table, key, values)
 }
 
 func (db DbWrapper) BatchInsert(ctx context.Context, table string, keys []string, values []map[string][]byte) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for i := range keys {
+			err = db.Insert(ctx, table, keys[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "BATCH_INSERT", "", nil, err)
+	}()
+
+	return batchDB.BatchInsert(ctx, table, keys, values)
 }
 
 func (db DbWrapper) Delete(ctx context.Context, table string, key string) (err error) {
-	return nil
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "DELETE", key, nil, err)
+	}()
+
+	return db.DB.Delete(ctx, table, key)
 }
 
 func (db DbWrapper) BatchDelete(ctx context.Context, table string, keys []string) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for _, key := range keys {
+			err = db.Delete(ctx, table, key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "BATCH_DELETE", "", nil, err)
+	}()
+
+	return batchDB.BatchDelete(ctx, table, keys)
 }
 
-func (db DbWrapper) Analyze(ctx context.Context, table string) error {
-	return nil
+func (db DbWrapper) Analyze(ctx context.Context, table string) (err error) {
+	analyzeDB, ok := db.DB.(ycsb.AnalyzeDB)
+	if !ok {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, time.Now(), "ANALYZE", table, nil, err)
+	}()
+
+	return analyzeDB.Analyze(ctx, table)
 }
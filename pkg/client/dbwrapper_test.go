@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// callDB records the operations forwarded to it
+type callDB struct {
+	calls []string
+}
+
+func (db *callDB) Close() error { return nil }
+
+func (db *callDB) InitThread(ctx context.Context, _ int, _ int) context.Context { return ctx }
+
+func (db *callDB) CleanupThread(_ context.Context) {}
+
+func (db *callDB) Read(_ context.Context, _ string, _ string, _ []string) (map[string][]byte, error) {
+	db.calls = append(db.calls, "Read")
+	return nil, nil
+}
+
+func (db *callDB) Scan(_ context.Context, _ string, _ string, _ int, _ []string) ([]map[string][]byte, error) {
+	db.calls = append(db.calls, "Scan")
+	return nil, nil
+}
+
+func (db *callDB) Update(_ context.Context, _ string, _ string, _ map[string][]byte) error {
+	db.calls = append(db.calls, "Update")
+	return nil
+}
+
+func (db *callDB) Insert(_ context.Context, _ string, _ string, _ map[string][]byte) error {
+	db.calls = append(db.calls, "Insert")
+	return nil
+}
+
+func (db *callDB) Delete(_ context.Context, _ string, _ string) error {
+	db.calls = append(db.calls, "Delete")
+	return nil
+}
+
+// callBatchDB also supports the batch operations and the analysis
+type callBatchDB struct {
+	callDB
+}
+
+func (db *callBatchDB) BatchInsert(_ context.Context, _ string, _ []string, _ []map[string][]byte) error {
+	db.calls = append(db.calls, "BatchInsert")
+	return nil
+}
+
+func (db *callBatchDB) BatchRead(_ context.Context, _ string, _ []string, _ []string) ([]map[string][]byte, error) {
+	db.calls = append(db.calls, "BatchRead")
+	return nil, nil
+}
+
+func (db *callBatchDB) BatchUpdate(_ context.Context, _ string, _ []string, _ []map[string][]byte) error {
+	db.calls = append(db.calls, "BatchUpdate")
+	return nil
+}
+
+func (db *callBatchDB) BatchDelete(_ context.Context, _ string, _ []string) error {
+	db.calls = append(db.calls, "BatchDelete")
+	return nil
+}
+
+func (db *callBatchDB) Analyze(_ context.Context, _ string) error {
+	db.calls = append(db.calls, "Analyze")
+	return nil
+}
+
+func TestDbWrapperForwardsOperations(t *testing.T) {
+	plain, batch := new(callDB), new(callBatchDB)
+	for _, c := range []struct {
+		name  string
+		db    DbWrapper
+		calls *[]string
+		want  []string
+		ops   map[string]int64
+	}{
+		{
+			name:  "batches run one by one",
+			db:    DbWrapper{DB: plain},
+			calls: &plain.calls,
+			want:  []string{"Read", "Scan", "Update", "Insert", "Delete", "Read", "Read", "Update", "Update", "Insert", "Insert", "Delete", "Delete"},
+			ops:   map[string]int64{"READ": 3, "SCAN": 1, "UPDATE": 3, "INSERT": 3, "DELETE": 3},
+		},
+		{
+			name:  "batch database",
+			db:    DbWrapper{DB: batch},
+			calls: &batch.calls,
+			want:  []string{"Read", "Scan", "Update", "Insert", "Delete", "BatchRead", "BatchUpdate", "BatchInsert", "BatchDelete", "Analyze"},
+			ops: map[string]int64{
+				"READ": 1, "SCAN": 1, "UPDATE": 1, "INSERT": 1, "DELETE": 1,
+				"BATCH_READ": 1, "BATCH_UPDATE": 1, "BATCH_INSERT": 1, "BATCH_DELETE": 1, "ANALYZE": 1,
+			},
+		},
+	} {
+		p := properties.NewProperties()
+		p.Set(prop.MeasurementType, "histogram")
+		measurement.InitMeasure(p)
+
+		ctx := context.Background()
+		keys := []string{"user1", "user2"}
+		values := []map[string][]byte{nil, nil}
+		c.db.Read(ctx, "usertable", "user1", nil)
+		c.db.Scan(ctx, "usertable", "user1", 10, nil)
+		c.db.Update(ctx, "usertable", "user1", nil)
+		c.db.Insert(ctx, "usertable", "user1", nil)
+		c.db.Delete(ctx, "usertable", "user1")
+		c.db.BatchRead(ctx, "usertable", keys, nil)
+		c.db.BatchUpdate(ctx, "usertable", keys, values)
+		c.db.BatchInsert(ctx, "usertable", keys, values)
+		c.db.BatchDelete(ctx, "usertable", keys)
+		c.db.Analyze(ctx, "usertable")
+
+		if !reflect.DeepEqual(*c.calls, c.want) {
+			t.Errorf("%v: want calls %v, got %v", c.name, c.want, *c.calls)
+		}
+		ops := make(map[string]int64)
+		for op, info := range measurement.Info() {
+			ops[op] = info.Get(measurement.COUNT).(int64)
+		}
+		if !reflect.DeepEqual(ops, c.ops) {
+			t.Errorf("%v: want operations %v, got %v", c.name, c.ops, ops)
+		}
+	}
+}
+
+func TestMeasureLatencyModes(t *testing.T) {
+	start := time.Now()
+	end := start.Add(10 * time.Millisecond)
+	for _, c := range []struct {
+		latency string
+		// the maximum latency of each operation, in microseconds
+		want map[string]int64
+	}{
+		{measurement.LatencyOp, map[string]int64{"READ": 10000, "UPDATE_ERROR": 10000}},
+		{measurement.LatencyIntended, map[string]int64{"Intended-READ": 1010000, "Intended-UPDATE_ERROR": 10000}},
+		{measurement.LatencyBoth, map[string]int64{
+			"READ": 10000, "UPDATE_ERROR": 10000, "Intended-READ": 1010000, "Intended-UPDATE_ERROR": 10000,
+		}},
+	} {
+		p := properties.NewProperties()
+		p.Set(prop.MeasurementType, "histogram")
+		p.Set(prop.MeasurementLatency, c.latency)
+		measurement.InitMeasure(p)
+
+		// the read was scheduled a second before it started, the update
+		// of a worker which is not throttled starts when intended
+		intended := new(measurement.IntendedStart)
+		intended.Set(start.Add(-time.Second))
+		measure(measurement.WithIntendedStart(context.Background(), intended), start, end, "READ", "user1", nil, nil)
+		measure(context.Background(), start, end, "UPDATE", "user1", nil, errors.New("failed"))
+
+		info := measurement.Info()
+		var ops, wantOps []string
+		for op := range info {
+			ops = append(ops, op)
+		}
+		for op, max := range c.want {
+			wantOps = append(wantOps, op)
+			if got, ok := info[op]; ok && !within(got.Get(measurement.MAX).(int64), max) {
+				t.Errorf("%v: %v: want a latency of %vus, got %vus", c.latency, op, max, got.Get(measurement.MAX))
+			}
+		}
+		sort.Strings(ops)
+		sort.Strings(wantOps)
+		if !reflect.DeepEqual(ops, wantOps) {
+			t.Errorf("%v: want operations %v, got %v", c.latency, wantOps, ops)
+		}
+	}
+}
+
+// within tells whether the latency recorded by a histogram is the expected
+// one, up to the precision of the histogram
+func within(got, want int64) bool {
+	return got >= want && got <= want+want/100
+}
@@ -0,0 +1,334 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Load phase shapes.
+const (
+	// PhaseStep switches to the target at the phase time.
+	PhaseStep = "step"
+	// PhaseRamp moves linearly from the previous target to the target over the duration.
+	PhaseRamp = "ramp"
+	// PhaseSine oscillates around the target by the amplitude for the duration.
+	PhaseSine = "sine"
+)
+
+// parkInterval is how often a parked worker checks whether it became active.
+const parkInterval = 100 * time.Millisecond
+
+// LoadPhase changes the target throughput and/or the thread count at a time
+// relative to the start of the workload.
+type LoadPhase struct {
+	RelativeTime float64 `json:"time"`
+	Shape        string  `json:"type"`
+	Duration     float64 `json:"duration"`
+	// Target in operations per second, 0 means unthrottled and no target
+	// keeps the current one
+	Target    *float64 `json:"target"`
+	Amplitude float64  `json:"amplitude"`
+	Period    float64  `json:"period"`
+	// Threads is the number of active workers from the phase on, 0 keeps the current one
+	Threads int `json:"threads"`
+}
+
+type LoadPhaseList []LoadPhase
+
+type LoadProfile struct {
+	Phases LoadPhaseList `json:"profile"`
+}
+
+// Len Sort interface implementation so we can sort by RelativeTime
+func (s LoadPhaseList) Len() int {
+	return len(s)
+}
+
+// Less Sort interface implementation so we can sort by RelativeTime
+func (s LoadPhaseList) Less(i, j int) bool {
+	return s[i].RelativeTime < s[j].RelativeTime
+}
+
+// Swap Sort interface implementation so we can sort by RelativeTime
+func (s LoadPhaseList) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (l *LoadPhase) validate() error {
+	if l.Shape == "" {
+		l.Shape = PhaseStep
+	}
+	if l.RelativeTime < 0 || (l.Target != nil && *l.Target < 0) || l.Threads < 0 {
+		return fmt.Errorf("load phase at %vs: time, target and threads must not be negative", l.RelativeTime)
+	}
+	switch l.Shape {
+	case PhaseStep:
+	case PhaseRamp:
+		if l.Target == nil || l.Duration <= 0 {
+			return fmt.Errorf("load phase at %vs: ramp needs a target and a positive duration", l.RelativeTime)
+		}
+	case PhaseSine:
+		if l.Target == nil || l.Period <= 0 || l.Duration < 0 {
+			return fmt.Errorf("load phase at %vs: sine needs a target and a positive period", l.RelativeTime)
+		}
+	default:
+		return fmt.Errorf("load phase at %vs: unsupported type %q", l.RelativeTime, l.Shape)
+	}
+	return nil
+}
+
+// ParseLoadProfile reads the load profile file to internal data structure
+func ParseLoadProfile(jsonSource string) (*LoadProfile, error) {
+	bytes, err := os.ReadFile(jsonSource)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile LoadProfile
+	err = json.Unmarshal(bytes, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range profile.Phases {
+		if err = profile.Phases[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Stable(profile.Phases)
+
+	return &profile, nil
+}
+
+// loadSchedule evaluates a load profile on top of the target and threadcount
+// properties, which apply until the first phase. The phase times count from
+// the start of the clock of events.clock, like the event times, and the
+// properties apply until it starts.
+type loadSchedule struct {
+	profile    *LoadProfile
+	target     float64
+	threads    int
+	maxThreads int
+	// startTime is the start of the run, which loadduration counts from
+	startTime time.Time
+	// clockStart is the Unix nanoseconds the phase times count from, 0
+	// until the clock starts
+	clockStart int64
+}
+
+func newLoadSchedule(p *properties.Properties, profile *LoadProfile, startTime time.Time) *loadSchedule {
+	s := new(loadSchedule)
+	if profile == nil {
+		profile = new(LoadProfile)
+	}
+	s.profile = profile
+	s.target = float64(p.GetInt64(prop.Target, 0))
+	s.threads = p.GetInt(prop.ThreadCount, 1)
+	s.maxThreads = s.threads
+	for _, phase := range profile.Phases {
+		if phase.Threads > s.maxThreads {
+			s.maxThreads = phase.Threads
+		}
+	}
+	s.startTime = startTime
+	return s
+}
+
+// startClock starts the phase times at t.
+func (s *loadSchedule) startClock(t time.Time) {
+	atomic.StoreInt64(&s.clockStart, t.UnixNano())
+}
+
+// elapsed returns the time of the profile at t, negative before the clock
+// starts.
+func (s *loadSchedule) elapsed(t time.Time) time.Duration {
+	start := atomic.LoadInt64(&s.clockStart)
+	if start == 0 {
+		return -1
+	}
+	return time.Duration(t.UnixNano() - start)
+}
+
+// targetAt returns the target throughput at the elapsed time, in operations per second.
+func (s *loadSchedule) targetAt(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	i := sort.Search(len(s.profile.Phases), func(i int) bool {
+		return s.profile.Phases[i].RelativeTime > t
+	})
+	return s.phaseTarget(i-1, t)
+}
+
+// phaseTarget returns the target set by the i-th phase at time t.
+func (s *loadSchedule) phaseTarget(i int, t float64) float64 {
+	if i < 0 {
+		return s.target
+	}
+
+	phase := &s.profile.Phases[i]
+	if phase.Target == nil {
+		return s.phaseTarget(i-1, t)
+	}
+	target := *phase.Target
+	offset := t - phase.RelativeTime
+	switch phase.Shape {
+	case PhaseRamp:
+		if offset >= phase.Duration {
+			return target
+		}
+		from := s.phaseTarget(i-1, phase.RelativeTime)
+		return from + (target-from)*offset/phase.Duration
+	case PhaseSine:
+		if phase.Duration > 0 && offset >= phase.Duration {
+			return target
+		}
+		// a target of 0 would mean unthrottled, so the wave bottoms out at 1 op/s
+		return math.Max(1, target+phase.Amplitude*math.Sin(2*math.Pi*offset/phase.Period))
+	default:
+		return target
+	}
+}
+
+// threadsAt returns the number of active workers at the elapsed time.
+func (s *loadSchedule) threadsAt(elapsed time.Duration) int {
+	t := elapsed.Seconds()
+	threads := s.threads
+	for _, phase := range s.profile.Phases {
+		if phase.RelativeTime > t {
+			break
+		}
+		if phase.Threads > 0 {
+			threads = phase.Threads
+		}
+	}
+	return threads
+}
+
+// active returns the target and active workers now.
+func (s *loadSchedule) active() (float64, int) {
+	elapsed := s.elapsed(time.Now())
+	return s.targetAt(elapsed), s.threadsAt(elapsed)
+}
+
+// waitActive parks the worker while the profile has less active workers than
+// its id, and returns false if the context is done or the run finished first.
+func (s *loadSchedule) waitActive(ctx context.Context, threadID int, finished func() bool) bool {
+	for threadID >= s.threadsAt(s.elapsed(time.Now())) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(parkInterval):
+		}
+		if finished() {
+			return false
+		}
+	}
+	return true
+}
+
+// pacer hands out the start times of the operations of all the workers so
+// that together they follow the target of the load schedule.
+type pacer struct {
+	schedule *loadSchedule
+	// opCount is the number of operations to do, 0 means until the deadline
+	opCount  int64
+	deadline time.Time
+
+	mu        sync.Mutex
+	scheduled bool
+	next      time.Time
+	opsDone   int64
+}
+
+func newPacer(p *properties.Properties, schedule *loadSchedule) *pacer {
+	pc := &pacer{schedule: schedule}
+	if p.GetString(prop.LoadMode, prop.LoadModeDef) == prop.LoadModeDef {
+		if !p.GetBool(prop.DoTransactions, true) {
+			if _, ok := p.Get(prop.InsertCount); ok {
+				pc.opCount = p.GetInt64(prop.InsertCount, 0)
+			} else {
+				pc.opCount = p.GetInt64(prop.RecordCount, 0)
+			}
+		} else {
+			pc.opCount = p.GetInt64(prop.OperationCount, 0)
+		}
+	} else {
+		pc.deadline = schedule.startTime.Add(time.Duration(p.GetInt(prop.LoadDuration, prop.LoadDurationDef)) * time.Second)
+	}
+	return pc
+}
+
+func (pc *pacer) finished() bool {
+	if !pc.deadline.IsZero() && time.Now().After(pc.deadline) {
+		return true
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.opCount > 0 && pc.opsDone >= pc.opCount
+}
+
+// reserve waits until the worker is active and its next operations are due,
+// and returns their intended start. It returns false when the run is over.
+func (pc *pacer) reserve(ctx context.Context, threadID int, ops int) (time.Time, bool) {
+	if !pc.schedule.waitActive(ctx, threadID, pc.finished) || pc.finished() {
+		return time.Time{}, false
+	}
+
+	now := time.Now()
+	// operations during the warm-up are neither paced nor counted
+	if !measurement.IsWarmUpFinished() {
+		return now, true
+	}
+
+	pc.mu.Lock()
+	if pc.opCount > 0 && pc.opsDone >= pc.opCount {
+		pc.mu.Unlock()
+		return time.Time{}, false
+	}
+	pc.opsDone += int64(ops)
+	if !pc.scheduled {
+		pc.scheduled = true
+		pc.next = now
+	}
+	slot := pc.next
+	target := pc.schedule.targetAt(pc.schedule.elapsed(slot))
+	if target <= 0 {
+		slot = now
+		pc.next = now
+	} else {
+		pc.next = slot.Add(time.Duration(float64(ops) * float64(time.Second) / target))
+	}
+	pc.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		select {
+		case <-ctx.Done():
+			return time.Time{}, false
+		case <-time.After(d):
+		}
+	}
+	return slot, true
+}
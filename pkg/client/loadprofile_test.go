@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func writeProfile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseLoadProfile(t *testing.T) {
+	profile, err := ParseLoadProfile(writeProfile(t, `{"profile": [
+		{"time": 20, "type": "ramp", "target": 500, "duration": 10},
+		{"time": 5, "target": 100, "threads": 4},
+		{"time": 40, "type": "sine", "target": 300, "amplitude": 100, "period": 8}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases := profile.Phases
+	if len(phases) != 3 || phases[0].RelativeTime != 5 || phases[1].RelativeTime != 20 || phases[2].RelativeTime != 40 {
+		t.Fatalf("want the phases sorted by time, got %+v", phases)
+	}
+	if phases[0].Shape != PhaseStep {
+		t.Fatalf("want a step by default, got %v", phases[0].Shape)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		profile string
+	}{
+		{"negative time", `{"profile": [{"time": -1, "target": 10}]}`},
+		{"negative target", `{"profile": [{"time": 1, "target": -10}]}`},
+		{"ramp without duration", `{"profile": [{"time": 1, "type": "ramp", "target": 10}]}`},
+		{"ramp without target", `{"profile": [{"time": 1, "type": "ramp", "duration": 10}]}`},
+		{"sine without period", `{"profile": [{"time": 1, "type": "sine", "target": 10}]}`},
+		{"unknown type", `{"profile": [{"time": 1, "type": "square", "target": 10}]}`},
+		{"invalid json", `{"profile": [`},
+	} {
+		if _, err := ParseLoadProfile(writeProfile(t, tt.profile)); err == nil {
+			t.Errorf("%v: want an error", tt.name)
+		}
+	}
+	if _, err := ParseLoadProfile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("want an error for a missing file")
+	}
+}
+
+func target(v float64) *float64 {
+	return &v
+}
+
+func TestPhaseTarget(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.Target, "50")
+	p.Set(prop.ThreadCount, "2")
+	schedule := newLoadSchedule(p, &LoadProfile{Phases: LoadPhaseList{
+		{RelativeTime: 10, Shape: PhaseStep, Target: target(100), Threads: 8},
+		{RelativeTime: 20, Shape: PhaseRamp, Target: target(300), Duration: 10},
+		{RelativeTime: 40, Shape: PhaseStep, Threads: 4},
+		{RelativeTime: 50, Shape: PhaseSine, Target: target(300), Amplitude: 100, Period: 8, Duration: 16},
+	}}, time.Now())
+	if schedule.maxThreads != 8 {
+		t.Fatalf("want the largest thread count of the profile, got %v", schedule.maxThreads)
+	}
+
+	for _, tt := range []struct {
+		at      float64
+		target  float64
+		threads int
+	}{
+		{0, 50, 2},
+		{10, 100, 8},
+		{19.9, 100, 8},
+		// the ramp goes from the target of the previous phase
+		{20, 100, 8},
+		{25, 200, 8},
+		{30, 300, 8},
+		// a phase without target keeps the current one
+		{45, 300, 4},
+		// the sine peaks at a quarter of the period
+		{52, 400, 4},
+		{56, 200, 4},
+		{66, 300, 4},
+	} {
+		elapsed := time.Duration(tt.at * float64(time.Second))
+		if got := schedule.targetAt(elapsed); math.Abs(got-tt.target) > 1e-6 {
+			t.Errorf("target at %vs: want %v, got %v", tt.at, tt.target, got)
+		}
+		if got := schedule.threadsAt(elapsed); got != tt.threads {
+			t.Errorf("threads at %vs: want %v, got %v", tt.at, tt.threads, got)
+		}
+	}
+
+	// the wave bottoms out at 1 op/s, 0 would be unthrottled
+	low := newLoadSchedule(p, &LoadProfile{Phases: LoadPhaseList{
+		{Shape: PhaseSine, Target: target(10), Amplitude: 50, Period: 4},
+	}}, time.Now())
+	if got := low.targetAt(3 * time.Second); got != 1 {
+		t.Errorf("want the sine clamped to 1 op/s, got %v", got)
+	}
+}
+
+func TestLoadScheduleClock(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.Target, "50")
+	schedule := newLoadSchedule(p, &LoadProfile{Phases: LoadPhaseList{
+		{RelativeTime: 0, Shape: PhaseStep, Target: target(100)},
+	}}, time.Now().Add(-time.Hour))
+
+	// the properties apply until the clock starts, however long the run is
+	if got, _ := schedule.active(); got != 50 {
+		t.Fatalf("want the target property before the clock starts, got %v", got)
+	}
+	schedule.startClock(time.Now())
+	if got, _ := schedule.active(); got != 100 {
+		t.Fatalf("want the first phase once the clock started, got %v", got)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.Target, "200")
+	p.Set(prop.OperationCount, "5")
+	schedule := newLoadSchedule(p, nil, time.Now())
+	schedule.startClock(time.Now())
+	pc := newPacer(p, schedule)
+
+	ctx := context.Background()
+	var slots []time.Time
+	for {
+		slot, ok := pc.reserve(ctx, 0, 1)
+		if !ok {
+			break
+		}
+		slots = append(slots, slot)
+	}
+	if len(slots) != 5 || !pc.finished() {
+		t.Fatalf("want the 5 operations of operationcount, got %d", len(slots))
+	}
+	for i := 1; i < len(slots); i++ {
+		if gap := slots[i].Sub(slots[i-1]); gap != 5*time.Millisecond {
+			t.Fatalf("want the operations 5ms apart at 200 ops/s, got %v", gap)
+		}
+	}
+
+	// an unthrottled schedule does not wait
+	p.Set(prop.Target, "0")
+	pc = newPacer(p, newLoadSchedule(p, nil, time.Now()))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, ok := pc.reserve(ctx, 0, 1); !ok {
+			t.Fatalf("want operation %d reserved", i)
+		}
+	}
+	if d := time.Since(start); d > 10*time.Millisecond {
+		t.Fatalf("want no pacing without a target, took %v", d)
+	}
+}
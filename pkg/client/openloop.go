@@ -0,0 +1,225 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+// openLoop issues operations from an arrival process into a bounded queue
+// served by a pool of executors, so the offered load does not depend on
+// how fast the DB answers.
+type openLoop struct {
+	p              *properties.Properties
+	workload       ycsb.Workload
+	db             ycsb.DB
+	schedule       *loadSchedule
+	doTransactions bool
+	batchSize      int
+
+	// opCount is the number of operations to issue, 0 means loadDuration.
+	opCount       int64
+	loadDuration  time.Duration
+	poisson       bool
+	lateThreshold time.Duration
+	// seed seeds the arrival process
+	seed int64
+
+	queue chan time.Time
+
+	dispatched int32
+
+	issued  int64
+	dropped int64
+	late    int64
+}
+
+func newOpenLoop(p *properties.Properties, schedule *loadSchedule, workload ycsb.Workload, db ycsb.DB) *openLoop {
+	o := new(openLoop)
+	o.p = p
+	o.workload = workload
+	o.db = db
+	o.schedule = schedule
+	o.doTransactions = p.GetBool(prop.DoTransactions, true)
+	o.batchSize = p.GetInt(prop.BatchSize, prop.DefaultBatchSize)
+	if o.batchSize < 1 {
+		o.batchSize = 1
+	}
+
+	if o.doTransactions {
+		o.opCount = p.GetInt64(prop.OperationCount, 0)
+	} else if _, ok := p.Get(prop.InsertCount); ok {
+		o.opCount = p.GetInt64(prop.InsertCount, 0)
+	} else {
+		o.opCount = p.GetInt64(prop.RecordCount, 0)
+	}
+	o.loadDuration = time.Duration(p.GetInt(prop.LoadDuration, prop.LoadDurationDef)) * time.Second
+
+	if schedule.target <= 0 && len(schedule.profile.Phases) == 0 {
+		util.Fatalf("%s %s needs a %s", prop.LoadMode, prop.LoadModeOpenLoop, prop.Target)
+	}
+
+	switch arrival := p.GetString(prop.OpenLoopArrival, prop.OpenLoopArrivalDefault); arrival {
+	case "poisson":
+		o.poisson = true
+	case "constant":
+	default:
+		util.Fatalf("unsupported %s: %s", prop.OpenLoopArrival, arrival)
+	}
+
+	o.lateThreshold = time.Duration(p.GetInt64(prop.OpenLoopLateThreshold, prop.OpenLoopLateThresholdDefault)) * time.Millisecond
+	o.seed = time.Now().UnixNano()
+	o.queue = make(chan time.Time, p.GetInt(prop.OpenLoopQueueSize, prop.OpenLoopQueueSizeDefault))
+	return o
+}
+
+// nextInterArrival returns the time to the next arrival for the given target
+// throughput. Every arrival issues one batch.
+func (o *openLoop) nextInterArrival(r *rand.Rand, target float64) time.Duration {
+	mean := float64(time.Second) * float64(o.batchSize) / target
+	if o.poisson {
+		return time.Duration(r.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+// dispatch queues the arrivals until opCount operations were issued after the
+// warm-up, the load duration elapsed or the context is done, then closes the
+// queue. Arrivals are scheduled on absolute times, so when the dispatcher
+// oversleeps it catches up with a burst instead of lowering the offered load.
+func (o *openLoop) dispatch(ctx context.Context) {
+	defer func() {
+		atomic.StoreInt32(&o.dispatched, 1)
+		close(o.queue)
+	}()
+
+	r := rand.New(rand.NewSource(o.seed))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	startTime := o.schedule.startTime
+	next := time.Now()
+	for {
+		target := o.schedule.targetAt(o.schedule.elapsed(next))
+		if target > 0 {
+			next = next.Add(o.nextInterArrival(r, target))
+		} else {
+			next = next.Add(parkInterval)
+		}
+		if d := time.Until(next); d > 0 {
+			timer.Reset(d)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+		}
+
+		if o.opCount == 0 && time.Since(startTime) >= o.loadDuration {
+			return
+		}
+		// no arrivals while the load profile sets no target
+		if target <= 0 {
+			continue
+		}
+
+		warmUpFinished := measurement.IsWarmUpFinished()
+		select {
+		case o.queue <- next:
+		default:
+			if warmUpFinished {
+				atomic.AddInt64(&o.dropped, int64(o.batchSize))
+				measurement.Measure(measurement.OpDropped, next, time.Now(), "", nil)
+			}
+		}
+
+		if warmUpFinished {
+			issued := atomic.AddInt64(&o.issued, int64(o.batchSize))
+			if o.opCount > 0 && issued >= o.opCount {
+				return
+			}
+		}
+	}
+}
+
+// execute serves the queued arrivals until the queue is closed. The arrival
+// is the intended start of the operation, so measurement.latency "intended"
+// reports the response time including the queueing delay.
+func (o *openLoop) execute(ctx context.Context, threadID int) {
+	w := &worker{
+		p:              o.p,
+		workDB:         o.db,
+		workload:       o.workload,
+		doTransactions: o.doTransactions,
+		doBatch:        o.batchSize > 1,
+		batchSize:      o.batchSize,
+		threadID:       threadID,
+	}
+	ctx = measurement.WithIntendedStart(ctx, &w.intended)
+
+	for {
+		if !o.schedule.waitActive(ctx, threadID, o.finished) {
+			return
+		}
+
+		var arrival time.Time
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case arrival, ok = <-o.queue:
+			if !ok {
+				return
+			}
+		}
+
+		now := time.Now()
+		if measurement.IsWarmUpFinished() {
+			measurement.Measure(measurement.OpQueue, arrival, now, "", nil)
+			if now.Sub(arrival) > o.lateThreshold {
+				atomic.AddInt64(&o.late, int64(o.batchSize))
+				measurement.Measure(measurement.OpLate, arrival, now, "", nil)
+			}
+		}
+
+		w.intended.Set(arrival)
+		w.doOperation(ctx)
+	}
+}
+
+func (o *openLoop) finished() bool {
+	return atomic.LoadInt32(&o.dispatched) == 1
+}
+
+func (o *openLoop) report() {
+	fmt.Printf("Open loop - Issued: %d, Dropped: %d, Late: %d\n",
+		atomic.LoadInt64(&o.issued), atomic.LoadInt64(&o.dropped), atomic.LoadInt64(&o.late))
+}
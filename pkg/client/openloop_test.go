@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+func openLoopProps(arrival string, batchSize int) *properties.Properties {
+	p := properties.NewProperties()
+	p.Set(prop.Target, "1000")
+	p.Set(prop.OpenLoopArrival, arrival)
+	p.Set(prop.BatchSize, strconv.Itoa(batchSize))
+	return p
+}
+
+func TestOpenLoopInterArrival(t *testing.T) {
+	for _, c := range []struct {
+		arrival   string
+		batchSize int
+		// the mean time between the arrivals of 1000 operations per second
+		want time.Duration
+	}{
+		{"poisson", 1, time.Millisecond},
+		{"poisson", 4, 4 * time.Millisecond},
+		{"constant", 1, time.Millisecond},
+		{"constant", 4, 4 * time.Millisecond},
+	} {
+		p := openLoopProps(c.arrival, c.batchSize)
+		o := newOpenLoop(p, newLoadSchedule(p, nil, time.Now()), nil, nil)
+		r := rand.New(rand.NewSource(1))
+
+		const n = 100000
+		var sum, sumSquares float64
+		for i := 0; i < n; i++ {
+			d := float64(o.nextInterArrival(r, 1000))
+			sum += d
+			sumSquares += d * d
+		}
+		mean := sum / n
+		stddev := math.Sqrt(sumSquares/n - mean*mean)
+		if math.Abs(mean-float64(c.want)) > 0.01*float64(c.want) {
+			t.Errorf("%v batch %v: want a mean of %v, got %v", c.arrival, c.batchSize, c.want, time.Duration(mean))
+		}
+		// the exponential distribution has its mean as standard deviation
+		wantStddev := 0.0
+		if c.arrival == "poisson" {
+			wantStddev = float64(c.want)
+		}
+		if math.Abs(stddev-wantStddev) > 0.02*float64(c.want) {
+			t.Errorf("%v batch %v: want a standard deviation of %v, got %v",
+				c.arrival, c.batchSize, time.Duration(wantStddev), time.Duration(stddev))
+		}
+	}
+}
+
+func TestOpenLoopDropsWhenQueueFull(t *testing.T) {
+	p := openLoopProps("poisson", 2)
+	p.Set(prop.Target, "1000000")
+	p.Set(prop.OperationCount, "20")
+	p.Set(prop.OpenLoopQueueSize, "3")
+	p.Set(prop.MeasurementType, "histogram")
+	measurement.InitMeasure(p)
+
+	schedule := newLoadSchedule(p, nil, time.Now())
+	o := newOpenLoop(p, schedule, nil, nil)
+	o.seed = 1
+
+	// nothing serves the queue: the first 3 arrivals are queued, the other
+	// 7 are dropped, 2 operations each
+	o.dispatch(context.Background())
+	if !o.finished() {
+		t.Error("want the dispatch finished")
+	}
+	if o.issued != 20 || o.dropped != 14 {
+		t.Errorf("want 20 operations issued and 14 dropped, got %v and %v", o.issued, o.dropped)
+	}
+	queued := 0
+	for range o.queue {
+		queued++
+	}
+	if queued != 3 {
+		t.Errorf("want 3 queued arrivals, got %v", queued)
+	}
+	if got := measurement.Info()[measurement.OpDropped].Get(measurement.COUNT).(int64); got != 7 {
+		t.Errorf("want 7 dropped arrivals measured, got %v", got)
+	}
+}
+
+func TestOpenLoopDispatchCanceled(t *testing.T) {
+	p := openLoopProps("constant", 1)
+	p.Set(prop.Target, "1")
+	schedule := newLoadSchedule(p, nil, time.Now())
+	o := newOpenLoop(p, schedule, nil, nil)
+
+	// an arrival a second away does not hold the canceled dispatch
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		o.dispatch(ctx)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the dispatch ended by the context")
+	}
+	if _, ok := <-o.queue; ok {
+		t.Error("want no arrival queued and the queue closed")
+	}
+}
+
+// intendedWorkload records the intended start of its transactions
+type intendedWorkload struct {
+	intended []time.Time
+}
+
+func (w *intendedWorkload) Close() error { return nil }
+
+func (w *intendedWorkload) InitThread(ctx context.Context, _ int, _ int) context.Context { return ctx }
+
+func (w *intendedWorkload) CleanupThread(_ context.Context) {}
+
+func (w *intendedWorkload) Load(_ context.Context, _ ycsb.DB, _ int64) error { return nil }
+
+func (w *intendedWorkload) DoInsert(_ context.Context, _ ycsb.DB) error { return nil }
+
+func (w *intendedWorkload) DoBatchInsert(_ context.Context, _ int, _ ycsb.DB) error { return nil }
+
+func (w *intendedWorkload) DoTransaction(ctx context.Context, _ ycsb.DB) error {
+	start, _ := measurement.IntendedLatencyStart(ctx, time.Now())
+	w.intended = append(w.intended, start)
+	return nil
+}
+
+func (w *intendedWorkload) DoBatchTransaction(_ context.Context, _ int, _ ycsb.DB) error { return nil }
+
+func TestOpenLoopExecuteCountsLate(t *testing.T) {
+	p := openLoopProps("constant", 1)
+	p.Set(prop.OpenLoopLateThreshold, "100")
+	p.Set(prop.MeasurementType, "histogram")
+	p.Set(prop.MeasurementLatency, measurement.LatencyIntended)
+	measurement.InitMeasure(p)
+
+	workload := new(intendedWorkload)
+	o := newOpenLoop(p, newLoadSchedule(p, nil, time.Now()), workload, nil)
+	now := time.Now()
+	arrivals := []time.Time{now.Add(-time.Second), now}
+	for _, arrival := range arrivals {
+		o.queue <- arrival
+	}
+	close(o.queue)
+	o.dispatched = 1
+
+	o.execute(context.Background(), 0)
+	if o.late != 1 {
+		t.Errorf("want the arrival a second ago late, got %v late", o.late)
+	}
+	info := measurement.Info()
+	if queued, late := info[measurement.OpQueue].Get(measurement.COUNT), info[measurement.OpLate].Get(measurement.COUNT); queued != int64(2) || late != int64(1) {
+		t.Errorf("want 2 queueing delays and 1 late arrival measured, got %v and %v", queued, late)
+	}
+	// the operations start when they arrived
+	if !reflect.DeepEqual(workload.intended, arrivals) {
+		t.Errorf("want the intended starts %v, got %v", arrivals, workload.intended)
+	}
+}
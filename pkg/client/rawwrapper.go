@@ -19,21 +19,66 @@ import (
 	"time"
 
 	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
 // RawWrapper stores the pointer to a implementation of ycsb.DB.
+// When Histogram is set every operation is also fed to the latency
+// histograms, so one run produces both the raw series and the summary.
 type RawWrapper struct {
-	DB ycsb.DB
+	DB        ycsb.DB
+	Histogram bool
 }
 
-func rawmeasure(start time.Time, end time.Time, op string, key string, values []interface{}, err error) {
+// rawmeasure records the actual start of the operation whatever the
+// measurement.latency, the history the checker reads needs the real
+// intervals of the operations. The intended latencies are recorded next to
+// them under IntendedPrefix, which the checker skips.
+func rawmeasure(ctx context.Context, start time.Time, end time.Time, op string, key string, values []interface{}, err error) {
 	if err != nil {
-		measurement.RawMeasure(fmt.Sprintf("%s_ERROR", op), start, end, key, values)
-		return
+		op = fmt.Sprintf("%s_ERROR", op)
 	}
 
-	measurement.RawMeasure(op, start, end, key, values)
+	measurement.RawMeasure(ctx, op, start, end, key, values)
+	if intended, ok := measurement.IntendedLatencyStart(ctx, start); ok {
+		measurement.RawMeasure(ctx, measurement.IntendedPrefix+op, intended, end, key, values)
+	}
+}
+
+func (db RawWrapper) measure(ctx context.Context, start time.Time, end time.Time, op string, key string, values []interface{}, err error) {
+	rawmeasure(ctx, start, end, op, key, values, err)
+	if db.Histogram {
+		measure(ctx, start, end, op, key, values, err)
+	}
+}
+
+// measureBatch records one raw operation per key of the batch, while the
+// histogram sees the batch as a single operation like in DbWrapper.
+func (db RawWrapper) measureBatch(ctx context.Context, start time.Time, end time.Time, op string, keys []string, values [][]interface{}, err error) {
+	for i, key := range keys {
+		var tempVals []interface{}
+		if i < len(values) {
+			tempVals = values[i]
+		}
+		rawmeasure(ctx, start, end, op, key, tempVals, err)
+	}
+	if db.Histogram {
+		measure(ctx, start, end, op, "", nil, err)
+	}
+}
+
+// rawValues copies the values sorted by field name, so the recorded values
+// are stable across runs and are not affected by the workload reusing buffers.
+func rawValues(values map[string][]byte) []interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	tempVals := make([]interface{}, 0, len(values))
+	for _, pair := range util.NewFieldPairs(values) {
+		tempVals = append(tempVals, string(pair.Value))
+	}
+	return tempVals
 }
 
 func (db RawWrapper) Close() error {
@@ -41,10 +86,12 @@ func (db RawWrapper) Close() error {
 }
 
 func (db RawWrapper) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
-	return db.DB.InitThread(ctx, threadID, threadCount)
+	ctx = db.DB.InitThread(ctx, threadID, threadCount)
+	return measurement.RawInitThread(ctx, threadID)
 }
 
 func (db RawWrapper) CleanupThread(ctx context.Context) {
+	measurement.RawCleanupThread(ctx)
 	db.DB.CleanupThread(ctx)
 }
 
@@ -52,65 +99,158 @@ func (db RawWrapper) Read(ctx context.Context, table string, key string, fields
 	start := time.Now()
 	dbRead, err := db.DB.Read(ctx, table, key, fields)
 	end := time.Now()
-	var tempVals []interface{}
-	for _, dbVal := range dbRead {
-		tempVals = append(tempVals, dbVal)
-	}
-	rawmeasure(start, end, "READ", key, tempVals, err)
+	db.measure(ctx, start, end, "READ", key, rawValues(dbRead), err)
 
 	return dbRead, err
 }
 
 func (db RawWrapper) BatchRead(ctx context.Context, table string, keys []string, fields []string) (_ []map[string][]byte, err error) {
-	return nil, nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		var res []map[string][]byte
+		for _, key := range keys {
+			values, err := db.Read(ctx, table, key, fields)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, values)
+		}
+		return res, nil
+	}
+
+	start := time.Now()
+	dbRead, err := batchDB.BatchRead(ctx, table, keys, fields)
+	end := time.Now()
+	tempVals := make([][]interface{}, len(dbRead))
+	for i := range dbRead {
+		tempVals[i] = rawValues(dbRead[i])
+	}
+	db.measureBatch(ctx, start, end, "BATCH_READ", keys, tempVals, err)
+
+	return dbRead, err
 }
 
 func (db RawWrapper) Scan(ctx context.Context, table string, startKey string, count int, fields []string) (_ []map[string][]byte, err error) {
-	return nil, nil
+	start := time.Now()
+	dbScan, err := db.DB.Scan(ctx, table, startKey, count, fields)
+	end := time.Now()
+	var tempVals []interface{}
+	for _, row := range dbScan {
+		tempVals = append(tempVals, rawValues(row)...)
+	}
+	db.measure(ctx, start, end, "SCAN", startKey, tempVals, err)
+
+	return dbScan, err
 }
 
 func (db RawWrapper) Update(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
-	var tempVals []interface{}
-	for _, pVal := range values {
-		tempVals = append(tempVals, pVal)
-	}
+	tempVals := rawValues(values)
 
 	start := time.Now()
 	err = db.DB.Update(ctx, table, key, values)
-	rawmeasure(start, time.Now(), "UPDATE", key, tempVals, err)
+	db.measure(ctx, start, time.Now(), "UPDATE", key, tempVals, err)
 	return err
 }
 
 func (db RawWrapper) BatchUpdate(ctx context.Context, table string, keys []string, values []map[string][]byte) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for i := range keys {
+			err = db.Update(ctx, table, keys[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tempVals := make([][]interface{}, len(values))
+	for i := range values {
+		tempVals[i] = rawValues(values[i])
+	}
+
+	start := time.Now()
+	err = batchDB.BatchUpdate(ctx, table, keys, values)
+	end := time.Now()
+	db.measureBatch(ctx, start, end, "BATCH_UPDATE", keys, tempVals, err)
+	return err
 }
 
 func (db RawWrapper) Insert(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
 	start := time.Now()
-	var tempVals []interface{}
-	for _, pVal := range values {
-		tempVals = append(tempVals, pVal)
-	}
+	tempVals := rawValues(values)
 
 	defer func() {
-		rawmeasure(start, time.Now(), "INSERT", key, tempVals, err)
+		db.measure(ctx, start, time.Now(), "INSERT", key, tempVals, err)
 	}()
 
 	return db.DB.Insert(ctx, table, key, values)
 }
 
 func (db RawWrapper) BatchInsert(ctx context.Context, table string, keys []string, values []map[string][]byte) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for i := range keys {
+			err = db.Insert(ctx, table, keys[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tempVals := make([][]interface{}, len(values))
+	for i := range values {
+		tempVals[i] = rawValues(values[i])
+	}
+
+	start := time.Now()
+	err = batchDB.BatchInsert(ctx, table, keys, values)
+	end := time.Now()
+	db.measureBatch(ctx, start, end, "BATCH_INSERT", keys, tempVals, err)
+	return err
 }
 
 func (db RawWrapper) Delete(ctx context.Context, table string, key string) (err error) {
-	return nil
+	start := time.Now()
+
+	defer func() {
+		db.measure(ctx, start, time.Now(), "DELETE", key, nil, err)
+	}()
+
+	return db.DB.Delete(ctx, table, key)
 }
 
 func (db RawWrapper) BatchDelete(ctx context.Context, table string, keys []string) (err error) {
-	return nil
+	batchDB, ok := db.DB.(ycsb.BatchDB)
+	if !ok {
+		for _, key := range keys {
+			err = db.Delete(ctx, table, key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	err = batchDB.BatchDelete(ctx, table, keys)
+	end := time.Now()
+	db.measureBatch(ctx, start, end, "BATCH_DELETE", keys, nil, err)
+	return err
 }
 
-func (db RawWrapper) Analyze(ctx context.Context, table string) error {
-	return nil
+func (db RawWrapper) Analyze(ctx context.Context, table string) (err error) {
+	analyzeDB, ok := db.DB.(ycsb.AnalyzeDB)
+	if !ok {
+		return nil
+	}
+
+	start := time.Now()
+
+	defer func() {
+		db.measure(ctx, start, time.Now(), "ANALYZE", table, nil, err)
+	}()
+
+	return analyzeDB.Analyze(ctx, table)
 }
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/ycsbchecker"
+)
+
+// staleDB keeps the first value written to a key, so its reads go stale
+// after an update
+type staleDB struct {
+	values map[string]map[string][]byte
+}
+
+func (db *staleDB) Close() error { return nil }
+
+func (db *staleDB) InitThread(ctx context.Context, _ int, _ int) context.Context { return ctx }
+
+func (db *staleDB) CleanupThread(_ context.Context) {}
+
+func (db *staleDB) Read(_ context.Context, _ string, key string, _ []string) (map[string][]byte, error) {
+	return db.values[key], nil
+}
+
+func (db *staleDB) Scan(_ context.Context, _ string, _ string, _ int, _ []string) ([]map[string][]byte, error) {
+	return nil, nil
+}
+
+func (db *staleDB) Update(_ context.Context, _ string, _ string, _ map[string][]byte) error {
+	return nil
+}
+
+func (db *staleDB) Insert(_ context.Context, _ string, key string, values map[string][]byte) error {
+	db.values[key] = values
+	return nil
+}
+
+func (db *staleDB) Delete(_ context.Context, _ string, _ string) error { return nil }
+
+func TestIntendedLatencyKeepsCheckableHistory(t *testing.T) {
+	dir := t.TempDir()
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, filepath.Join(dir, "raw"))
+	p.Set(prop.RunID, "test")
+	p.Set(prop.MeasurementType, "raw,histogram")
+	p.Set(prop.MeasurementLatency, measurement.LatencyIntended)
+	measurement.InitMeasure(p)
+	measurement.RawInitMeasure(p)
+
+	db := RawWrapper{DB: &staleDB{values: make(map[string]map[string][]byte)}, Histogram: true}
+	ctx := db.InitThread(context.Background(), 0, 1)
+	intended := new(measurement.IntendedStart)
+	ctx = measurement.WithIntendedStart(ctx, intended)
+
+	// every operation is late, and the read misses the update it follows
+	intended.Set(time.Now().Add(-time.Second))
+	db.Insert(ctx, "usertable", "user1", map[string][]byte{"field0": []byte("v1")})
+	intended.Set(time.Now().Add(-time.Second))
+	db.Update(ctx, "usertable", "user1", map[string][]byte{"field0": []byte("v2")})
+	intended.Set(time.Now().Add(-time.Second))
+	db.Read(ctx, "usertable", "user1", nil)
+	db.CleanupThread(ctx)
+	measurement.RawClose()
+
+	files, err := measurement.RawFiles(p)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("want one raw file, got %v %v", files, err)
+	}
+	h := ycsbchecker.NewHistory()
+	if err = h.ReadFile(files[0].Name, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if anomalies := h.Linearizable(); anomalies != 1 {
+		t.Fatalf("want the stale read found in the history, got %d anomalies", anomalies)
+	}
+
+	info := measurement.Info()
+	if _, ok := info[measurement.IntendedPrefix+"READ"]; !ok {
+		t.Fatalf("want the intended latencies in the histograms, got %v", info)
+	}
+	if _, ok := info["READ"]; ok {
+		t.Fatalf("want no actual latencies in the histograms, got %v", info)
+	}
+}
+
+func TestRawWrapperFeedsHistograms(t *testing.T) {
+	for _, c := range []struct {
+		histogram bool
+		// the histograms see a batch as one operation
+		ops map[string]int64
+	}{
+		{false, map[string]int64{}},
+		{true, map[string]int64{"READ": 1, "BATCH_READ": 1}},
+	} {
+		p := properties.NewProperties()
+		p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "raw"))
+		p.Set(prop.RunID, "test")
+		p.Set(prop.MeasurementType, "raw,histogram")
+		measurement.InitMeasure(p)
+		measurement.RawInitMeasure(p)
+
+		db := RawWrapper{DB: new(callBatchDB), Histogram: c.histogram}
+		ctx := context.Background()
+		db.Read(ctx, "usertable", "user1", nil)
+		db.BatchRead(ctx, "usertable", []string{"user1", "user2"}, nil)
+		measurement.RawClose()
+
+		files, err := measurement.RawFiles(p)
+		if err != nil || len(files) != 1 {
+			t.Fatalf("histogram %v: want one raw file, got %v %v", c.histogram, files, err)
+		}
+		if got, want := rawOps(t, files[0].Name), map[string]int{"READ": 1, "BATCH_READ": 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("histogram %v: want rows %v, got %v", c.histogram, want, got)
+		}
+		ops := make(map[string]int64)
+		for op, info := range measurement.Info() {
+			ops[op] = info.Get(measurement.COUNT).(int64)
+		}
+		if !reflect.DeepEqual(ops, c.ops) {
+			t.Errorf("histogram %v: want operations %v, got %v", c.histogram, c.ops, ops)
+		}
+	}
+}
+
+// rawOps counts the rows of each operation of a raw file
+func rawOps(t *testing.T, name string) map[string]int {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := make(map[string]int)
+	for _, row := range rows[1:] {
+		ops[row[0]]++
+	}
+	return ops
+}
+
+func TestRawLatencyRows(t *testing.T) {
+	for _, c := range []struct {
+		latency string
+		want    map[string]int
+	}{
+		{measurement.LatencyOp, map[string]int{"INSERT": 1, "READ": 1}},
+		{measurement.LatencyIntended, map[string]int{"INSERT": 1, "READ": 1, "Intended-INSERT": 1, "Intended-READ": 1}},
+		{measurement.LatencyBoth, map[string]int{"INSERT": 1, "READ": 1, "Intended-INSERT": 1, "Intended-READ": 1}},
+	} {
+		p := properties.NewProperties()
+		p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "raw"))
+		p.Set(prop.RunID, "test")
+		p.Set(prop.MeasurementLatency, c.latency)
+		measurement.InitMeasure(p)
+		measurement.RawInitMeasure(p)
+
+		db := RawWrapper{DB: &staleDB{values: make(map[string]map[string][]byte)}}
+		ctx := db.InitThread(context.Background(), 0, 1)
+		intended := new(measurement.IntendedStart)
+		ctx = measurement.WithIntendedStart(ctx, intended)
+		intended.Set(time.Now().Add(-time.Second))
+		db.Insert(ctx, "usertable", "user1", map[string][]byte{"field0": []byte("v1")})
+		intended.Set(time.Now().Add(-time.Second))
+		db.Read(ctx, "usertable", "user1", nil)
+		db.CleanupThread(ctx)
+		measurement.RawClose()
+
+		files, err := measurement.RawFiles(p)
+		if err != nil || len(files) != 1 {
+			t.Fatalf("%v: want one raw file, got %v %v", c.latency, files, err)
+		}
+		if got := rawOps(t, files[0].Name); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: want rows %v, got %v", c.latency, c.want, got)
+		}
+	}
+}
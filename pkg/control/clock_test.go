@@ -0,0 +1,85 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeNetwork answers the clock requests of a follower for a leader skew
+// ahead of the follower clock. The follower clock is simulated: each
+// request takes there[i] to reach the leader, which replies after process,
+// and back[i] to return.
+type fakeNetwork struct {
+	now         time.Time
+	skew        time.Duration
+	process     time.Duration
+	there, back []time.Duration
+	requests    int
+}
+
+func (n *fakeNetwork) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		return &http.Response{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized",
+			Body: io.NopCloser(bytes.NewReader(nil)), Request: r}, nil
+	}
+	i := n.requests % len(n.there)
+	n.requests++
+
+	n.now = n.now.Add(n.there[i])
+	receive := n.now.Add(n.skew).UnixNano()
+	n.now = n.now.Add(n.process)
+	send := n.now.Add(n.skew).UnixNano()
+	n.now = n.now.Add(n.back[i])
+
+	body, err := json.Marshal(clockResponse{Receive: receive, Send: send})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK",
+		Body: io.NopCloser(bytes.NewReader(body)), Request: r}, nil
+}
+
+func TestEstimateClock(t *testing.T) {
+	ms := time.Millisecond
+	for _, c := range []struct {
+		name        string
+		skew        time.Duration
+		process     time.Duration
+		there, back []time.Duration
+		offset      time.Duration
+		uncertainty time.Duration
+	}{
+		{name: "ahead", skew: 3 * time.Second, there: []time.Duration{ms}, back: []time.Duration{ms},
+			offset: 3 * time.Second, uncertainty: ms},
+		{name: "behind", skew: -1500 * ms, there: []time.Duration{ms}, back: []time.Duration{ms},
+			offset: -1500 * ms, uncertainty: ms},
+		// the asymmetry of the network is the error of the offset, within the uncertainty
+		{name: "asymmetric network", skew: time.Second, there: []time.Duration{10 * ms}, back: []time.Duration{2 * ms},
+			offset: time.Second + 4*ms, uncertainty: 6 * ms},
+		{name: "processing is not uncertain", skew: time.Second, process: 50 * ms,
+			there: []time.Duration{ms}, back: []time.Duration{ms}, offset: time.Second, uncertainty: ms},
+		{name: "shortest round trip", skew: -time.Second,
+			there: []time.Duration{100 * ms, 3 * ms, 2 * ms, 5 * ms}, back: []time.Duration{ms, 3 * ms, 2 * ms, ms},
+			offset: -time.Second, uncertainty: 2 * ms},
+	} {
+		n := &fakeNetwork{now: time.Unix(1700000000, 0), skew: c.skew, process: c.process, there: c.there, back: c.back}
+		f := newTestFollower("leader", "a")
+		f.client = &http.Client{Transport: n}
+		f.now = func() time.Time { return n.now }
+		f.clockSamples = 4
+		if err := f.estimateClock(context.Background()); err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if n.requests != 4 {
+			t.Errorf("%v: want 4 samples, got %v", c.name, n.requests)
+		}
+		if offset, uncertainty := f.Clock(); offset != c.offset || uncertainty != c.uncertainty {
+			t.Errorf("%v: want an offset of %v ± %v, got %v ± %v", c.name, c.offset, c.uncertainty, offset, uncertainty)
+		}
+	}
+}
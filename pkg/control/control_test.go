@@ -0,0 +1,282 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+const testToken = "secret"
+
+// startLeader serves the control channel of a new leader on a test server
+func startLeader(t *testing.T, p *properties.Properties) (*Leader, string) {
+	t.Helper()
+	p.Set(prop.ControlToken, testToken)
+	l := newLeader(p)
+	srv := httptest.NewServer(l.handler())
+	go l.watchHeartbeats()
+	t.Cleanup(func() {
+		close(l.closed)
+		srv.Close()
+	})
+	return l, srv.Listener.Addr().String()
+}
+
+func newTestFollower(addr, id string) *Follower {
+	p := properties.NewProperties()
+	p.Set(prop.ControlLeader, addr)
+	p.Set(prop.ControlToken, testToken)
+	p.Set(prop.FollowerName, id)
+	p.Set(prop.ControlHeartbeat, "20")
+	p.Set(prop.ControlHeartbeatTimeout, "1")
+	p.Set(prop.ControlClockSamples, "2")
+	return NewFollower(p)
+}
+
+func followerStateOf(l *Leader, id string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.followers[id].state
+}
+
+// waitState waits for the leader to see the follower in the state
+func waitState(t *testing.T, l *Leader, id, state string, timeout time.Duration) {
+	t.Helper()
+	if !l.wait(context.Background(), timeout, func() bool { return l.followers[id].state == state }) {
+		t.Fatalf("follower %v: want state %v, got %v", id, state, followerStateOf(l, id))
+	}
+}
+
+func TestBarrierHeartbeatsAndDone(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.ControlStartDelay, "100")
+	l, addr := startLeader(t, p)
+	// c is launched but never becomes ready
+	l.Expect("a", "b", "c")
+
+	followers := map[string]*Follower{"a": newTestFollower(addr, "a"), "b": newTestFollower(addr, "b")}
+	starts := make(map[string]time.Time)
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for id, f := range followers {
+		wg.Add(1)
+		go func(id string, f *Follower) {
+			defer wg.Done()
+			start, err := f.Ready(context.Background())
+			mu.Lock()
+			starts[id], errs[id] = start, err
+			mu.Unlock()
+		}(id, f)
+	}
+	for id := range followers {
+		waitState(t, l, id, StateReady, 5*time.Second)
+	}
+
+	start, err := l.Barrier(context.Background(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("barrier: %v", err)
+	}
+	wg.Wait()
+	for id, f := range followers {
+		if errs[id] != nil {
+			t.Fatalf("follower %v ready: %v", id, errs[id])
+		}
+		// the follower converts the start time to its clock
+		offset, uncertainty := f.Clock()
+		if d := starts[id].Add(offset).Sub(start); d < -uncertainty-time.Millisecond || d > uncertainty+time.Millisecond {
+			t.Errorf("follower %v: want start %v, got %v", id, start, starts[id])
+		}
+		if got := followerStateOf(l, id); got != StateRunning {
+			t.Errorf("follower %v: want state %v, got %v", id, StateRunning, got)
+		}
+	}
+	if got := followerStateOf(l, "c"); got != StateMissed {
+		t.Errorf("follower c: want state %v, got %v", StateMissed, got)
+	}
+	if _, err = newTestFollower(addr, "c").Ready(context.Background()); err == nil || !strings.Contains(err.Error(), "already open") {
+		t.Errorf("follower c ready after the barrier: want the barrier open error, got %v", err)
+	}
+	if _, err = newTestFollower(addr, "d").Ready(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown follower") {
+		t.Errorf("unknown follower ready: want an unknown follower error, got %v", err)
+	}
+
+	// the leader stops the runs at their next heartbeat
+	runs := make(map[string]context.Context)
+	for id, f := range followers {
+		runs[id] = f.Run(context.Background())
+	}
+	l.Stop()
+	for id, ctx := range runs {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("follower %v: run not stopped by the leader", id)
+		}
+	}
+
+	if err = followers["a"].Done(nil); err != nil {
+		t.Fatalf("follower a done: %v", err)
+	}
+	if err = followers["b"].Done(errors.New("run failed")); err != nil {
+		t.Fatalf("follower b done: %v", err)
+	}
+	ids := l.WaitDone(context.Background(), 5*time.Second)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("want finished followers %v, got %v", want, ids)
+	}
+	for id, want := range map[string]string{"a": StateDone, "b": StateFailed, "c": StateMissed} {
+		if got := followerStateOf(l, id); got != want {
+			t.Errorf("follower %v: want state %v, got %v", id, want, got)
+		}
+	}
+	l.mu.Lock()
+	if got := l.followers["b"].err; got != "run failed" {
+		t.Errorf("follower b: want error %q, got %q", "run failed", got)
+	}
+	l.mu.Unlock()
+}
+
+func TestBarrierTimesOutWithoutFollowers(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.ControlStartDelay, "0")
+	l, _ := startLeader(t, p)
+	l.Expect("a")
+
+	begin := time.Now()
+	if _, err := l.Barrier(context.Background(), 100*time.Millisecond); err != nil {
+		t.Fatalf("barrier: %v", err)
+	}
+	if d := time.Since(begin); d < 100*time.Millisecond {
+		t.Errorf("barrier opened after %v, before its timeout", d)
+	}
+	if got := followerStateOf(l, "a"); got != StateMissed {
+		t.Errorf("want state %v, got %v", StateMissed, got)
+	}
+	if ids := l.WaitDone(context.Background(), time.Second); len(ids) != 0 {
+		t.Errorf("want no finished follower, got %v", ids)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l2, _ := startLeader(t, p)
+	l2.Expect("a")
+	if _, err := l2.Barrier(ctx, time.Minute); err != context.Canceled {
+		t.Errorf("canceled barrier: want %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.ControlStartDelay, "0")
+	p.Set(prop.ControlHeartbeatTimeout, "1")
+	l, addr := startLeader(t, p)
+	l.Expect("a")
+
+	f := newTestFollower(addr, "a")
+	ready := make(chan error, 1)
+	go func() {
+		_, err := f.Ready(context.Background())
+		ready <- err
+	}()
+	waitState(t, l, "a", StateReady, 5*time.Second)
+	if _, err := l.Barrier(context.Background(), time.Second); err != nil {
+		t.Fatalf("barrier: %v", err)
+	}
+	if err := <-ready; err != nil {
+		t.Fatalf("ready: %v", err)
+	}
+
+	// without heartbeats the follower is lost, and is back with the next one
+	waitState(t, l, "a", StateLost, 5*time.Second)
+	ctx := f.Run(context.Background())
+	waitState(t, l, "a", StateRunning, 5*time.Second)
+	if ctx.Err() != nil {
+		t.Errorf("run stopped while the leader is alive: %v", ctx.Err())
+	}
+	if err := f.Done(nil); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	waitState(t, l, "a", StateDone, 5*time.Second)
+}
+
+func TestFollowerLosesLeader(t *testing.T) {
+	srv := httptest.NewServer(newLeader(properties.NewProperties()).handler())
+	f := newTestFollower(srv.Listener.Addr().String(), "a")
+	srv.Close()
+
+	begin := time.Now()
+	ctx := f.Run(context.Background())
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("run not stopped after the leader was lost")
+	}
+	if d := time.Since(begin); d < f.heartbeatTimeout {
+		t.Errorf("run stopped after %v, before the heartbeat timeout %v", d, f.heartbeatTimeout)
+	}
+	if err := f.Done(nil); err == nil {
+		t.Error("done without a leader: want an error")
+	}
+}
+
+func TestLeaderChecksToken(t *testing.T) {
+	l, addr := startLeader(t, properties.NewProperties())
+	l.Expect("a")
+
+	for _, token := range []string{"", "wrong", testToken + "x"} {
+		f := newTestFollower(addr, "a")
+		f.token = token
+		if _, err := f.Ready(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("token %q: want the leader to refuse the follower, got %v", token, err)
+		}
+	}
+	if got := followerStateOf(l, "a"); got != StateLaunched {
+		t.Errorf("want the follower still %v, got %v", StateLaunched, got)
+	}
+
+	// without a token of its own the leader refuses every follower
+	srv := httptest.NewServer(newLeader(properties.NewProperties()).handler())
+	defer srv.Close()
+	f := newTestFollower(srv.Listener.Addr().String(), "a")
+	f.token = ""
+	if err := f.estimateClock(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("leader without token: want the follower refused, got %v", err)
+	}
+}
+
+func TestNewLeaderDrawsToken(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.ControlListen, "127.0.0.1:0")
+	tokens := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		l, err := NewLeader(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+		if len(l.Token()) != 32 || tokens[l.Token()] {
+			t.Errorf("want a new token for each run, got %q", l.Token())
+		}
+		tokens[l.Token()] = true
+	}
+
+	p.Set(prop.ControlToken, testToken)
+	l, err := NewLeader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if l.Token() != testToken {
+		t.Errorf("want the token of control.token, got %q", l.Token())
+	}
+}
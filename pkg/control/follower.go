@@ -0,0 +1,181 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Follower is the side of the control channel of a follower run: it waits
+// at the start barrier of the leader, sends heartbeats while running, stops
+// when the leader says so or is lost, and reports its completion.
+type Follower struct {
+	id               string
+	url              string
+	token            string
+	client           *http.Client
+	heartbeat        time.Duration
+	heartbeatTimeout time.Duration
+	clockSamples     int
+	// now reads the follower clock
+	now func() time.Time
+
+	// offset is the estimated leader clock minus the follower clock, and
+	// uncertainty the bound of its error
+	offset      time.Duration
+	uncertainty time.Duration
+
+	stopHeartbeats context.CancelFunc
+	heartbeats     sync.WaitGroup
+}
+
+// NewFollower returns the follower side of the channel to the leader at control.leader
+func NewFollower(p *properties.Properties) *Follower {
+	f := &Follower{
+		id:               p.GetString(prop.FollowerName, ""),
+		url:              "http://" + p.GetString(prop.ControlLeader, ""),
+		token:            p.GetString(prop.ControlToken, ""),
+		client:           &http.Client{},
+		heartbeat:        time.Duration(p.GetInt64(prop.ControlHeartbeat, prop.ControlHeartbeatDefault)) * time.Millisecond,
+		heartbeatTimeout: time.Duration(p.GetInt64(prop.ControlHeartbeatTimeout, prop.ControlHeartbeatTimeoutDefault)) * time.Second,
+		clockSamples:     p.GetInt(prop.ControlClockSamples, prop.ControlClockSamplesDefault),
+		now:              time.Now,
+	}
+	if f.heartbeat <= 0 {
+		f.heartbeat = time.Duration(prop.ControlHeartbeatDefault) * time.Millisecond
+	}
+	if f.clockSamples <= 0 {
+		f.clockSamples = 1
+	}
+	return f
+}
+
+// estimateClock exchanges timestamps with the leader NTP-style and keeps the
+// estimate of the exchange with the shortest round trip: the offset is the
+// mean of the differences of the clocks on the way there and back, and the
+// uncertainty half the round trip spent on the network
+func (f *Follower) estimateClock(ctx context.Context) error {
+	best := time.Duration(-1)
+	for i := 0; i < f.clockSamples; i++ {
+		var resp clockResponse
+		t0 := f.now()
+		if err := post(ctx, f.client, f.url+pathClock, f.token, clockRequest{Id: f.id}, &resp); err != nil {
+			return err
+		}
+		t3 := f.now()
+
+		delay := t3.Sub(t0) - time.Duration(resp.Send-resp.Receive)
+		if delay < 0 {
+			delay = 0
+		}
+		if best < 0 || delay < best {
+			best = delay
+			f.offset = time.Duration((resp.Receive - t0.UnixNano() + resp.Send - t3.UnixNano()) / 2)
+			f.uncertainty = delay / 2
+		}
+	}
+	return nil
+}
+
+// Clock returns the estimated offset of the leader clock to the follower
+// clock and the bound of its error
+func (f *Follower) Clock() (time.Duration, time.Duration) {
+	return f.offset, f.uncertainty
+}
+
+// Ready estimates the clock offset to the leader, tells the leader the
+// follower is ready to run and waits at the barrier, and returns the start
+// time shared by the runs, in the follower clock
+func (f *Follower) Ready(ctx context.Context) (time.Time, error) {
+	if f.id == "" {
+		return time.Time{}, errors.New("a follower needs its name, see -F")
+	}
+	if err := f.estimateClock(ctx); err != nil {
+		return time.Time{}, err
+	}
+	fmt.Printf("Clock offset to the leader %v ± %v\n", f.offset, f.uncertainty)
+
+	var resp readyResponse
+	req := readyRequest{Id: f.id, Offset: int64(f.offset), Uncertainty: int64(f.uncertainty)}
+	if err := post(ctx, f.client, f.url+pathReady, f.token, req, &resp); err != nil {
+		return time.Time{}, err
+	}
+	if resp.Error != "" {
+		return time.Time{}, errors.New(resp.Error)
+	}
+	return time.Unix(0, resp.Start).Add(-f.offset), nil
+}
+
+// Run sends the heartbeats until Done, and returns the context of the run,
+// canceled when the leader tells the follower to stop or is lost
+func (f *Follower) Run(ctx context.Context) context.Context {
+	runCtx, cancel := context.WithCancel(ctx)
+	heartbeatCtx, stop := context.WithCancel(context.Background())
+	f.stopHeartbeats = stop
+
+	f.heartbeats.Add(1)
+	go func() {
+		defer f.heartbeats.Done()
+		ticker := time.NewTicker(f.heartbeat)
+		defer ticker.Stop()
+
+		lastContact := time.Now()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var resp heartbeatResponse
+			reqCtx, reqCancel := context.WithTimeout(heartbeatCtx, f.heartbeat)
+			err := post(reqCtx, f.client, f.url+pathHeartbeat, f.token,
+				heartbeatRequest{Id: f.id, Operations: measurement.Operations()}, &resp)
+			reqCancel()
+			switch {
+			case err == nil:
+				lastContact = time.Now()
+				if resp.Stop {
+					if runCtx.Err() == nil {
+						fmt.Println("Leader stopped the run")
+					}
+					cancel()
+				}
+			case heartbeatCtx.Err() != nil:
+				return
+			case time.Since(lastContact) > f.heartbeatTimeout:
+				if runCtx.Err() == nil {
+					log.Printf("Lost the leader for %v, stopping the run: %v", f.heartbeatTimeout, err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return runCtx
+}
+
+// Done stops the heartbeats and reports the completion of the run to the
+// leader, with the error of the run if it failed
+func (f *Follower) Done(runErr error) error {
+	if f.stopHeartbeats != nil {
+		f.stopHeartbeats()
+		f.heartbeats.Wait()
+	}
+
+	req := doneRequest{Id: f.id, Operations: measurement.Operations()}
+	if runErr != nil {
+		req.Error = runErr.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.heartbeatTimeout)
+	defer cancel()
+	return post(ctx, f.client, f.url+pathDone, f.token, req, nil)
+}
@@ -0,0 +1,463 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+var followerReportHeader = []string{"Follower", "State", "Operations", "LastSeen", "Offset", "Uncertainty", "Error"}
+
+// followerState is what the leader knows of a follower
+type followerState struct {
+	state      string
+	operations int64
+	lastSeen   time.Time
+	err        string
+	// offset is the estimated leader clock minus the follower clock, and
+	// uncertainty the bound of its error
+	offset      time.Duration
+	uncertainty time.Duration
+}
+
+// finished returns whether the follower is not expected to report anymore
+func (f *followerState) finished() bool {
+	switch f.state {
+	case StateDone, StateFailed, StateMissed, StateLost:
+		return true
+	}
+	return false
+}
+
+// Leader serves the control channel of the followers: it holds them at the
+// start barrier, hands out the shared start time, watches their heartbeats,
+// tells them to stop and collects their completion.
+type Leader struct {
+	listener         net.Listener
+	server           *http.Server
+	token            string
+	startDelay       time.Duration
+	heartbeatTimeout time.Duration
+
+	mu        sync.Mutex
+	followers map[string]*followerState
+	start     time.Time
+	stopped   bool
+	// changed is closed and replaced whenever a follower changes state
+	changed chan struct{}
+	// released is closed when the barrier opens
+	released chan struct{}
+	closed   chan struct{}
+}
+
+// NewLeader listens on control.listen and serves the control channel to
+// the followers sending control.token, or a token drawn for the run
+func NewLeader(p *properties.Properties) (*Leader, error) {
+	l := newLeader(p)
+	if l.token == "" {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		l.token = hex.EncodeToString(secret)
+	}
+
+	listener, err := net.Listen("tcp", p.GetString(prop.ControlListen, prop.ControlListenDefault))
+	if err != nil {
+		return nil, err
+	}
+	l.listener = listener
+	l.server = &http.Server{Handler: l.handler()}
+
+	go l.server.Serve(listener)
+	go l.watchHeartbeats()
+	return l, nil
+}
+
+// newLeader returns the leader state, without serving it
+func newLeader(p *properties.Properties) *Leader {
+	l := &Leader{
+		token:            p.GetString(prop.ControlToken, ""),
+		startDelay:       time.Duration(p.GetInt64(prop.ControlStartDelay, prop.ControlStartDelayDefault)) * time.Millisecond,
+		heartbeatTimeout: time.Duration(p.GetInt64(prop.ControlHeartbeatTimeout, prop.ControlHeartbeatTimeoutDefault)) * time.Second,
+		followers:        make(map[string]*followerState),
+		changed:          make(chan struct{}),
+		released:         make(chan struct{}),
+		closed:           make(chan struct{}),
+	}
+	if l.heartbeatTimeout <= 0 {
+		l.heartbeatTimeout = time.Duration(prop.ControlHeartbeatTimeoutDefault) * time.Second
+	}
+	return l
+}
+
+// handler returns the handler of the control channel endpoints
+func (l *Leader) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathClock, l.handleClock)
+	mux.HandleFunc(pathReady, l.handleReady)
+	mux.HandleFunc(pathHeartbeat, l.handleHeartbeat)
+	mux.HandleFunc(pathDone, l.handleDone)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.authorized(r) {
+			http.Error(w, "bad or missing control token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized returns whether the request carries the token of the run
+func (l *Leader) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return l.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(l.token)) == 1
+}
+
+// Token returns the token the followers have to send, control.token
+func (l *Leader) Token() string {
+	return l.token
+}
+
+// Port returns the port the control channel listens on
+func (l *Leader) Port() int {
+	return l.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Expect registers the followers which were launched and have to join the barrier
+func (l *Leader) Expect(ids ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		l.followers[id] = &followerState{state: StateLaunched}
+	}
+	l.notifyLocked()
+}
+
+// notifyLocked wakes up the waiters for a change of state, l.mu held
+func (l *Leader) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// setStateLocked changes the state of the follower, l.mu held
+func (l *Leader) setStateLocked(id string, f *followerState, state string) {
+	if f.state == state {
+		return
+	}
+	fmt.Printf("Follower %v %v\n", id, state)
+	f.state = state
+	measurement.RecordFollowerState(id, state == StateReady || state == StateRunning)
+	l.notifyLocked()
+}
+
+// wait waits until cond holds, the timeout expires or the context is done,
+// and returns whether cond holds. l.mu is held when cond is called.
+func (l *Leader) wait(ctx context.Context, timeout time.Duration, cond func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		l.mu.Lock()
+		ok := cond()
+		changed := l.changed
+		l.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Barrier waits until every expected follower is ready to run, the timeout
+// expires or the context is done. It then opens the barrier and returns the
+// start time shared with the followers ready by then. The followers which
+// were not are missed and told not to run.
+func (l *Leader) Barrier(ctx context.Context, timeout time.Duration) (time.Time, error) {
+	allReady := func() bool {
+		for _, f := range l.followers {
+			if f.state != StateReady {
+				return false
+			}
+		}
+		return true
+	}
+	ok := l.wait(ctx, timeout, allReady)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ready := 0
+	for id, f := range l.followers {
+		if f.state == StateReady {
+			ready++
+		} else {
+			f.err = "not ready at the start barrier"
+			l.setStateLocked(id, f, StateMissed)
+		}
+	}
+	l.start = time.Now().Add(l.startDelay)
+	close(l.released)
+
+	if ctx.Err() != nil {
+		return l.start, ctx.Err()
+	}
+	if !ok {
+		log.Printf("Start barrier timed out after %v, %v of %v followers ready", timeout, ready, len(l.followers))
+	}
+	return l.start, nil
+}
+
+// Stop tells the followers to stop their run at their next heartbeat
+func (l *Leader) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+}
+
+// WaitDone waits until every follower which passed the barrier reported its
+// completion or was lost, the timeout expires or the context is done, and
+// returns the ids of the followers which finished their run, failed or not.
+func (l *Leader) WaitDone(ctx context.Context, timeout time.Duration) []string {
+	if !l.wait(ctx, timeout, func() bool {
+		for _, f := range l.followers {
+			if !f.finished() {
+				return false
+			}
+		}
+		return true
+	}) {
+		log.Printf("Followers did not all report their completion within %v", timeout)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ids []string
+	for id, f := range l.followers {
+		if f.state == StateDone || f.state == StateFailed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Report prints the final state of every follower
+func (l *Leader) Report(outputStyle string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := make([]string, 0, len(l.followers))
+	for id := range l.followers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	lines := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		f := l.followers[id]
+		lastSeen := ""
+		if !f.lastSeen.IsZero() {
+			lastSeen = f.lastSeen.Format(time.RFC3339)
+		}
+		offset, uncertainty := "", ""
+		if f.state != StateLaunched && f.state != StateMissed {
+			offset, uncertainty = f.offset.String(), f.uncertainty.String()
+		}
+		lines = append(lines, []string{id, f.state, strconv.FormatInt(f.operations, 10), lastSeen, offset, uncertainty, f.err})
+	}
+
+	fmt.Println("Followers:")
+	switch outputStyle {
+	case util.OutputStyleJson:
+		util.RenderJson(followerReportHeader, lines)
+	case util.OutputStyleTable:
+		util.RenderTable(followerReportHeader, lines)
+	default:
+		util.RenderString("%-10s - %s\n", followerReportHeader, lines)
+	}
+}
+
+// Clock returns the clock offset of the follower, which is added to its
+// timestamps to get the leader clock, and the bound of the error of the offset
+func (l *Leader) Clock(id string) (time.Duration, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f := l.followers[id]; f != nil {
+		return f.offset, f.uncertainty
+	}
+	return 0, 0
+}
+
+// Close stops serving the control channel
+func (l *Leader) Close() error {
+	close(l.closed)
+	return l.server.Close()
+}
+
+// watchHeartbeats marks the followers whose heartbeats stopped after the
+// barrier opened as lost
+func (l *Leader) watchHeartbeats() {
+	ticker := time.NewTicker(l.heartbeatTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.closed:
+			return
+		case <-ticker.C:
+		}
+
+		select {
+		case <-l.released:
+		default:
+			continue
+		}
+		l.mu.Lock()
+		for id, f := range l.followers {
+			alive := f.state == StateReady || f.state == StateRunning
+			if alive && time.Since(f.lastSeen) > l.heartbeatTimeout {
+				log.Printf("Follower %v sent no heartbeat for %v", id, l.heartbeatTimeout)
+				l.setStateLocked(id, f, StateLost)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// followerLocked returns the state of the follower of the request, nil and
+// an error reply if it is unknown, l.mu held
+func (l *Leader) followerLocked(w http.ResponseWriter, id string) *followerState {
+	f := l.followers[id]
+	if f == nil {
+		http.Error(w, fmt.Sprintf("unknown follower %q", id), http.StatusNotFound)
+	}
+	return f
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (l *Leader) handleClock(w http.ResponseWriter, r *http.Request) {
+	receive := time.Now().UnixNano()
+	var req clockRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	reply(w, clockResponse{Receive: receive, Send: time.Now().UnixNano()})
+}
+
+func (l *Leader) handleReady(w http.ResponseWriter, r *http.Request) {
+	var req readyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	l.mu.Lock()
+	f := l.followerLocked(w, req.Id)
+	if f == nil {
+		l.mu.Unlock()
+		return
+	}
+	select {
+	case <-l.released:
+		l.mu.Unlock()
+		reply(w, readyResponse{Error: "the start barrier is already open"})
+		return
+	default:
+	}
+	f.lastSeen = time.Now()
+	f.offset = time.Duration(req.Offset)
+	f.uncertainty = time.Duration(req.Uncertainty)
+	l.setStateLocked(req.Id, f, StateReady)
+	l.mu.Unlock()
+
+	select {
+	case <-l.released:
+	case <-r.Context().Done():
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f.state == StateMissed {
+		reply(w, readyResponse{Error: f.err})
+		return
+	}
+	l.setStateLocked(req.Id, f, StateRunning)
+	f.lastSeen = time.Now()
+	reply(w, readyResponse{Start: l.start.UnixNano()})
+}
+
+func (l *Leader) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req heartbeatRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f := l.followerLocked(w, req.Id)
+	if f == nil {
+		return
+	}
+	f.lastSeen = time.Now()
+	f.operations = req.Operations
+	if f.state == StateLost {
+		log.Printf("Follower %v is back", req.Id)
+		l.setStateLocked(req.Id, f, StateRunning)
+	}
+	reply(w, heartbeatResponse{Stop: l.stopped})
+}
+
+func (l *Leader) handleDone(w http.ResponseWriter, r *http.Request) {
+	var req doneRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f := l.followerLocked(w, req.Id)
+	if f == nil {
+		return
+	}
+	f.lastSeen = time.Now()
+	f.operations = req.Operations
+	if req.Error != "" {
+		f.err = req.Error
+		l.setStateLocked(req.Id, f, StateFailed)
+	} else {
+		l.setStateLocked(req.Id, f, StateDone)
+	}
+	reply(w, struct{}{})
+}
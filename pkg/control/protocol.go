@@ -0,0 +1,121 @@
+// Package control is the channel between the leader of a distributed run
+// and its followers. The leader serves it over HTTP, the followers call it:
+//
+//	POST /clock      returns the leader clock, for the follower to estimate its
+//	                 offset to the leader NTP-style before it is ready
+//	POST /ready      the follower is ready to run, blocks until every follower
+//	                 is, and returns the shared start time (barrier)
+//	POST /heartbeat  the follower is alive, the reply tells it to stop when
+//	                 the leader run is canceled
+//	POST /done       the follower run finished and its files are written
+//
+// Every request carries the token of the run as a bearer token.
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	pathClock     = "/clock"
+	pathReady     = "/ready"
+	pathHeartbeat = "/heartbeat"
+	pathDone      = "/done"
+)
+
+// Follower states reported by the leader
+const (
+	StateLaunched = "launched"
+	StateReady    = "ready"
+	StateRunning  = "running"
+	StateLost     = "lost"
+	StateDone     = "done"
+	StateFailed   = "failed"
+	StateMissed   = "missed"
+)
+
+type clockRequest struct {
+	Id string `json:"id"`
+}
+
+type clockResponse struct {
+	// Receive and Send are the times the leader received the request and
+	// sent the reply, in Unix nanoseconds of the leader clock
+	Receive int64 `json:"receive"`
+	Send    int64 `json:"send"`
+}
+
+type readyRequest struct {
+	Id string `json:"id"`
+	// Offset is the estimated leader clock minus the follower clock, and
+	// Uncertainty the bound of its error, in nanoseconds
+	Offset      int64 `json:"offset"`
+	Uncertainty int64 `json:"uncertainty"`
+}
+
+type readyResponse struct {
+	// Start is the time the runs start at, in Unix nanoseconds of the leader clock
+	Start int64  `json:"start"`
+	Error string `json:"error,omitempty"`
+}
+
+type heartbeatRequest struct {
+	Id         string `json:"id"`
+	Operations int64  `json:"operations"`
+}
+
+type heartbeatResponse struct {
+	Stop bool `json:"stop"`
+}
+
+type doneRequest struct {
+	Id         string `json:"id"`
+	Operations int64  `json:"operations"`
+	Error      string `json:"error,omitempty"`
+}
+
+// post sends the request as JSON to the leader and decodes its JSON reply
+func post(ctx context.Context, client *http.Client, url string, token string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%v: %v %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// SleepUntil waits until the time, and returns false if the context is done first
+func SleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// summarySuffix ends the name of the histogram summary file of a run, and
+// clocksSuffix the name of the clock offsets file of the cluster
+const (
+	summarySuffix = "_histograms.json"
+	clocksSuffix  = "_clocks.csv"
+)
+
+var clocksHeader = []string{"Follower", "Offset(ns)", "Uncertainty(ns)"}
+
+// runSummary is the histogram summary file a run of a cluster writes next to
+// its raw output files, for the leader to merge
+type runSummary struct {
+	Follower   string                      `json:"follower"`
+	RunID      string                      `json:"runid"`
+	Operations map[string]operationSummary `json:"operations"`
+}
+
+type operationSummary struct {
+	// Elapsed is the number of seconds the operation was measured for
+	Elapsed float64 `json:"elapsed"`
+	// Histogram is the cumulative latency histogram in microseconds, in the
+	// V2 compressed HdrHistogram encoding
+	Histogram []byte `json:"histogram"`
+	// Intervals is the count of operations of every measurement.interval
+	Intervals []int64 `json:"intervals"`
+}
+
+// RunFilePrefixes returns the prefixes of the files the run of the follower
+// writes: the raw output and histogram summary, and the timeline if enabled
+func RunFilePrefixes(p *properties.Properties, follower string) []string {
+	prefixes := []string{rawFilePrefix(p, follower, p.GetString(prop.RunID, ""))}
+	if timeline := p.GetString(prop.Timeline, ""); timeline != "" {
+		prefixes = append(prefixes, fmt.Sprintf("%v_%v_%v", timeline, follower, p.GetString(prop.RunID, "")))
+	}
+	return prefixes
+}
+
+// RawFile is a raw output file of a run of the cluster. The timestamps of a
+// follower run are in its clock: Offset is added to them to get the leader
+// clock, and Uncertainty bounds the error of the offset.
+type RawFile struct {
+	Name        string
+	Offset      time.Duration
+	Uncertainty time.Duration
+}
+
+// RawFiles returns the raw output files of the run, in interval order
+func RawFiles(p *properties.Properties) ([]RawFile, error) {
+	names, err := rawFiles(RawFilePrefix(p))
+	files := make([]RawFile, 0, len(names))
+	for _, name := range names {
+		files = append(files, RawFile{Name: name})
+	}
+	return files, err
+}
+
+func rawFiles(prefix string) ([]string, error) {
+	files, err := filepath.Glob(prefix + "_[0-9][0-9][0-9][0-9].csv")
+	sort.Strings(files)
+	return files, err
+}
+
+// WriteRunSummary writes the cumulative histograms and the interval counts
+// of the run to <csvfilename>_<follower>_<runid>_histograms.json
+func WriteRunSummary(p *properties.Properties) error {
+	if globalMeasure == nil {
+		return errors.New("no histograms were collected")
+	}
+	m := globalMeasure
+
+	summary := runSummary{
+		Follower:   p.GetString(prop.FollowerName, "primary"),
+		RunID:      p.GetString(prop.RunID, ""),
+		Operations: make(map[string]operationSummary),
+	}
+	m.RLock()
+	for op, opM := range m.opMeasurement {
+		total, elapsed := opM.total()
+		encoded, err := total.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			m.RUnlock()
+			return err
+		}
+		m.countsLock.Lock()
+		intervals := append([]int64(nil), m.intervalCounts[op]...)
+		m.countsLock.Unlock()
+		// the operations since the last interval output end the run
+		rest := total.TotalCount()
+		for _, count := range intervals {
+			rest -= count
+		}
+		if rest > 0 {
+			intervals = append(intervals, rest)
+		}
+		summary.Operations[op] = operationSummary{Elapsed: elapsed, Histogram: encoded, Intervals: intervals}
+	}
+	m.RUnlock()
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(RawFilePrefix(p)+summarySuffix, data, 0644)
+}
+
+// clusterRun is what the report knows of the run of the leader or of a follower
+type clusterRun struct {
+	name        string
+	offset      time.Duration
+	uncertainty time.Duration
+	rawFiles    []string
+	ops         map[string]*hdrhistogram.Histogram
+	elapsed     map[string]float64
+	intervals   map[int]int64
+}
+
+// ClusterReport merges the measurements of the runs of the leader and of its
+// followers into one report of the cluster
+type ClusterReport struct {
+	p        *properties.Properties
+	start    time.Time
+	interval time.Duration
+	runs     []*clusterRun
+}
+
+// NewClusterReport returns an empty report of the runs which started at start
+func NewClusterReport(p *properties.Properties, start time.Time) *ClusterReport {
+	interval := time.Duration(p.GetInt64(prop.LogInterval, 10)) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &ClusterReport{p: p, start: start, interval: interval}
+}
+
+// Add reads the files of the run of the follower, "primary" for the leader,
+// whose clock is offset from the leader clock, give or take the uncertainty.
+// The histograms and interval counts come from its histogram summary, or
+// from its raw output files when it has no summary.
+func (r *ClusterReport) Add(follower string, offset, uncertainty time.Duration) error {
+	prefix := rawFilePrefix(r.p, follower, r.p.GetString(prop.RunID, ""))
+	files, err := rawFiles(prefix)
+	if err != nil {
+		return err
+	}
+	run := &clusterRun{
+		name:        follower,
+		offset:      offset,
+		uncertainty: uncertainty,
+		rawFiles:    files,
+		ops:         make(map[string]*hdrhistogram.Histogram),
+		elapsed:     make(map[string]float64),
+		intervals:   make(map[int]int64),
+	}
+
+	data, err := os.ReadFile(prefix + summarySuffix)
+	switch {
+	case err == nil:
+		err = run.readSummary(data)
+	case os.IsNotExist(err) && len(files) > 0:
+		err = run.readRaw(r.start, r.interval)
+	case os.IsNotExist(err):
+		err = fmt.Errorf("no measurements of follower %v found, expected %v", follower, prefix+summarySuffix)
+	}
+	if err != nil {
+		return err
+	}
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (run *clusterRun) readSummary(data []byte) error {
+	var summary runSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return err
+	}
+	for op, opSummary := range summary.Operations {
+		hist, err := hdrhistogram.Decode(opSummary.Histogram)
+		if err != nil {
+			return fmt.Errorf("histogram of %v: %v", op, err)
+		}
+		run.ops[op] = hist
+		run.elapsed[op] = opSummary.Elapsed
+		if strings.HasPrefix(op, IntendedPrefix) {
+			continue
+		}
+		for i, count := range opSummary.Intervals {
+			run.intervals[i] += count
+		}
+	}
+	return nil
+}
+
+// readRaw builds the histograms from the operations of the raw files, and
+// counts the operations in the intervals of the start time they ended in,
+// in the leader clock
+func (run *clusterRun) readRaw(start time.Time, interval time.Duration) error {
+	for _, file := range run.rawFiles {
+		if err := run.readRawFile(file, start, interval); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+	}
+	return nil
+}
+
+func (run *clusterRun) readRawFile(file string, start time.Time, interval time.Duration) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 3 || record[0] == seriesheader[0] {
+			continue
+		}
+		opStart, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		opEnd, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		op := record[0]
+		hist, ok := run.ops[op]
+		if !ok {
+			hist = newHDRHistogram()
+			run.ops[op] = hist
+		}
+		hist.RecordValue((opEnd - opStart) / int64(time.Microsecond))
+		end := time.Unix(0, opEnd).Add(run.offset).Sub(start)
+		if end.Seconds() > run.elapsed[op] {
+			run.elapsed[op] = end.Seconds()
+		}
+		if end >= 0 && !strings.HasPrefix(op, IntendedPrefix) {
+			run.intervals[int(end/interval)]++
+		}
+	}
+}
+
+// RawFiles returns the raw output files of every run of the report, with
+// the clock offset of their run
+func (r *ClusterReport) RawFiles() []RawFile {
+	var files []RawFile
+	for _, run := range r.runs {
+		for _, name := range run.rawFiles {
+			files = append(files, RawFile{Name: name, Offset: run.offset, Uncertainty: run.uncertainty})
+		}
+	}
+	return files
+}
+
+// WriteClocks records the clock offset and uncertainty of every run of the
+// report in <csvfilename>_<follower>_<runid>_clocks.csv of the leader run
+func (r *ClusterReport) WriteClocks() error {
+	f, err := os.Create(RawFilePrefix(r.p) + clocksSuffix)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(clocksHeader)
+	for _, run := range r.runs {
+		w.Write([]string{run.name, strconv.FormatInt(int64(run.offset), 10), strconv.FormatInt(int64(run.uncertainty), 10)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Output prints the merged histograms of every operation, the histograms of
+// every run, and the throughput of the cluster and of every run per interval
+func (r *ClusterReport) Output() {
+	if len(r.runs) == 0 {
+		return
+	}
+	outputStyle := r.p.GetString(prop.OutputStyle, util.OutputStylePlain)
+
+	merged, elapsed := r.merged()
+	var lines [][]string
+	for _, op := range sortedKeys(merged) {
+		lines = append(lines, append([]string{op}, summaryLine(histogramInfoMap(merged[op], elapsed[op]))...))
+	}
+	fmt.Printf("Cluster of %d runs:\n", len(r.runs))
+	renderLines(outputStyle, header, lines)
+
+	lines = nil
+	for _, run := range r.runs {
+		for _, op := range sortedKeys(run.ops) {
+			line := []string{run.name, op}
+			line = append(line, summaryLine(histogramInfoMap(run.ops[op], run.elapsed[op]))...)
+			lines = append(lines, line)
+		}
+	}
+	fmt.Println("Cluster runs:")
+	renderLines(outputStyle, append([]string{"Follower"}, header...), lines)
+
+	intervalHeader := []string{"Interval", "Count", "OPS"}
+	last := -1
+	for _, run := range r.runs {
+		intervalHeader = append(intervalHeader, run.name+" OPS")
+		for i := range run.intervals {
+			if i > last {
+				last = i
+			}
+		}
+	}
+	lines = nil
+	seconds := r.interval.Seconds()
+	for i := 0; i <= last; i++ {
+		var total int64
+		var perRun []string
+		for _, run := range r.runs {
+			total += run.intervals[i]
+			perRun = append(perRun, util.FloatToOneString(float64(run.intervals[i])/seconds))
+		}
+		line := []string{strconv.Itoa(i + 1), strconv.FormatInt(total, 10), util.FloatToOneString(float64(total) / seconds)}
+		lines = append(lines, append(line, perRun...))
+	}
+	fmt.Println("Cluster intervals:")
+	renderLines(outputStyle, intervalHeader, lines)
+}
+
+// merged returns the histograms of every operation merged over the runs,
+// and the longest time the runs measured the operation for
+func (r *ClusterReport) merged() (map[string]*hdrhistogram.Histogram, map[string]float64) {
+	merged := make(map[string]*hdrhistogram.Histogram)
+	elapsed := make(map[string]float64)
+	for _, run := range r.runs {
+		for op, hist := range run.ops {
+			if merged[op] == nil {
+				merged[op] = newHDRHistogram()
+			}
+			merged[op].Merge(hist)
+			if run.elapsed[op] > elapsed[op] {
+				elapsed[op] = run.elapsed[op]
+			}
+		}
+	}
+	return merged, elapsed
+}
+
+func sortedKeys(ops map[string]*hdrhistogram.Histogram) []string {
+	keys := make([]string, 0, len(ops))
+	for op := range ops {
+		keys = append(keys, op)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func renderLines(outputStyle string, header []string, lines [][]string) {
+	switch outputStyle {
+	case util.OutputStyleJson:
+		util.RenderJson(header, lines)
+	case util.OutputStyleTable:
+		util.RenderTable(header, lines)
+	default:
+		util.RenderString("%-6s - %s\n", header, lines)
+	}
+}
@@ -0,0 +1,222 @@
+package measurement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func clusterProps(t *testing.T) *properties.Properties {
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "run"))
+	p.Set(prop.RunID, "test")
+	p.Set(prop.LogInterval, "10")
+	return p
+}
+
+// writeSummary writes the histogram summary of the run of the follower, the
+// latencies being in microseconds
+func writeSummary(t *testing.T, p *properties.Properties, follower string, ops map[string][]int64, intervals map[string][]int64) {
+	t.Helper()
+	summary := runSummary{Follower: follower, RunID: "test", Operations: make(map[string]operationSummary)}
+	for op, latencies := range ops {
+		hist := newHDRHistogram()
+		for _, latency := range latencies {
+			hist.RecordValue(latency)
+		}
+		encoded, err := hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary.Operations[op] = operationSummary{Elapsed: 20, Histogram: encoded, Intervals: intervals[op]}
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(rawFilePrefix(p, follower, "test")+summarySuffix, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeRaw writes the operations, start and end timestamps, to the raw file
+// of the run of the follower
+func writeRaw(t *testing.T, p *properties.Properties, follower string, index int, rows [][]string) {
+	t.Helper()
+	f, err := os.Create(fmt.Sprintf("%v_%04d.csv", rawFilePrefix(p, follower, "test"), index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write(seriesheader)
+	w.WriteAll(rows)
+	if err = w.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rawRow(op string, start, end time.Time) []string {
+	return []string{op, strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(end.UnixNano(), 10), "key", "", "0", "test"}
+}
+
+func TestClusterReportMergesRuns(t *testing.T) {
+	p := clusterProps(t)
+	start := time.Unix(1700000000, 0)
+
+	var primary, second []int64
+	for i := int64(1); i <= 100; i++ {
+		primary = append(primary, i*1000)
+		second = append(second, (100+i)*1000)
+	}
+	writeSummary(t, p, "primary", map[string][]int64{"READ": primary}, map[string][]int64{"READ": {60, 40}})
+	writeSummary(t, p, "f1",
+		map[string][]int64{"READ": second, "UPDATE": {500}, IntendedPrefix + "READ": second},
+		map[string][]int64{"READ": {30, 70}, "UPDATE": {0, 0, 1}, IntendedPrefix + "READ": {100}})
+
+	// f2 has no summary, its raw operations are in its clock, 2s behind the leader
+	offset := 2 * time.Second
+	local := start.Add(-offset)
+	writeRaw(t, p, "f2", 0, [][]string{
+		rawRow("READ", local.Add(-2*time.Second), local.Add(-time.Second)),
+		rawRow("READ", local, local.Add(time.Second)),
+	})
+	writeRaw(t, p, "f2", 1, [][]string{
+		rawRow("READ", local.Add(9*time.Second), local.Add(10*time.Second)),
+		rawRow(IntendedPrefix+"READ", local.Add(9*time.Second), local.Add(10*time.Second)),
+	})
+
+	r := NewClusterReport(p, start)
+	if err := r.Add("primary", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("f1", -time.Millisecond, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("f2", offset, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("f3", 0, 0); err == nil {
+		t.Error("want an error for a follower without measurements")
+	}
+
+	// the intervals of the summaries and those the raw operations ended in,
+	// in the leader clock, without the intended latencies
+	for i, want := range []map[int]int64{{0: 60, 1: 40}, {0: 30, 1: 70, 2: 1}, {0: 1, 1: 1}} {
+		if got := r.runs[i].intervals; !reflect.DeepEqual(got, want) {
+			t.Errorf("run %v: want intervals %v, got %v", r.runs[i].name, want, got)
+		}
+	}
+	if got := r.runs[2].elapsed["READ"]; got != 10 {
+		t.Errorf("want the raw run measured for 10s, got %v", got)
+	}
+
+	merged, elapsed := r.merged()
+	all := newHDRHistogram()
+	for _, latency := range append(append(primary, second...), 1000000, 1000000, 1000000) {
+		all.RecordValue(latency)
+	}
+	read := merged["READ"]
+	if read.TotalCount() != 203 {
+		t.Errorf("want 203 reads, got %v", read.TotalCount())
+	}
+	for _, q := range []float64{50, 90, 99, 99.9} {
+		if got, want := read.ValueAtQuantile(q), all.ValueAtQuantile(q); got != want {
+			t.Errorf("p%v: want %v, got %v", q, want, got)
+		}
+	}
+	if got, want := read.Max(), all.Max(); got != want {
+		t.Errorf("max: want %v, got %v", want, got)
+	}
+	if merged["UPDATE"].TotalCount() != 1 || merged[IntendedPrefix+"READ"].TotalCount() != 101 {
+		t.Errorf("want 1 update and 101 intended reads, got %v and %v",
+			merged["UPDATE"].TotalCount(), merged[IntendedPrefix+"READ"].TotalCount())
+	}
+	if elapsed["READ"] != 20 {
+		t.Errorf("want reads measured for the longest run, 20s, got %v", elapsed["READ"])
+	}
+
+	files := r.RawFiles()
+	if len(files) != 2 {
+		t.Fatalf("want the 2 raw files of f2, got %v", files)
+	}
+	for _, f := range files {
+		if f.Offset != offset || f.Uncertainty != 5*time.Millisecond {
+			t.Errorf("%v: want the clock of f2, got %v ± %v", f.Name, f.Offset, f.Uncertainty)
+		}
+	}
+}
+
+func TestClusterReportWriteClocks(t *testing.T) {
+	p := clusterProps(t)
+	p.Set(prop.FollowerName, "primary")
+	writeSummary(t, p, "primary", map[string][]int64{"READ": {1}}, nil)
+	writeSummary(t, p, "f1", map[string][]int64{"READ": {1}}, nil)
+
+	r := NewClusterReport(p, time.Now())
+	if err := r.Add("primary", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("f1", -1500*time.Microsecond, 250*time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteClocks(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(RawFilePrefix(p) + clocksSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{clocksHeader, {"primary", "0", "0"}, {"f1", "-1500000", "250000"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("want %v, got %v", want, rows)
+	}
+}
+
+func TestRunSummaryRoundTrip(t *testing.T) {
+	p := clusterProps(t)
+	p.Set(prop.FollowerName, "f1")
+	InitMeasure(p)
+	defer func() { globalMeasure = nil }()
+
+	start := time.Now()
+	for i := 1; i <= 60; i++ {
+		Measure("READ", start, start.Add(time.Duration(i)*time.Millisecond), "", nil)
+	}
+	IntervalOutput()
+	for i := 61; i <= 100; i++ {
+		Measure("READ", start, start.Add(time.Duration(i)*time.Millisecond), "", nil)
+	}
+	if err := WriteRunSummary(p); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewClusterReport(p, start)
+	if err := r.Add("f1", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	run := r.runs[0]
+	if want := map[int]int64{0: 60, 1: 40}; !reflect.DeepEqual(run.intervals, want) {
+		t.Errorf("want intervals %v, got %v", want, run.intervals)
+	}
+	read := run.ops["READ"]
+	if read.TotalCount() != 100 || !read.ValuesAreEquivalent(read.ValueAtQuantile(50), 50000) {
+		t.Errorf("want 100 reads with a median of 50000us, got %v and %v", read.TotalCount(), read.ValueAtQuantile(50))
+	}
+}
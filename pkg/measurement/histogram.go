@@ -14,7 +14,7 @@
 package measurement
 
 import (
-	"sort"
+	"sync"
 	"time"
 
 	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
@@ -23,10 +23,21 @@ import (
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
+// histogram records latencies into an interval histogram which is swapped
+// out on every report, and accumulates the swapped windows into a
+// cumulative histogram for the final summary.
 type histogram struct {
-	boundCounts util.ConcurrentMap
-	startTime   time.Time
-	hist        *hdrhistogram.Histogram
+	sync.Mutex
+
+	startTime time.Time
+	// active is the window the workers record into, guarded by the mutex.
+	active      *hdrhistogram.Histogram
+	activeStart time.Time
+	spare       *hdrhistogram.Histogram
+
+	// hist holds every window swapped out so far.
+	histLock sync.Mutex
+	hist     *hdrhistogram.Histogram
 }
 
 // Metric name.
@@ -42,6 +53,10 @@ const (
 	PER9999TH = "PER9999TH"
 )
 
+func newHDRHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+}
+
 func (h *histogram) Info() ycsb.MeasurementInfo {
 	res := h.getInfo()
 	delete(res, ELAPSED)
@@ -51,13 +66,18 @@ func (h *histogram) Info() ycsb.MeasurementInfo {
 func newHistogram(p *properties.Properties) *histogram {
 	h := new(histogram)
 	h.startTime = time.Now()
-	h.hist = hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+	h.activeStart = h.startTime
+	h.active = newHDRHistogram()
+	h.spare = newHDRHistogram()
+	h.hist = newHDRHistogram()
 	return h
 }
 
 func (h *histogram) Measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
 	latency := end.Sub(start)
-	h.hist.RecordValue(latency.Microseconds())
+	h.Lock()
+	h.active.RecordValue(latency.Microseconds())
+	h.Unlock()
 }
 
 // GetMeasurement Not meant to be implemented in a histogram
@@ -66,8 +86,10 @@ func (h *histogram) GetMeasurement(index int) ([]string, error) {
 }
 
 func (h *histogram) Summary() []string {
-	res := h.getInfo()
+	return summaryLine(h.getInfo())
+}
 
+func summaryLine(res map[string]interface{}) []string {
 	return []string{
 		util.FloatToOneString(res[ELAPSED]),
 		util.IntToString(res[COUNT]),
@@ -81,31 +103,67 @@ func (h *histogram) Summary() []string {
 	}
 }
 
+// swap takes the active window out and replaces it with the spare one.
+func (h *histogram) swap() (*hdrhistogram.Histogram, time.Time, time.Time) {
+	now := time.Now()
+	h.Lock()
+	window, windowStart := h.active, h.activeStart
+	h.active, h.activeStart = h.spare, now
+	h.Unlock()
+	return window, windowStart, now
+}
+
+func (h *histogram) intervalInfo() map[string]interface{} {
+	h.histLock.Lock()
+	defer h.histLock.Unlock()
+
+	window, windowStart, windowEnd := h.swap()
+	h.hist.Merge(window)
+	res := histogramInfoMap(window, windowEnd.Sub(windowStart).Seconds())
+
+	window.Reset()
+	h.Lock()
+	h.spare = window
+	h.Unlock()
+	return res
+}
+
 func (h *histogram) getInfo() map[string]interface{} {
-	min := h.hist.Min()
-	max := h.hist.Max()
-	avg := int64(h.hist.Mean())
-	count := h.hist.TotalCount()
+	total, elapsed := h.total()
+	return histogramInfoMap(total, elapsed)
+}
 
-	bounds := h.boundCounts.Keys()
-	sort.Ints(bounds)
+// total returns a copy of the cumulative histogram, the active window
+// included, and the seconds it covers
+func (h *histogram) total() (*hdrhistogram.Histogram, float64) {
+	h.histLock.Lock()
+	defer h.histLock.Unlock()
 
-	per99 := h.hist.ValueAtPercentile(99)
-	per999 := h.hist.ValueAtPercentile(99.9)
-	per9999 := h.hist.ValueAtPercentile(99.99)
+	total := hdrhistogram.Import(h.hist.Export())
+	h.Lock()
+	total.Merge(h.active)
+	h.Unlock()
+
+	return total, time.Now().Sub(h.startTime).Seconds()
+}
+
+func histogramInfoMap(hist *hdrhistogram.Histogram, elapsed float64) map[string]interface{} {
+	count := hist.TotalCount()
+	qps := float64(0)
+	if elapsed > 0 {
+		qps = float64(count) / elapsed
+	}
 
-	elapsed := time.Now().Sub(h.startTime).Seconds()
-	qps := float64(count) / elapsed
 	res := make(map[string]interface{})
 	res[ELAPSED] = elapsed
 	res[COUNT] = count
 	res[QPS] = qps
-	res[AVG] = avg
-	res[MIN] = min
-	res[MAX] = max
-	res[PER99TH] = per99
-	res[PER999TH] = per999
-	res[PER9999TH] = per9999
+	res[AVG] = int64(hist.Mean())
+	res[MIN] = hist.Min()
+	res[MAX] = hist.Max()
+	res[PER99TH] = hist.ValueAtPercentile(99)
+	res[PER999TH] = hist.ValueAtPercentile(99.9)
+	res[PER9999TH] = hist.ValueAtPercentile(99.99)
 
 	return res
 }
@@ -0,0 +1,48 @@
+package measurement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestHistogramInterval(t *testing.T) {
+	h := newHistogram(properties.NewProperties())
+	start := time.Now()
+
+	for i := 0; i < 10; i++ {
+		h.Measure("READ", start, start.Add(time.Millisecond), "", nil)
+	}
+	first := h.intervalInfo()
+	if first[COUNT].(int64) != 10 {
+		t.Fatalf("want 10 operations in the first interval, got %v", first[COUNT])
+	}
+
+	for i := 0; i < 5; i++ {
+		h.Measure("READ", start, start.Add(100*time.Millisecond), "", nil)
+	}
+	second := h.intervalInfo()
+	if second[COUNT].(int64) != 5 || second[MIN].(int64) < 99000 {
+		t.Fatalf("want only the 5 slow operations in the second interval, got %v", second)
+	}
+
+	h.Measure("READ", start, start.Add(time.Millisecond), "", nil)
+	total := h.getInfo()
+	if total[COUNT].(int64) != 16 || total[MIN].(int64) > 1001 {
+		t.Fatalf("want every operation in the cumulative summary, got %v", total)
+	}
+}
+
+func TestOpenLoopEnablesHistograms(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.MeasurementType, TypeRaw)
+	if HistogramEnabled(p) {
+		t.Fatalf("want no histograms for %v", TypeRaw)
+	}
+	p.Set(prop.LoadMode, prop.LoadModeOpenLoop)
+	if !HistogramEnabled(p) || !RawEnabled(p) {
+		t.Fatalf("want the histograms along the raw series of an open loop run")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"context"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Latency modes accepted by measurement.latency.
+const (
+	// LatencyOp measures the latency from the actual start of the operation.
+	LatencyOp = "op"
+	// LatencyIntended measures the latency from the time the throttled
+	// worker intended to start the operation, correcting coordinated omission.
+	LatencyIntended = "intended"
+	// LatencyBoth records both, the intended latency under IntendedPrefix.
+	LatencyBoth = "both"
+)
+
+// IntendedPrefix is prepended to the operation name of intended latencies.
+const IntendedPrefix = "Intended-"
+
+const intendedStartKey = contextKey("intendedstart")
+
+// IntendedStart holds the time at which the current operation of a worker
+// was scheduled to start.
+type IntendedStart struct {
+	t time.Time
+}
+
+// Set sets the intended start of the next operation.
+func (s *IntendedStart) Set(t time.Time) {
+	s.t = t
+}
+
+// WithIntendedStart attaches the intended start holder of a worker to the context.
+func WithIntendedStart(ctx context.Context, s *IntendedStart) context.Context {
+	return context.WithValue(ctx, intendedStartKey, s)
+}
+
+func initLatency(p *properties.Properties) {
+	switch mode := p.GetString(prop.MeasurementLatency, LatencyOp); mode {
+	case LatencyOp, LatencyIntended, LatencyBoth:
+		globalLatency = mode
+	default:
+		panic("unsupported measurement latency: " + mode)
+	}
+}
+
+// OpLatencyEnabled returns whether the latency from the actual start of the
+// operations is recorded.
+func OpLatencyEnabled() bool {
+	return globalLatency != LatencyIntended
+}
+
+// IntendedLatencyStart returns the intended start of the operation which
+// actually started at start, and whether the intended latency is recorded.
+// Operations of workers which are not throttled start when intended.
+func IntendedLatencyStart(ctx context.Context, start time.Time) (time.Time, bool) {
+	if globalLatency != LatencyIntended && globalLatency != LatencyBoth {
+		return start, false
+	}
+	if s, ok := ctx.Value(intendedStartKey).(*IntendedStart); ok && !s.t.IsZero() && s.t.Before(start) {
+		return s.t, true
+	}
+	return start, true
+}
+
+var globalLatency = LatencyOp
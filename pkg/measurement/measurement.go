@@ -14,7 +14,9 @@
 package measurement
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,6 +27,24 @@ import (
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
+// Measurement types accepted by measurement.type. Several types can be
+// combined with a comma, e.g. "raw,histogram".
+const (
+	TypeRaw       = "raw"
+	TypeHistogram = "histogram"
+)
+
+// Open loop measurements, reported separately from the service latency of
+// the operations.
+const (
+	// OpQueue is the delay between the arrival of a request and its execution.
+	OpQueue = "QUEUE"
+	// OpDropped counts the requests which arrived while the queue was full.
+	OpDropped = "DROPPED"
+	// OpLate counts the requests which waited longer than openloop.latethreshold.
+	OpLate = "LATE"
+)
+
 var header = []string{"Operation", "Takes(s)", "Count", "OPS", "Avg(us)", "Min(us)", "Max(us)", "99th(us)", "99.9th(us)", "99.99th(us)"}
 
 type measurement struct {
@@ -32,7 +52,14 @@ type measurement struct {
 
 	p *properties.Properties
 
-	opMeasurement map[string]ycsb.Measurement
+	opMeasurement map[string]*histogram
+	intervals     int
+	// intervalCounts are the operations measured during every interval,
+	// by operation, for the cluster summary
+	countsLock     sync.Mutex
+	intervalCounts map[string][]int64
+	// print is false when the histograms are only collected for the timeline.
+	print bool
 }
 
 func (m *measurement) measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
@@ -41,18 +68,18 @@ func (m *measurement) measure(op string, start time.Time, end time.Time, key str
 	m.RUnlock()
 
 	if !ok {
-		opM = newHistogram(m.p)
 		m.Lock()
-		m.opMeasurement[op] = opM
+		if opM, ok = m.opMeasurement[op]; !ok {
+			opM = newHistogram(m.p)
+			m.opMeasurement[op] = opM
+		}
 		m.Unlock()
 	}
 
 	opM.Measure(op, start, end, key, values)
 }
 
-func (m *measurement) output() {
-	m.RLock()
-	defer m.RUnlock()
+func (m *measurement) sortedOpNames() []string {
 	keys := make([]string, len(m.opMeasurement))
 	var i = 0
 	for k := range m.opMeasurement {
@@ -60,14 +87,133 @@ func (m *measurement) output() {
 		i += 1
 	}
 	sort.Strings(keys)
+	return keys
+}
+
+func (m *measurement) output() {
+	m.RLock()
+	defer m.RUnlock()
 
 	lines := [][]string{}
-	for _, op := range keys {
+	for _, op := range m.sortedOpNames() {
 		line := []string{op}
 		line = append(line, m.opMeasurement[op].Summary()...)
 		lines = append(lines, line)
 	}
 
+	m.render(lines)
+}
+
+// intervalOutput prints the summary of the operations measured during the
+// last interval only.
+func (m *measurement) intervalOutput() {
+	m.Lock()
+	m.intervals++
+	interval := m.intervals
+	m.Unlock()
+
+	m.RLock()
+	defer m.RUnlock()
+
+	now := time.Now()
+	ops := m.sortedOpNames()
+	infos := m.intervalInfos(interval, ops)
+
+	if m.print {
+		lines := [][]string{}
+		for _, op := range ops {
+			line := []string{op}
+			line = append(line, summaryLine(infos[op])...)
+			lines = append(lines, line)
+		}
+
+		if load, ok := globalLoad.Load().(activeLoad); ok {
+			fmt.Printf("Interval %d - Target(ops/s): %s, Threads: %d\n", interval, load.targetString(), load.threads)
+		} else {
+			fmt.Printf("Interval %d:\n", interval)
+		}
+		m.render(lines)
+	}
+
+	if globalTimeline != nil {
+		globalTimeline.writeIntervals(now, interval, infos)
+	}
+	notifyIntervalListeners(infos)
+}
+
+// lastInterval writes the operations measured since the last interval
+// output, which end the run, to the timeline. The window covers the time
+// since the last interval only.
+func (m *measurement) lastInterval() {
+	m.Lock()
+	m.intervals++
+	interval := m.intervals
+	m.Unlock()
+
+	m.RLock()
+	defer m.RUnlock()
+
+	now := time.Now()
+	infos := m.intervalInfos(interval, m.sortedOpNames())
+	for _, info := range infos {
+		if info[COUNT].(int64) > 0 {
+			globalTimeline.writeIntervals(now, interval, infos)
+			return
+		}
+	}
+}
+
+// intervalInfos ends the interval of every operation and returns their
+// metrics during the interval
+func (m *measurement) intervalInfos(interval int, ops []string) map[string]map[string]interface{} {
+	infos := make(map[string]map[string]interface{}, len(ops))
+	for _, op := range ops {
+		infos[op] = m.opMeasurement[op].intervalInfo()
+	}
+	m.recordIntervalCounts(interval, infos)
+	return infos
+}
+
+// recordIntervalCounts keeps the count of every operation during the interval
+func (m *measurement) recordIntervalCounts(interval int, infos map[string]map[string]interface{}) {
+	m.countsLock.Lock()
+	defer m.countsLock.Unlock()
+	for op, info := range infos {
+		counts := m.intervalCounts[op]
+		for len(counts) < interval {
+			counts = append(counts, 0)
+		}
+		counts[interval-1] = info[COUNT].(int64)
+		m.intervalCounts[op] = counts
+	}
+}
+
+// activeLoad is the target throughput and the number of active workers at
+// the end of an interval.
+type activeLoad struct {
+	target  float64
+	threads int
+}
+
+func (l activeLoad) targetString() string {
+	if l.target <= 0 {
+		return "unthrottled"
+	}
+	return util.FloatToOneString(l.target)
+}
+
+// SetActiveLoad records the target throughput in operations per second, 0
+// meaning unthrottled, and the number of active workers, so the interval
+// output reports the load the client was asked to generate.
+func SetActiveLoad(target float64, threads int) {
+	globalLoad.Store(activeLoad{target: target, threads: threads})
+	if globalProm != nil {
+		globalProm.target.Set(target)
+		globalProm.threads.Set(float64(threads))
+	}
+}
+
+func (m *measurement) render(lines [][]string) {
 	outputStyle := m.p.GetString(prop.OutputStyle, util.OutputStylePlain)
 	switch outputStyle {
 	case util.OutputStylePlain:
@@ -103,25 +249,129 @@ func (m *measurement) getOpName() []string {
 	return res
 }
 
+// Types returns the measurement types configured by measurement.type.
+func Types(p *properties.Properties) []string {
+	var types []string
+	for _, t := range strings.Split(p.GetString(prop.MeasurementType, TypeRaw), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// RawEnabled returns whether the raw series is part of measurement.type.
+func RawEnabled(p *properties.Properties) bool {
+	for _, t := range Types(p) {
+		if t == TypeRaw {
+			return true
+		}
+	}
+	return false
+}
+
+// HistogramCollected returns whether the latency histograms have to be
+// collected, either to be printed or to feed the timeline.
+func HistogramCollected(p *properties.Properties) bool {
+	return HistogramEnabled(p) || TimelineEnabled(p)
+}
+
+// HistogramEnabled returns whether the latency histograms are part of
+// measurement.type. Any type other than raw selects the histograms. The open
+// loop mode always has them, its queueing delay and dropped requests are
+// only measured by the histograms.
+func HistogramEnabled(p *properties.Properties) bool {
+	if p.GetString(prop.LoadMode, prop.LoadModeDef) == prop.LoadModeOpenLoop {
+		return true
+	}
+	for _, t := range Types(p) {
+		if t != TypeRaw {
+			return true
+		}
+	}
+	return false
+}
+
 // InitMeasure initializes the global measurement.
 func InitMeasure(p *properties.Properties) {
 	globalMeasure = new(measurement)
 	globalMeasure.p = p
-	globalMeasure.opMeasurement = make(map[string]ycsb.Measurement, 16)
+	globalMeasure.opMeasurement = make(map[string]*histogram, 16)
+	globalMeasure.intervalCounts = make(map[string][]int64, 16)
+	globalMeasure.print = HistogramEnabled(p)
+	initLatency(p)
+	resetProgress()
+	if TimelineEnabled(p) {
+		t, err := newTimeline(p)
+		if err != nil {
+			util.Fatalf("create timeline failed %v", err)
+		}
+		globalTimeline = t
+	}
 	EnableWarmUp(p.GetInt64(prop.WarmUpTime, 0) > 0)
 }
 
-// Output prints the measurement summary.
+// Output prints the cumulative measurement summary.
 func Output() {
 	globalMeasure.output()
 }
 
+// IntervalOutput prints the measurement summary of the last interval and
+// starts a new one.
+func IntervalOutput() {
+	globalMeasure.intervalOutput()
+}
+
 // EnableWarmUp sets whether to enable warm-up.
 func EnableWarmUp(b bool) {
+	warmUpLock.Lock()
+	defer warmUpLock.Unlock()
+
 	if b {
 		atomic.StoreInt32(&warmUp, 1)
+		select {
+		case <-warmUpDone:
+			warmUpDone = make(chan struct{})
+		default:
+		}
 	} else {
 		atomic.StoreInt32(&warmUp, 0)
+		select {
+		case <-warmUpDone:
+		default:
+			close(warmUpDone)
+		}
+	}
+}
+
+// WarmUpDone returns a channel which is closed when the warm-up finishes.
+func WarmUpDone() <-chan struct{} {
+	warmUpLock.Lock()
+	defer warmUpLock.Unlock()
+	return warmUpDone
+}
+
+// Clocks accepted by events.clock, which the event times and the load
+// profile phases count from.
+const (
+	// ClockWarmUp starts the clock when the warm-up finishes.
+	ClockWarmUp = "warmup"
+	// ClockLoad starts the clock when the workload starts.
+	ClockLoad = "load"
+)
+
+// ClockStart returns a channel which is closed when the clock of
+// events.clock starts.
+func ClockStart(p *properties.Properties) (<-chan struct{}, error) {
+	switch clock := p.GetString(prop.EventsClock, ClockWarmUp); clock {
+	case ClockWarmUp:
+		return WarmUpDone(), nil
+	case ClockLoad:
+		started := make(chan struct{})
+		close(started)
+		return started, nil
+	default:
+		return nil, fmt.Errorf("unsupported events clock: %v", clock)
 	}
 }
 
@@ -132,8 +382,11 @@ func IsWarmUpFinished() bool {
 
 // Measure measures the operation.
 func Measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
-	if IsWarmUpFinished() {
+	if globalMeasure != nil && IsWarmUpFinished() {
 		globalMeasure.measure(op, start, end, key, values)
+		if globalProm != nil {
+			globalProm.observe(op, start, end)
+		}
 	}
 }
 
@@ -149,4 +402,12 @@ func GetOpNames() []string {
 }
 
 var globalMeasure *measurement
+var globalLoad atomic.Value
 var warmUp int32 // use as bool, 1 means in warmup progress, 0 means warmup finished.
+var warmUpLock sync.Mutex
+var warmUpDone = func() chan struct{} {
+	// no warm-up until EnableWarmUp(true)
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
@@ -0,0 +1,39 @@
+package measurement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestMeasurementTypes(t *testing.T) {
+	for _, tt := range []struct {
+		value     string
+		types     []string
+		raw       bool
+		histogram bool
+	}{
+		{"", []string{TypeRaw}, true, false},
+		{"raw", []string{TypeRaw}, true, false},
+		{"histogram", []string{TypeHistogram}, false, true},
+		{"raw,histogram", []string{TypeRaw, TypeHistogram}, true, true},
+		{" histogram , raw ,", []string{TypeHistogram, TypeRaw}, true, true},
+		{"table", []string{"table"}, false, true},
+	} {
+		p := properties.NewProperties()
+		if tt.value != "" {
+			p.Set(prop.MeasurementType, tt.value)
+		}
+		if got := Types(p); !reflect.DeepEqual(got, tt.types) {
+			t.Errorf("%q: want types %v, got %v", tt.value, tt.types, got)
+		}
+		if got := RawEnabled(p); got != tt.raw {
+			t.Errorf("%q: want raw %v, got %v", tt.value, tt.raw, got)
+		}
+		if got := HistogramEnabled(p); got != tt.histogram {
+			t.Errorf("%q: want histogram %v, got %v", tt.value, tt.histogram, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// progress counts the operations done after the warm-up and wakes up the
+// waiters of an operation count.
+type progress struct {
+	done int64
+	// next is the lowest count waited for, so AddOperations only locks when
+	// a waiter has to be woken up.
+	next int64
+
+	mu      sync.Mutex
+	waiters map[int64]chan struct{}
+}
+
+func (p *progress) add(n int64) {
+	if done := atomic.AddInt64(&p.done, n); done >= atomic.LoadInt64(&p.next) {
+		p.notify(done)
+	}
+}
+
+func (p *progress) notify(done int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := int64(math.MaxInt64)
+	for count, ch := range p.waiters {
+		if count <= done {
+			close(ch)
+			delete(p.waiters, count)
+		} else if count < next {
+			next = count
+		}
+	}
+	atomic.StoreInt64(&p.next, next)
+}
+
+func (p *progress) wait(count int64) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.waiters == nil {
+		p.waiters = make(map[int64]chan struct{})
+	}
+	ch, ok := p.waiters[count]
+	if !ok {
+		ch = make(chan struct{})
+		if atomic.LoadInt64(&p.done) >= count {
+			close(ch)
+			return ch
+		}
+		p.waiters[count] = ch
+	}
+	if count < atomic.LoadInt64(&p.next) {
+		atomic.StoreInt64(&p.next, count)
+	}
+	return ch
+}
+
+// reset drops the operations counted so far and their waiters, which are
+// left blocked.
+func (p *progress) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.waiters = nil
+	atomic.StoreInt64(&p.next, math.MaxInt64)
+	atomic.StoreInt64(&p.done, 0)
+}
+
+// AddOperations counts operations done by the workers. Operations done
+// during the warm-up are not counted.
+func AddOperations(n int) {
+	if IsWarmUpFinished() {
+		globalProgress.add(int64(n))
+	}
+}
+
+// Operations returns the number of operations done after the warm-up.
+func Operations() int64 {
+	return atomic.LoadInt64(&globalProgress.done)
+}
+
+// OperationsDone returns a channel which is closed once count operations
+// were done after the warm-up.
+func OperationsDone(count int64) <-chan struct{} {
+	return globalProgress.wait(count)
+}
+
+// IntervalStats holds the metrics of an operation over the last interval.
+type IntervalStats struct {
+	Count int64
+	// P99 is the 99th percentile latency in microseconds
+	P99 int64
+}
+
+// intervalListener wraps a listener function, so it can be found again to
+// be removed.
+type intervalListener struct {
+	f func(map[string]IntervalStats)
+}
+
+// AddIntervalListener registers a function called with the metrics of every
+// operation at the end of each interval. The histograms have to be collected.
+// It returns the function removing the listener.
+func AddIntervalListener(f func(map[string]IntervalStats)) func() {
+	l := &intervalListener{f: f}
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	intervalListeners = append(intervalListeners, l)
+
+	return func() {
+		listenersLock.Lock()
+		defer listenersLock.Unlock()
+		for i, other := range intervalListeners {
+			if other == l {
+				// copied, notifyIntervalListeners may be going over the old slice
+				intervalListeners = append(intervalListeners[:i:i], intervalListeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// IntervalStatsAvailable returns whether the interval listeners are called,
+// that is whether the histograms are collected for the current workload.
+func IntervalStatsAvailable() bool {
+	return globalMeasure != nil
+}
+
+// resetProgress starts the progress of a new workload: the operations,
+// waiters and interval listeners of the previous workload are dropped.
+func resetProgress() {
+	globalProgress.reset()
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	intervalListeners = nil
+}
+
+func notifyIntervalListeners(infos map[string]map[string]interface{}) {
+	listenersLock.Lock()
+	listeners := intervalListeners
+	listenersLock.Unlock()
+	if len(listeners) == 0 {
+		return
+	}
+
+	stats := make(map[string]IntervalStats, len(infos))
+	for op, info := range infos {
+		stats[op] = IntervalStats{Count: info[COUNT].(int64), P99: info[PER99TH].(int64)}
+	}
+	for _, l := range listeners {
+		l.f(stats)
+	}
+}
+
+var (
+	globalProgress = progress{next: math.MaxInt64}
+
+	listenersLock     sync.Mutex
+	intervalListeners []*intervalListener
+)
@@ -0,0 +1,125 @@
+package measurement
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestProgressWait(t *testing.T) {
+	p := progress{next: math.MaxInt64}
+	fifty := p.wait(50)
+	hundred := p.wait(100)
+
+	p.add(49)
+	select {
+	case <-fifty:
+		t.Fatalf("woken up after 49 operations")
+	default:
+	}
+
+	p.add(1)
+	select {
+	case <-fifty:
+	default:
+		t.Fatalf("want the waiter of 50 operations woken up")
+	}
+	select {
+	case <-hundred:
+		t.Fatalf("waiter of 100 operations woken up after 50")
+	default:
+	}
+
+	p.add(60)
+	select {
+	case <-hundred:
+	default:
+		t.Fatalf("want the waiter of 100 operations woken up")
+	}
+
+	select {
+	case <-p.wait(10):
+	default:
+		t.Fatalf("want a count already reached to return a closed channel")
+	}
+}
+
+func TestIntervalListeners(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.MeasurementType, "histogram")
+	InitMeasure(p)
+	defer func() { globalMeasure = nil }()
+
+	var first, second []map[string]IntervalStats
+	removeFirst := AddIntervalListener(func(stats map[string]IntervalStats) { first = append(first, stats) })
+	AddIntervalListener(func(stats map[string]IntervalStats) { second = append(second, stats) })
+
+	start := time.Now()
+	Measure("READ", start, start.Add(time.Millisecond), "", nil)
+	IntervalOutput()
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("want both listeners called once, got %v and %v", len(first), len(second))
+	}
+	if got := first[0]["READ"]; got.Count != 1 || got.P99 < 1000 {
+		t.Errorf("want 1 read of 1ms, got %+v", got)
+	}
+
+	removeFirst()
+	removeFirst()
+	IntervalOutput()
+	if len(first) != 1 || len(second) != 2 {
+		t.Errorf("want the removed listener left out, got %v and %v calls", len(first), len(second))
+	}
+}
+
+func TestInitResetsProgress(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.MeasurementType, "histogram")
+	InitMeasure(p)
+	defer func() { globalMeasure = nil }()
+
+	called := false
+	AddIntervalListener(func(map[string]IntervalStats) { called = true })
+	AddOperations(10)
+	pending := OperationsDone(20)
+
+	// the next workload starts from no operation and no listener
+	p = properties.NewProperties()
+	p.Set(prop.MeasurementType, "raw,histogram")
+	p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "raw"))
+	InitMeasure(p)
+	RawInitMeasure(p)
+	defer RawClose()
+	if got := Operations(); got != 0 {
+		t.Errorf("want no operation after the init, got %v", got)
+	}
+	AddOperations(15)
+	select {
+	case <-OperationsDone(20):
+		t.Error("want the operations of the previous workload dropped")
+	default:
+	}
+	select {
+	case <-pending:
+		t.Error("want the waiters of the previous workload dropped")
+	default:
+	}
+	IntervalOutput()
+	if called {
+		t.Error("want the listeners of the previous workload dropped")
+	}
+	if !IntervalStatsAvailable() {
+		t.Error("want the interval stats of a workload collecting the histograms")
+	}
+
+	// a raw only workload drops the histograms of the previous one
+	p.Set(prop.MeasurementType, "raw")
+	RawInitMeasure(p)
+	if IntervalStatsAvailable() {
+		t.Error("want no interval stats for a raw only workload")
+	}
+}
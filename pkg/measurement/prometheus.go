@@ -0,0 +1,191 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// promMetrics exposes the live run metrics to Prometheus.
+type promMetrics struct {
+	operations  *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	inFlight    prometheus.Gauge
+	latency     *prometheus.HistogramVec
+	eventFired  *prometheus.GaugeVec
+	eventStatus *prometheus.GaugeVec
+	follower    *prometheus.GaugeVec
+	target      prometheus.Gauge
+	threads     prometheus.Gauge
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ycsb",
+			Name:      "operations_total",
+			Help:      "Number of operations completed.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ycsb",
+			Name:      "operation_errors_total",
+			Help:      "Number of operations which returned an error.",
+		}, []string{"operation"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "operations_in_flight",
+			Help:      "Number of operations currently executed by the workers.",
+		}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "ycsb",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of the operations, including failed ones.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 18),
+		}, []string{"operation"}),
+		eventFired: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "event_action_last_fired_timestamp_seconds",
+			Help:      "Unix time at which an event action was last fired against a node.",
+		}, []string{"node", "command"}),
+		eventStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "event_action_success",
+			Help:      "Whether the last firing of an event action succeeded (1) or failed (0).",
+		}, []string{"node", "command"}),
+		follower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "follower_started",
+			Help:      "Whether a follower was started (1) or not (0).",
+		}, []string{"follower"}),
+		target: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "target_operations_per_second",
+			Help:      "Target throughput the client generates, 0 means unthrottled.",
+		}),
+		threads: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "ycsb",
+			Name:      "active_threads",
+			Help:      "Number of workers issuing operations.",
+		}),
+	}
+}
+
+func (m *promMetrics) register(r prometheus.Registerer) {
+	r.MustRegister(m.operations, m.errors, m.inFlight, m.latency, m.eventFired, m.eventStatus, m.follower, m.target, m.threads)
+}
+
+// observe feeds an operation of the histograms, which record the actual or
+// the intended latency of every operation, or both with measurement.latency
+// both.
+func (m *promMetrics) observe(op string, start time.Time, end time.Time) {
+	if strings.HasPrefix(op, IntendedPrefix) {
+		// The operation was already counted with its actual latency.
+		if globalLatency == LatencyBoth {
+			m.observeLatency(op, start, end)
+			return
+		}
+		op = strings.TrimPrefix(op, IntendedPrefix)
+	}
+	m.count(op)
+	m.observeLatency(op, start, end)
+}
+
+// observeRaw feeds an operation of the raw series, which records the actual
+// latency of every operation, and the intended one next to it with
+// measurement.latency intended or both.
+func (m *promMetrics) observeRaw(op string, start time.Time, end time.Time) {
+	if globalLatency != LatencyIntended {
+		m.observe(op, start, end)
+		return
+	}
+	// The operations are counted from their actual latency, and take the
+	// intended one for their latency like with the histograms.
+	if strings.HasPrefix(op, IntendedPrefix) {
+		m.observeLatency(strings.TrimPrefix(op, IntendedPrefix), start, end)
+	} else {
+		m.count(op)
+	}
+}
+
+func (m *promMetrics) count(op string) {
+	if strings.HasSuffix(op, errorSuffix) {
+		m.errors.WithLabelValues(strings.TrimSuffix(op, errorSuffix)).Inc()
+	} else {
+		m.operations.WithLabelValues(op).Inc()
+	}
+}
+
+func (m *promMetrics) observeLatency(op string, start time.Time, end time.Time) {
+	m.latency.WithLabelValues(strings.TrimSuffix(op, errorSuffix)).Observe(end.Sub(start).Seconds())
+}
+
+// EnablePrometheus registers the run metrics and serves them on /metrics of
+// the default HTTP mux, which is the one the debug.pprof listener uses.
+func EnablePrometheus() {
+	promOnce.Do(func() {
+		m := newPromMetrics()
+		m.register(prometheus.DefaultRegisterer)
+		http.Handle("/metrics", promhttp.Handler())
+		globalProm = m
+	})
+}
+
+// BeginOperation marks an operation as in flight.
+func BeginOperation() {
+	if globalProm != nil {
+		globalProm.inFlight.Inc()
+	}
+}
+
+// EndOperation marks an operation started with BeginOperation as done.
+func EndOperation() {
+	if globalProm != nil {
+		globalProm.inFlight.Dec()
+	}
+}
+
+// RecordFollowerState exposes whether the follower was started.
+func RecordFollowerState(followerID string, started bool) {
+	if globalProm == nil {
+		return
+	}
+	state := float64(0)
+	if started {
+		state = 1
+	}
+	globalProm.follower.WithLabelValues(followerID).Set(state)
+}
+
+func promRecordEvent(now time.Time, nodeID string, command string, err error) {
+	if globalProm == nil {
+		return
+	}
+	globalProm.eventFired.WithLabelValues(nodeID, command).Set(float64(now.UnixNano()) / float64(time.Second))
+	status := float64(1)
+	if err != nil {
+		status = 0
+	}
+	globalProm.eventStatus.WithLabelValues(nodeID, command).Set(status)
+}
+
+var (
+	promOnce   sync.Once
+	globalProm *promMetrics
+)
@@ -0,0 +1,183 @@
+package measurement
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// promCounts returns the operations, the errors and the latencies observed
+// of each label of the metrics
+func promCounts(t *testing.T, m *promMetrics) (map[string]float64, map[string]float64, map[string]uint64) {
+	t.Helper()
+	r := prometheus.NewPedanticRegistry()
+	m.register(r)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, errs, latencies := make(map[string]float64), make(map[string]float64), make(map[string]uint64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "ycsb_operations_total":
+				ops[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+			case "ycsb_operation_errors_total":
+				errs[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+			case "ycsb_operation_duration_seconds":
+				latencies[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return ops, errs, latencies
+}
+
+func setLatency(t *testing.T, latency string) {
+	saved := globalLatency
+	globalLatency = latency
+	t.Cleanup(func() { globalLatency = saved })
+}
+
+func TestPromObserve(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Millisecond)
+	for _, c := range []struct {
+		name    string
+		latency string
+		raw     bool
+		// the operations each feed records for a read and a failed update
+		ops []string
+		// the latencies observed of each label
+		latencies map[string]uint64
+	}{
+		{"op", LatencyOp, false, []string{"READ", "UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1}},
+		{"intended", LatencyIntended, false, []string{"Intended-READ", "Intended-UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1}},
+		{"both", LatencyBoth, false, []string{"READ", "Intended-READ", "UPDATE_ERROR", "Intended-UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1, "Intended-READ": 1, "Intended-UPDATE": 1}},
+		{"raw op", LatencyOp, true, []string{"READ", "UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1}},
+		{"raw intended", LatencyIntended, true, []string{"READ", "Intended-READ", "UPDATE_ERROR", "Intended-UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1}},
+		{"raw both", LatencyBoth, true, []string{"READ", "Intended-READ", "UPDATE_ERROR", "Intended-UPDATE_ERROR"},
+			map[string]uint64{"READ": 1, "UPDATE": 1, "Intended-READ": 1, "Intended-UPDATE": 1}},
+	} {
+		setLatency(t, c.latency)
+		m := newPromMetrics()
+		for _, op := range c.ops {
+			if c.raw {
+				m.observeRaw(op, start, end)
+			} else {
+				m.observe(op, start, end)
+			}
+		}
+
+		// every operation is counted once whatever the latencies recorded
+		ops, errs, latencies := promCounts(t, m)
+		if len(ops) != 1 || ops["READ"] != 1 || len(errs) != 1 || errs["UPDATE"] != 1 {
+			t.Errorf("%v: want 1 read and 1 failed update, got %v and %v", c.name, ops, errs)
+		}
+		if len(latencies) != len(c.latencies) {
+			t.Errorf("%v: want latencies %v, got %v", c.name, c.latencies, latencies)
+		}
+		for op, want := range c.latencies {
+			if latencies[op] != want {
+				t.Errorf("%v: want latencies %v, got %v", c.name, c.latencies, latencies)
+				break
+			}
+		}
+	}
+}
+
+func TestPromRawFeed(t *testing.T) {
+	m := newPromMetrics()
+	globalProm = m
+	defer func() { globalProm = nil }()
+	start := time.Now()
+
+	// the raw series feeds Prometheus when the histograms are not collected
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "raw"))
+	p.Set(prop.MeasurementType, "raw")
+	RawInitMeasure(p)
+	RawMeasure(context.Background(), "READ", start, start, "key", nil)
+
+	// and leaves it to them otherwise, even after a raw only workload
+	p.Set(prop.MeasurementType, "raw,histogram")
+	InitMeasure(p)
+	RawInitMeasure(p)
+	defer func() { globalMeasure = nil }()
+	RawMeasure(context.Background(), "READ", start, start, "key", nil)
+	Measure("READ", start, start, "key", nil)
+	RawClose()
+
+	if got := testutil.ToFloat64(m.operations.WithLabelValues("READ")); got != 2 {
+		t.Errorf("want 2 reads, got %v", got)
+	}
+}
+
+func TestPromEventsAndFollowers(t *testing.T) {
+	m := newPromMetrics()
+	globalProm = m
+	defer func() { globalProm = nil }()
+
+	now := time.Unix(1700000000, 500000000)
+	promRecordEvent(now, "n1", "kill -KILL", nil)
+	promRecordEvent(now, "n2", "kill -KILL", errors.New("unreachable"))
+	RecordFollowerState("f1", true)
+	RecordFollowerState("f2", false)
+	BeginOperation()
+	BeginOperation()
+	EndOperation()
+
+	for _, c := range []struct {
+		name      string
+		collector prometheus.Collector
+		want      float64
+	}{
+		{"n1 fired", m.eventFired.WithLabelValues("n1", "kill -KILL"), 1700000000.5},
+		{"n1 status", m.eventStatus.WithLabelValues("n1", "kill -KILL"), 1},
+		{"n2 status", m.eventStatus.WithLabelValues("n2", "kill -KILL"), 0},
+		{"f1 started", m.follower.WithLabelValues("f1"), 1},
+		{"f2 started", m.follower.WithLabelValues("f2"), 0},
+		{"in flight", m.inFlight, 1},
+	} {
+		if got := testutil.ToFloat64(c.collector); got != c.want {
+			t.Errorf("%v: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestEnablePrometheus(t *testing.T) {
+	EnablePrometheus()
+	defer func() { globalProm = nil }()
+	start := time.Now()
+	globalProm.observe("READ", start, start.Add(time.Millisecond))
+
+	srv := httptest.NewServer(http.DefaultServeMux)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `ycsb_operations_total{operation="READ"} 1`; !strings.Contains(string(body), want) {
+		t.Errorf("want %q in the metrics, got %s", want, body)
+	}
+}
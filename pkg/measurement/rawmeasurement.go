@@ -2,11 +2,11 @@ package measurement
 
 import (
 	"fmt"
-	"github.com/pingcap/go-ycsb/pkg/ycsb"
-	"golang.org/x/crypto/openpgp/errors"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
 type rawmeasurement struct {
@@ -17,36 +17,48 @@ type rawmeasurement struct {
 	opVals  []interface{}
 }
 
+// rawseries holds the measurements taken by one worker thread of one run.
 type rawseries struct {
-	series *[]rawmeasurement
+	threadID int
+	runID    string
+	series   []rawmeasurement
 }
 
-func newRawSeries() *rawseries {
-	return &rawseries{series: new([]rawmeasurement)}
+func newRawSeries(threadID int, runID string, capacity int) *rawseries {
+	return &rawseries{
+		threadID: threadID,
+		runID:    runID,
+		series:   make([]rawmeasurement, 0, capacity),
+	}
 }
 
 func (r *rawseries) Measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
-	*r.series = append(*r.series, rawmeasurement{
+	r.series = append(r.series, rawmeasurement{
 		opType:  op,
 		opStart: start,
 		opEnd:   end,
 		opKey:   key,
 		opVals:  values,
 	})
-	//fmt.Printf("Latest Series : %+v\n", r.series)
+}
+
+// Len returns the number of measurements in the series.
+func (r *rawseries) Len() int {
+	return len(r.series)
 }
 
 func (r *rawseries) GetMeasurement(index int) ([]string, error) {
-	if len(*r.series) == 0 || index > len(*r.series) || index < 0 {
-		return nil, errors.InvalidArgumentError(index)
+	if index >= len(r.series) || index < 0 {
+		return nil, fmt.Errorf("measurement index %d out of range [0, %d)", index, len(r.series))
 	}
+	m := r.series[index]
 	line := []string{}
-	line = append(line, (*r.series)[index].opType)
-	line = append(line, strconv.FormatInt((*r.series)[index].opStart.UnixMilli(), 10))
-	line = append(line, strconv.FormatInt((*r.series)[index].opEnd.UnixMilli(), 10))
-	line = append(line, (*r.series)[index].opKey)
+	line = append(line, m.opType)
+	line = append(line, strconv.FormatInt(m.opStart.UnixNano(), 10))
+	line = append(line, strconv.FormatInt(m.opEnd.UnixNano(), 10))
+	line = append(line, m.opKey)
 	var vals []string
-	for _, v := range (*r.series)[index].opVals {
+	for _, v := range m.opVals {
 		switch t := v.(type) {
 		case []byte:
 			vals = append(vals, fmt.Sprintf("%v", string(t)))
@@ -56,6 +68,8 @@ func (r *rawseries) GetMeasurement(index int) ([]string, error) {
 
 	}
 	line = append(line, strings.Join(vals, ","))
+	line = append(line, strconv.Itoa(r.threadID))
+	line = append(line, r.runID)
 
 	return line, nil
 }
@@ -67,7 +81,7 @@ func (r *rawseries) Summary() []string {
 
 func (r *rawseries) Info() ycsb.MeasurementInfo {
 	tempInfo := make(map[string]interface{})
-	tempInfo["len"] = len(*r.series)
+	tempInfo["len"] = len(r.series)
 	return newRawmeasurementInfo(tempInfo)
 }
 
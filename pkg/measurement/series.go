@@ -14,71 +14,228 @@
 package measurement
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
 	"fmt"
-	"github.com/pingcap/go-ycsb/pkg/util"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/magiconair/properties"
 	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
-var seriesheader = []string{"Operation", "Start", "End", "Key", "Value(s)"}
+var seriesheader = []string{"Operation", "Start", "End", "Key", "Value(s)", "Thread", "RunID"}
+
+// seriesQueueSize is the number of flushed batches that may wait for the
+// background writer before the measuring threads block.
+const seriesQueueSize = 64
+
+type contextKey string
 
+const rawBufferKey = contextKey("rawbuffer")
+
+// seriesBatch is a unit of work for the background writer. A batch either
+// carries measurements or, when rotate is set, asks the writer to close the
+// current output file and acknowledge on the channel.
+type seriesBatch struct {
+	rawSeries *rawseries
+	rotate    chan struct{}
+}
+
+// threadBuffer collects the raw measurements of one worker thread, so that
+// threads never contend with each other while measuring.
+type threadBuffer struct {
+	sync.Mutex
+
+	s         *series
+	threadID  int
+	rawSeries *rawseries
+}
+
+func (b *threadBuffer) measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
+	b.Lock()
+	b.rawSeries.Measure(op, start, end, key, values)
+	if b.rawSeries.Len() < b.s.flushSize {
+		b.Unlock()
+		return
+	}
+	full := b.rawSeries
+	b.rawSeries = b.s.newRawSeries(b.threadID)
+	b.Unlock()
+
+	b.s.submit(full)
+}
+
+func (b *threadBuffer) flush() {
+	b.Lock()
+	if b.rawSeries.Len() == 0 {
+		b.Unlock()
+		return
+	}
+	full := b.rawSeries
+	b.rawSeries = b.s.newRawSeries(b.threadID)
+	b.Unlock()
+
+	b.s.submit(full)
+}
+
+// series streams raw measurements to the output through a background writer.
 type series struct {
 	sync.RWMutex
 
 	p *properties.Properties
 
-	rawSeries *rawseries
+	runID     string
+	flushSize int
+	closed    bool
+	// histogram tells whether the histograms are collected next to the series
+	histogram bool
+	batches   chan seriesBatch
+	done      chan struct{}
+
+	buffersLock sync.Mutex
+	buffers     map[*threadBuffer]struct{}
+	// shared is used by callers which did not register a thread buffer.
+	shared *threadBuffer
+
+	count int64
+
+	// The fields below are only touched by the writer goroutine.
+	fileHandle *os.File
+	interval   int
+	bufWriter  *bufio.Writer
+	csvWriter  *csv.Writer
+	// writeErr is the first error writing the current file
+	writeErr error
 }
 
-func (s *series) measure(op string, start time.Time, end time.Time, key string, values []interface{}) {
-	s.Lock()
-	defer s.Unlock()
-	if s.rawSeries == nil {
-		s.rawSeries = newRawSeries()
+func newSeries(p *properties.Properties) *series {
+	s := new(series)
+	s.p = p
+	s.runID = runID(p)
+	s.flushSize = p.GetInt(prop.RawFlushSize, prop.RawFlushSizeDefault)
+	if s.flushSize <= 0 {
+		s.flushSize = prop.RawFlushSizeDefault
 	}
-	(s.rawSeries).Measure(op, start, end, key, values)
+	s.batches = make(chan seriesBatch, seriesQueueSize)
+	s.done = make(chan struct{})
+	s.buffers = make(map[*threadBuffer]struct{})
+	s.shared = s.newThreadBuffer(-1)
+
+	go s.writeLoop()
+	return s
+}
+
+func (s *series) newRawSeries(threadID int) *rawseries {
+	return newRawSeries(threadID, s.runID, s.flushSize)
+}
+
+func (s *series) newThreadBuffer(threadID int) *threadBuffer {
+	b := &threadBuffer{s: s, threadID: threadID, rawSeries: s.newRawSeries(threadID)}
+	s.buffersLock.Lock()
+	s.buffers[b] = struct{}{}
+	s.buffersLock.Unlock()
+	return b
+}
+
+func (s *series) releaseThreadBuffer(b *threadBuffer) {
+	b.flush()
+	s.buffersLock.Lock()
+	delete(s.buffers, b)
+	s.buffersLock.Unlock()
 }
 
+// submit hands a full batch to the writer, blocking while the queue is full
+// so that memory stays bounded when the disk cannot keep up.
+func (s *series) submit(r *rawseries) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.closed {
+		return
+	}
+	atomic.AddInt64(&s.count, int64(r.Len()))
+	s.batches <- seriesBatch{rawSeries: r}
+}
+
+func (s *series) measure(ctx context.Context, op string, start time.Time, end time.Time, key string, values []interface{}) {
+	b, ok := ctx.Value(rawBufferKey).(*threadBuffer)
+	if !ok || b.s != s {
+		b = s.shared
+	}
+	b.measure(op, start, end, key, values)
+}
+
+// output flushes every thread buffer and closes the output file of the
+// current interval.
 func (s *series) output() {
+	s.buffersLock.Lock()
+	buffers := make([]*threadBuffer, 0, len(s.buffers))
+	for b := range s.buffers {
+		buffers = append(buffers, b)
+	}
+	s.buffersLock.Unlock()
+
+	for _, b := range buffers {
+		b.flush()
+	}
+
+	s.RLock()
+	if s.closed {
+		s.RUnlock()
+		return
+	}
+	ack := make(chan struct{})
+	s.batches <- seriesBatch{rotate: ack}
+	s.RUnlock()
+	<-ack
+}
+
+func (s *series) close() {
+	s.output()
+
 	s.Lock()
-	defer s.Unlock()
-	//fmt.Printf("%+v\n", s.rawSeries)
+	if s.closed {
+		s.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.batches)
+	s.Unlock()
+	<-s.done
+}
 
-	lines := [][]string{}
-	var length int
-	length = (s.rawSeries).Info().Get("len").(int)
-	//fmt.Printf("Series Length: %v\n", length)
+func (s *series) writeLoop() {
+	defer close(s.done)
 
-	for i := 0; i < length; i++ {
-		meas, err := (s.rawSeries).GetMeasurement(i)
+	for batch := range s.batches {
+		if batch.rotate != nil {
+			s.closeFile()
+			s.interval++
+			close(batch.rotate)
+			continue
+		}
+		s.write(batch.rawSeries)
+	}
+	s.closeFile()
+}
+
+func (s *series) write(r *rawseries) {
+	lines := make([][]string, 0, r.Len())
+	for i := 0; i < r.Len(); i++ {
+		meas, err := r.GetMeasurement(i)
 		if err == nil {
 			lines = append(lines, meas)
 		} else {
 			fmt.Printf("%v\n", err.Error())
 		}
 	}
-	//fmt.Printf("%+v\n", lines)
 
 	outputStyle := s.p.GetString(prop.OutputStyle, util.OutputStyleCSV)
-	filename := s.p.GetString(prop.CSVFileName, prop.Workload)
-	followerName := s.p.GetString(prop.FollowerName, "primary")
-
-	fileHandle, err := os.Create(fmt.Sprintf(
-		"%v_%v_%v_%v.csv",
-		filename,
-		followerName,
-		time.Now().UnixMilli(),
-		s.rawSeries.Info().Get("len"),
-	))
-	if err != nil {
-		fileHandle = nil
-	}
-
 	switch outputStyle {
 	case util.OutputStylePlain:
 		util.RenderString("%-6s - %s\n", seriesheader, lines)
@@ -87,49 +244,146 @@ func (s *series) output() {
 	case util.OutputStyleTable:
 		util.RenderTable(seriesheader, lines)
 	case util.OutputStyleCSV:
-		util.RenderCSV(seriesheader, lines, fileHandle)
+		s.writeCSV(lines)
 	default:
 		panic("unsupported outputstyle: " + outputStyle)
 	}
-	//fmt.Printf("Completed output of %v\n", fileHandle)
 }
 
-func (s *series) info() ycsb.MeasurementInfo {
-	s.RLock()
-	defer s.RUnlock()
+func (s *series) writeCSV(lines [][]string) {
+	if len(lines) == 0 {
+		return
+	}
 
-	return (s.rawSeries).Info()
+	if s.csvWriter == nil {
+		fileHandle, err := os.Create(s.fileName())
+		if err != nil {
+			fmt.Printf("Error creating raw output file [%v], writing to stdout\n", err.Error())
+			s.bufWriter = bufio.NewWriter(os.Stdout)
+		} else {
+			s.fileHandle = fileHandle
+			s.bufWriter = bufio.NewWriter(fileHandle)
+		}
+		s.csvWriter = csv.NewWriter(s.bufWriter)
+		s.csvWriter.Write(seriesheader)
+	}
+
+	s.writeFailed(s.csvWriter.WriteAll(lines))
+}
+
+// writeFailed reports the first error writing the output file of the
+// current interval, the lines written after it may be lost.
+func (s *series) writeFailed(err error) {
+	if err == nil || s.writeErr != nil {
+		return
+	}
+	s.writeErr = err
+	name := "stdout"
+	if s.fileHandle != nil {
+		name = s.fileHandle.Name()
+	}
+	fmt.Printf("Error writing raw output file %v [%v]\n", name, err.Error())
+}
+
+// fileName returns the output file of the current interval, in the form
+// <csvfilename>_<follower>_<runid>_<interval>.csv.
+func (s *series) fileName() string {
+	return fmt.Sprintf("%v_%04d.csv", rawFilePrefix(s.p, s.p.GetString(prop.FollowerName, "primary"), s.runID), s.interval)
 }
 
-// RawInitMeasure initializes the global measurement.
+// RawFilePrefix returns the <csvfilename>_<follower>_<runid> prefix of the
+// raw output files of the run, which the files kept next to them share.
+func RawFilePrefix(p *properties.Properties) string {
+	return rawFilePrefix(p, p.GetString(prop.FollowerName, "primary"), runID(p))
+}
+
+// runID returns the run id of p, setting it to the current time when it has
+// none so the output files of the run agree on it
+func runID(p *properties.Properties) string {
+	if id, ok := p.Get(prop.RunID); ok {
+		return id
+	}
+	id := time.Now().Format(prop.RunIDLayout)
+	p.Set(prop.RunID, id)
+	return id
+}
+
+func rawFilePrefix(p *properties.Properties, follower, runID string) string {
+	filename := p.GetString(prop.CSVFileName, prop.Workload)
+	return fmt.Sprintf("%v_%v_%v", filename, follower, runID)
+}
+
+// closeFile flushes and closes the output file of the current interval.
+func (s *series) closeFile() {
+	if s.csvWriter == nil {
+		return
+	}
+	s.csvWriter.Flush()
+	s.writeFailed(s.csvWriter.Error())
+	s.writeFailed(s.bufWriter.Flush())
+
+	if s.fileHandle != nil {
+		s.writeFailed(s.fileHandle.Close())
+	}
+
+	s.fileHandle = nil
+	s.bufWriter = nil
+	s.csvWriter = nil
+	s.writeErr = nil
+}
+
+func (s *series) info() ycsb.MeasurementInfo {
+	tempInfo := make(map[string]interface{})
+	tempInfo["len"] = int(atomic.LoadInt64(&s.count))
+	return newRawmeasurementInfo(tempInfo)
+}
+
+// RawInitMeasure initializes the global measurement. The histograms of a
+// previous workload are dropped when this one does not collect them.
 func RawInitMeasure(p *properties.Properties) {
-	globalRawMeasure = new(series)
-	globalRawMeasure.p = p
-	globalRawMeasure.rawSeries = newRawSeries()
+	if globalRawMeasure != nil {
+		globalRawMeasure.close()
+	}
+	globalRawMeasure = newSeries(p)
+	globalRawMeasure.histogram = HistogramCollected(p)
+	if !globalRawMeasure.histogram {
+		globalMeasure = nil
+	}
+	initLatency(p)
+	resetProgress()
 	EnableWarmUp(p.GetInt64(prop.WarmUpTime, 0) > 0)
 }
 
-// RawOutput prints the measurement summary.
-func RawOutput() {
-	var outputReference *rawseries
-
-	globalRawMeasure.Lock()
-	outputReference = globalRawMeasure.rawSeries
-	globalRawMeasure.rawSeries = newRawSeries()
-	globalRawMeasure.Unlock()
+// RawInitThread attaches a per-thread measurement buffer to the context.
+func RawInitThread(ctx context.Context, threadID int) context.Context {
+	return context.WithValue(ctx, rawBufferKey, globalRawMeasure.newThreadBuffer(threadID))
+}
 
-	var outputSeries = &series{
-		RWMutex:   sync.RWMutex{},
-		p:         globalRawMeasure.p,
-		rawSeries: outputReference,
+// RawCleanupThread flushes and releases the buffer attached by RawInitThread.
+func RawCleanupThread(ctx context.Context) {
+	if b, ok := ctx.Value(rawBufferKey).(*threadBuffer); ok {
+		b.s.releaseThreadBuffer(b)
 	}
-	outputSeries.output()
+}
+
+// RawOutput flushes the measurements taken so far to the output.
+func RawOutput() {
+	globalRawMeasure.output()
+}
+
+// RawClose flushes the remaining measurements and stops the background writer.
+func RawClose() {
+	globalRawMeasure.close()
 }
 
 // RawMeasure measures the operation.
-func RawMeasure(op string, start time.Time, end time.Time, key string, values []interface{}) {
-	if IsWarmUpFinished() {
-		globalRawMeasure.measure(op, start, end, key, values)
+func RawMeasure(ctx context.Context, op string, start time.Time, end time.Time, key string, values []interface{}) {
+	if globalRawMeasure != nil && IsWarmUpFinished() {
+		globalRawMeasure.measure(ctx, op, start, end, key, values)
+		// When the histograms are collected too, Measure feeds Prometheus.
+		if globalProm != nil && !globalRawMeasure.histogram {
+			globalProm.observeRaw(op, start, end)
+		}
 	}
 }
 
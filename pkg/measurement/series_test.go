@@ -0,0 +1,96 @@
+package measurement
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestSeriesStreamsToCSV(t *testing.T) {
+	dir := t.TempDir()
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, filepath.Join(dir, "raw"))
+	p.Set(prop.RawFlushSize, "8")
+	p.Set(prop.RunID, "test")
+
+	s := newSeries(p)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			b := s.newThreadBuffer(threadID)
+			ctx := context.WithValue(context.Background(), rawBufferKey, b)
+			for j := 0; j < 100; j++ {
+				now := time.Now()
+				s.measure(ctx, "READ", now, now, "key", []interface{}{[]byte("value")})
+			}
+			s.releaseThreadBuffer(b)
+		}(i)
+	}
+	wg.Wait()
+	s.close()
+
+	f, err := os.Open(filepath.Join(dir, "raw_primary_test_0000.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 401 {
+		t.Fatalf("want 401 records including header, got %d", len(records))
+	}
+	if records[0][0] != "Operation" || records[1][0] != "READ" || records[1][4] != "value" || records[1][6] != "test" {
+		t.Fatalf("unexpected records %v %v", records[0], records[1])
+	}
+}
+
+func TestRunIDDefaultsOnce(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, "raw")
+
+	id := runID(p)
+	if _, err := time.Parse(prop.RunIDLayout, id); err != nil {
+		t.Fatalf("run id %v does not follow the layout: %v", id, err)
+	}
+	time.Sleep(time.Millisecond)
+	if got := RawFilePrefix(p); got != "raw_primary_"+id {
+		t.Fatalf("want the run id %v in the prefix, got %v", id, got)
+	}
+	if next := time.Now().Format(prop.RunIDLayout); next == id {
+		t.Fatalf("run ids a millisecond apart collide: %v", id)
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestSeriesReportsWriteErrors(t *testing.T) {
+	s := &series{p: properties.NewProperties()}
+	s.bufWriter = bufio.NewWriterSize(failingWriter{}, 16)
+	s.csvWriter = csv.NewWriter(s.bufWriter)
+
+	s.writeCSV([][]string{{"READ", "1", "2", "key", "value", "0", "test"}})
+	if s.writeErr == nil || s.writeErr.Error() != "disk full" {
+		t.Fatalf("want the write error kept, got %v", s.writeErr)
+	}
+	s.closeFile()
+	if s.writeErr != nil || s.csvWriter != nil {
+		t.Errorf("want the next file to start without error, got %v", s.writeErr)
+	}
+}
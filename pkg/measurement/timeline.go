@@ -0,0 +1,254 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// Timeline row types.
+const (
+	TimelineMetric = "metric"
+	TimelineEvent  = "event"
+)
+
+const errorSuffix = "_ERROR"
+
+// Columns of a timeline row, in the order of timelineHeader.
+const (
+	colTime = iota
+	colElapsed
+	colInterval
+	colType
+	colOperation
+	colCount
+	colOPS
+	colErrors
+	colAvg
+	colMin
+	colMax
+	colPer99th
+	colPer999th
+	colPer9999th
+	colNodeID
+	colCommand
+	colStatus
+	colMessage
+	colTarget
+	colThreads
+)
+
+var timelineHeader = []string{"Time", "Elapsed(s)", "Interval", "Type", "Operation", "Count", "OPS", "Errors",
+	"Avg(us)", "Min(us)", "Max(us)", "99th(us)", "99.9th(us)", "99.99th(us)", "NodeID", "Command", "Status", "Message", "Target(ops/s)", "Threads"}
+
+// timeline exports the interval metrics and the injected events to a
+// time-indexed CSV and/or JSON lines file.
+type timeline struct {
+	sync.Mutex
+
+	startTime time.Time
+	closed    bool
+
+	csvFile   *os.File
+	csvWriter *csv.Writer
+
+	jsonFile    *os.File
+	jsonEncoder *json.Encoder
+}
+
+func newTimeline(p *properties.Properties) (*timeline, error) {
+	t := new(timeline)
+	t.startTime = time.Now()
+
+	prefix := p.GetString(prop.Timeline, "")
+	followerName := p.GetString(prop.FollowerName, "primary")
+	fileName := fmt.Sprintf("%v_%v_%v", prefix, followerName, runID(p))
+
+	for _, format := range strings.Split(p.GetString(prop.TimelineFormat, prop.TimelineFormatDefault), ",") {
+		switch strings.TrimSpace(format) {
+		case util.OutputStyleCSV:
+			f, err := os.Create(fileName + ".csv")
+			if err != nil {
+				t.close()
+				return nil, err
+			}
+			t.csvFile = f
+			t.csvWriter = csv.NewWriter(f)
+			t.csvWriter.Write(timelineHeader)
+		case util.OutputStyleJson:
+			f, err := os.Create(fileName + ".jsonl")
+			if err != nil {
+				t.close()
+				return nil, err
+			}
+			t.jsonFile = f
+			t.jsonEncoder = json.NewEncoder(f)
+		default:
+			t.close()
+			return nil, fmt.Errorf("unsupported timeline format: %v", format)
+		}
+	}
+
+	return t, nil
+}
+
+// writeIntervals writes one row per operation type with the metrics of the
+// interval that just ended. Failed operations are counted as the errors of
+// their operation type.
+func (t *timeline) writeIntervals(now time.Time, interval int, infos map[string]map[string]interface{}) {
+	ops := make(map[string]struct{}, len(infos))
+	for op := range infos {
+		ops[strings.TrimSuffix(op, errorSuffix)] = struct{}{}
+	}
+	names := make([]string, 0, len(ops))
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+
+	load, hasLoad := globalLoad.Load().(activeLoad)
+	rows := make([][]string, 0, len(names))
+	for _, op := range names {
+		row := t.newRow(now, TimelineMetric)
+		row[colInterval] = strconv.Itoa(interval)
+		if hasLoad {
+			row[colTarget] = util.FloatToOneString(load.target)
+			row[colThreads] = strconv.Itoa(load.threads)
+		}
+		row[colOperation] = op
+		row[colErrors] = "0"
+		if errInfo, ok := infos[op+errorSuffix]; ok {
+			row[colErrors] = util.IntToString(errInfo[COUNT])
+		}
+		if info, ok := infos[op]; ok {
+			row[colCount] = util.IntToString(info[COUNT])
+			row[colOPS] = util.FloatToOneString(info[QPS])
+			row[colAvg] = util.IntToString(info[AVG])
+			row[colMin] = util.IntToString(info[MIN])
+			row[colMax] = util.IntToString(info[MAX])
+			row[colPer99th] = util.IntToString(info[PER99TH])
+			row[colPer999th] = util.IntToString(info[PER999TH])
+			row[colPer9999th] = util.IntToString(info[PER9999TH])
+		} else {
+			row[colCount] = "0"
+			row[colOPS] = util.FloatToOneString(0.0)
+		}
+		rows = append(rows, row)
+	}
+
+	t.write(rows)
+}
+
+// writeEvent writes a marker for an action fired against a node.
+func (t *timeline) writeEvent(now time.Time, nodeID string, command string, err error) {
+	row := t.newRow(now, TimelineEvent)
+	row[colNodeID] = nodeID
+	row[colCommand] = command
+	if err != nil {
+		row[colStatus] = "failure"
+		row[colMessage] = err.Error()
+	} else {
+		row[colStatus] = "success"
+	}
+
+	t.write([][]string{row})
+}
+
+func (t *timeline) newRow(now time.Time, rowType string) []string {
+	row := make([]string, len(timelineHeader))
+	row[colTime] = strconv.FormatInt(now.UnixMilli(), 10)
+	row[colElapsed] = fmt.Sprintf("%.3f", now.Sub(t.startTime).Seconds())
+	row[colType] = rowType
+	return row
+}
+
+func (t *timeline) write(rows [][]string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		return
+	}
+
+	if t.csvWriter != nil {
+		t.csvWriter.WriteAll(rows)
+	}
+	if t.jsonEncoder != nil {
+		for _, row := range rows {
+			line := make(map[string]string, len(timelineHeader))
+			for i, header := range timelineHeader {
+				if row[i] != "" {
+					line[header] = row[i]
+				}
+			}
+			t.jsonEncoder.Encode(line)
+		}
+	}
+}
+
+func (t *timeline) close() {
+	t.Lock()
+	defer t.Unlock()
+	t.closed = true
+
+	if t.csvWriter != nil {
+		t.csvWriter.Flush()
+	}
+	if t.csvFile != nil {
+		t.csvFile.Close()
+	}
+	if t.jsonFile != nil {
+		t.jsonFile.Close()
+	}
+}
+
+// TimelineEnabled returns whether the timeline exporter is configured.
+func TimelineEnabled(p *properties.Properties) bool {
+	return p.GetString(prop.Timeline, "") != ""
+}
+
+// RecordEvent adds a marker for an action fired against a node to the
+// timeline and to the Prometheus metrics, when they are enabled.
+func RecordEvent(nodeID string, command string, err error) {
+	now := time.Now()
+	t := globalTimeline
+	if t != nil {
+		t.writeEvent(now, nodeID, command, err)
+	}
+	promRecordEvent(now, nodeID, command, err)
+}
+
+// CloseTimeline writes the interval which ends the run, then flushes and
+// closes the timeline files.
+func CloseTimeline() {
+	if globalTimeline != nil {
+		if globalMeasure != nil {
+			globalMeasure.lastInterval()
+		}
+		globalTimeline.close()
+	}
+}
+
+var globalTimeline *timeline
@@ -0,0 +1,54 @@
+package measurement
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestTimelineWritesLastInterval(t *testing.T) {
+	dir := t.TempDir()
+	p := properties.NewProperties()
+	p.Set(prop.Timeline, filepath.Join(dir, "timeline"))
+	p.Set(prop.RunID, "test")
+	InitMeasure(p)
+	defer func() { globalMeasure, globalTimeline = nil, nil }()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		Measure("READ", start, start.Add(time.Millisecond), "", nil)
+	}
+	IntervalOutput()
+	for i := 0; i < 3; i++ {
+		Measure("READ", start, start.Add(time.Millisecond), "", nil)
+	}
+	time.Sleep(50 * time.Millisecond)
+	CloseTimeline()
+
+	f, err := os.Open(filepath.Join(dir, "timeline_primary_test.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("want the header and two intervals, got %v", rows)
+	}
+	last := rows[2]
+	if last[colInterval] != "2" || last[colCount] != "3" {
+		t.Fatalf("want the 3 operations of the last interval, got %v", last)
+	}
+	// 3 operations over the last window of at least 50ms
+	if ops, _ := strconv.ParseFloat(last[colOPS], 64); ops <= 0 || ops > 60 {
+		t.Fatalf("want the throughput of the real length of the last window, got %v", last[colOPS])
+	}
+}
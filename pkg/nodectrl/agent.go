@@ -0,0 +1,583 @@
+package nodectrl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// The HTTP interface of an agent:
+//
+//	POST /run     runs a workload as a follower of the leader, see agentRunRequest
+//	GET  /output  streams the output of the run of ?follower= until it exits
+//	GET  /files   lists the files of ?follower= starting with the ?prefix= values
+//	GET  /file    returns the file ?name= of the working directory
+//	GET  /status  answers when the agent is up
+//
+// Every request carries the agent.token shared by the agent and its leaders
+// as "Authorization: Bearer <token>".
+const (
+	agentPathRun    = "/run"
+	agentPathOutput = "/output"
+	agentPathFiles  = "/files"
+	agentPathFile   = "/file"
+	agentPathStatus = "/status"
+)
+
+var errNoAgentToken = errors.New("an agent follower needs agent.token, the secret shared with its agent")
+
+// agentOutputWait bounds the wait for the end of the output of a follower
+// run before its files are downloaded
+const agentOutputWait = 5 * time.Second
+
+type agentRunRequest struct {
+	Follower string `json:"follower"`
+	DB       string `json:"db"`
+	// Workload is the content of the property file of the workload
+	Workload string            `json:"workload"`
+	Props    map[string]string `json:"props"`
+}
+
+type agentRunResponse struct {
+	Pid int `json:"pid"`
+}
+
+type agentFilesResponse struct {
+	// Dir is the absolute working directory of the agent
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"`
+}
+
+// Agent runs the workloads leaders send it as follower runs of go-ycsb in
+// its working directory, and serves their output and files. It runs what it
+// is sent, so it only serves the requests carrying its token.
+type Agent struct {
+	executable string
+	dir        string
+	token      string
+	listener   net.Listener
+	server     *http.Server
+
+	mu   sync.Mutex
+	runs map[string]*agentRun
+}
+
+// agentRun is a follower run of the agent
+type agentRun struct {
+	cmd      *exec.Cmd
+	output   *runOutput
+	workload string
+	done     chan struct{}
+}
+
+// NewAgent listens on agent.listen and serves the runs of the leaders which
+// send agent.token
+func NewAgent(p *properties.Properties) (*Agent, error) {
+	token := p.GetString(prop.AgentToken, "")
+	if token == "" {
+		return nil, errors.New("an agent needs the agent.token its leaders send, see --token")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(p.GetString(prop.AgentDir, prop.AgentDirDefault))
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", p.GetString(prop.AgentListen, prop.AgentListenDefault))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Agent{
+		executable: executable,
+		dir:        dir,
+		token:      token,
+		listener:   listener,
+		runs:       make(map[string]*agentRun),
+	}
+	a.server = &http.Server{Handler: a.handler()}
+
+	go a.server.Serve(listener)
+	return a, nil
+}
+
+// handler returns the handler of the agent endpoints, which checks the token
+// of every request
+func (a *Agent) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(agentPathRun, a.handleRun)
+	mux.HandleFunc(agentPathOutput, a.handleOutput)
+	mux.HandleFunc(agentPathFiles, a.handleFiles)
+	mux.HandleFunc(agentPathFile, a.handleFile)
+	mux.HandleFunc(agentPathStatus, func(w http.ResponseWriter, r *http.Request) {})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorized(r) {
+			http.Error(w, "bad or missing agent token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized returns whether the request carries the token of the agent
+func (a *Agent) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// Addr returns the address the agent listens on
+func (a *Agent) Addr() string {
+	return a.listener.Addr().String()
+}
+
+// Close stops serving and stops the runs still going: SIGTERM first, and
+// SIGKILL after nodes.stoptimeout
+func (a *Agent) Close() error {
+	err := a.server.Close()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, run := range a.runs {
+		select {
+		case <-run.done:
+			continue
+		default:
+		}
+		log.Printf("Stopping the run of follower %v", id)
+		syscall.Kill(-run.cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-run.done:
+		case <-time.After(globalStopTimeout):
+			syscall.Kill(-run.cmd.Process.Pid, syscall.SIGKILL)
+			<-run.done
+		}
+	}
+	return err
+}
+
+func (a *Agent) handleRun(w http.ResponseWriter, r *http.Request) {
+	var req agentRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Follower == "" || req.DB == "" || !isBaseName(req.Follower) {
+		http.Error(w, "a run needs a follower and a db", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if run := a.runs[req.Follower]; run != nil {
+		select {
+		case <-run.done:
+		default:
+			http.Error(w, fmt.Sprintf("follower %v is already running", req.Follower), http.StatusConflict)
+			return
+		}
+	}
+
+	leaderHost, _, _ := net.SplitHostPort(r.RemoteAddr)
+	run, err := a.start(req, leaderHost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.runs[req.Follower] = run
+	log.Printf("Running follower %v, pid %v", req.Follower, run.cmd.Process.Pid)
+	json.NewEncoder(w).Encode(agentRunResponse{Pid: run.cmd.Process.Pid})
+}
+
+// start launches go-ycsb run in its own process group, with its output in
+// follower_<id>.log. A control.leader of the form :port is completed with
+// the address the leader request came from.
+func (a *Agent) start(req agentRunRequest, leaderHost string) (*agentRun, error) {
+	args := []string{"run", req.DB, "-F", req.Follower}
+	run := &agentRun{done: make(chan struct{})}
+	if req.Workload != "" {
+		f, err := os.CreateTemp("", "go-ycsb-workload-*.properties")
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.WriteString(req.Workload)
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+			return nil, err
+		}
+		run.workload = f.Name()
+		args = append(args, "-P", run.workload)
+	}
+	for key, value := range req.Props {
+		if key == prop.ControlLeader && strings.HasPrefix(value, ":") {
+			value = net.JoinHostPort(leaderHost, value[1:])
+		}
+		args = append(args, "-p", key+"="+value)
+	}
+
+	output, err := newRunOutput(filepath.Join(a.dir, fmt.Sprintf("follower_%v.log", req.Follower)))
+	if err != nil {
+		run.removeWorkload()
+		return nil, err
+	}
+	run.output = output
+	run.cmd = exec.Command(a.executable, args...)
+	run.cmd.Dir = a.dir
+	run.cmd.Stdout = output
+	run.cmd.Stderr = output
+	run.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = run.cmd.Start(); err != nil {
+		output.Close()
+		run.removeWorkload()
+		return nil, err
+	}
+
+	go func() {
+		err := run.cmd.Wait()
+		fmt.Fprintf(output, "[agent] run exited: %v\n", exitString(err))
+		output.Close()
+		run.removeWorkload()
+		close(run.done)
+	}()
+	return run, nil
+}
+
+func (run *agentRun) removeWorkload() {
+	if run.workload != "" {
+		os.Remove(run.workload)
+	}
+}
+
+func exitString(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+func (a *Agent) handleOutput(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	run := a.runs[r.URL.Query().Get("follower")]
+	a.mu.Unlock()
+	if run == nil {
+		http.Error(w, "unknown follower", http.StatusNotFound)
+		return
+	}
+	run.output.stream(r.Context(), w)
+}
+
+func (a *Agent) handleFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	follower := query.Get("follower")
+	if follower == "" {
+		http.Error(w, "the files need a follower", http.StatusBadRequest)
+		return
+	}
+	for _, prefix := range query["prefix"] {
+		// an empty prefix would match every file
+		if prefix == "" {
+			http.Error(w, "empty file prefix", http.StatusBadRequest)
+			return
+		}
+	}
+	prefixes := append(query["prefix"], "follower_"+follower)
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := agentFilesResponse{Dir: a.dir}
+	for _, e := range entries {
+		if e.Type().IsRegular() && hasFilePrefix(e.Name(), prefixes) {
+			resp.Files = append(resp.Files, e.Name())
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (a *Agent) handleFile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if !isBaseName(name) {
+		http.Error(w, "bad file name", http.StatusBadRequest)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(a.dir, name))
+}
+
+// isBaseName returns whether the name is the name of a file of the working
+// directory and not a path
+func isBaseName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+}
+
+// runOutput writes the output of a run to its log file and wakes up the
+// streams reading it
+type runOutput struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+func newRunOutput(path string) (*runOutput, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	o := &runOutput{file: f}
+	o.cond = sync.NewCond(&o.mu)
+	return o, nil
+}
+
+func (o *runOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n, err := o.file.Write(p)
+	o.size += int64(n)
+	o.cond.Broadcast()
+	return n, err
+}
+
+// Close ends the output and the streams once they read all of it
+func (o *runOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.cond.Broadcast()
+	return o.file.Close()
+}
+
+// stream copies the output to w from its start as it is written, until the
+// run exits or the context is done
+func (o *runOutput) stream(ctx context.Context, w http.ResponseWriter) {
+	f, err := os.Open(o.file.Name())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// wake up the wait when the reader goes away
+	streamDone := make(chan struct{})
+	defer close(streamDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			o.mu.Lock()
+			o.cond.Broadcast()
+			o.mu.Unlock()
+		case <-streamDone:
+		}
+	}()
+
+	flusher, _ := w.(http.Flusher)
+	var offset int64
+	for {
+		o.mu.Lock()
+		for o.size <= offset && !o.closed && ctx.Err() == nil {
+			o.cond.Wait()
+		}
+		size, closed := o.size, o.closed
+		o.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+
+		n, err := io.CopyN(w, f, size-offset)
+		offset += n
+		if err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if closed && offset >= size {
+			return
+		}
+	}
+}
+
+// agentURL returns the URL of the path on the agent of the follower
+func (f *Follower) agentURL(path string, query url.Values) string {
+	u := url.URL{Scheme: "http", Host: f.Agent, Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// agentRequest sends the request with agent.token to the agent of the
+// follower, and returns its reply if it succeeded
+func (f *Follower) agentRequest(client *http.Client, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, f.agentURL(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+globalAgentToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = agentError(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// startAgentRun sends the workload to the agent of the follower to run, and
+// streams the output of the run to the standard output
+func (f *Follower) startAgentRun(dbName, workload string, props map[string]string) error {
+	if globalAgentToken == "" {
+		return errNoAgentToken
+	}
+	req := agentRunRequest{Follower: f.Id, DB: dbName, Props: props}
+	if workload != "" {
+		content, err := os.ReadFile(workload)
+		if err != nil {
+			return err
+		}
+		req.Workload = string(content)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.agentRequest(http.DefaultClient, http.MethodPost, agentPathRun, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	f.outputDone = make(chan struct{})
+	go f.streamAgentOutput()
+	return nil
+}
+
+// streamAgentOutput prints the output of the run of the follower, each line
+// prefixed with the follower, until the run exits
+func (f *Follower) streamAgentOutput() {
+	defer close(f.outputDone)
+	resp, err := f.agentRequest(http.DefaultClient, http.MethodGet, agentPathOutput, url.Values{"follower": {f.Id}}, nil)
+	if err != nil {
+		log.Printf("Error streaming the output of follower %v: %v", f.Id, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewReader(resp.Body)
+	for {
+		line, err := lines.ReadString('\n')
+		if line = strings.TrimRight(line, "\n"); line != "" {
+			fmt.Printf("[follower %v] %v\n", f.Id, line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// getAgentFiles downloads the files of the follower from its agent, whose
+// name starts with one of the prefixes, unless the agent runs in the working
+// directory of the leader
+func (f *Follower) getAgentFiles(prefixes []string) error {
+	select {
+	case <-f.outputDone:
+	case <-time.After(agentOutputWait):
+		log.Printf("Output of follower %v still going, downloading its files anyway", f.Id)
+	}
+
+	resp, err := f.agentRequest(http.DefaultClient, http.MethodGet, agentPathFiles, url.Values{"follower": {f.Id}, "prefix": prefixes}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var files agentFilesResponse
+	if err = json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return err
+	}
+
+	if wd, err := os.Getwd(); err == nil && sameDir(wd, files.Dir) {
+		return nil
+	}
+	for _, name := range files.Files {
+		if err := f.downloadAgentFile(name); err != nil {
+			log.Printf("Error downloading file %v from follower %v: %v", name, f.Id, err)
+		}
+	}
+	return nil
+}
+
+func (f *Follower) downloadAgentFile(name string) error {
+	resp, err := f.agentRequest(http.DefaultClient, http.MethodGet, agentPathFile, url.Values{"name": {name}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dst, err := os.Create("./" + name)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+// checkAgent asks the agent of the follower whether it is up
+func (f *Follower) checkAgent() error {
+	client := &http.Client{Timeout: globalNodeTimeout}
+	resp, err := f.agentRequest(client, http.MethodGet, agentPathStatus, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// agentError returns the error of a failed agent reply
+func agentError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("agent: %v %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// sameDir returns whether the paths are the same directory
+func sameDir(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+var globalAgentToken string
@@ -0,0 +1,281 @@
+package nodectrl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+const testAgentToken = "secret"
+
+// fakeRun stands for go-ycsb in the runs of the test agents: it prints its
+// arguments and its workload, and writes a raw output file of its follower
+const fakeRun = `#!/bin/sh
+echo "args: $*"
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-F) id=$2; shift ;;
+	-P) cat "$2" ;;
+	esac
+	shift
+done
+echo "data of $id" > "run_${id}_test_0000.csv"
+`
+
+// startAgent serves an agent with its own directory on a port of localhost,
+// running fakeRun instead of go-ycsb
+func startAgent(t *testing.T) *Agent {
+	t.Helper()
+	p := properties.NewProperties()
+	p.Set(prop.AgentListen, "127.0.0.1:0")
+	p.Set(prop.AgentDir, t.TempDir())
+	p.Set(prop.AgentToken, testAgentToken)
+	a, err := NewAgent(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	a.executable = filepath.Join(t.TempDir(), "go-ycsb")
+	if err = os.WriteFile(a.executable, []byte(fakeRun), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func setAgentToken(t *testing.T, token string) {
+	saved := globalAgentToken
+	globalAgentToken = token
+	t.Cleanup(func() { globalAgentToken = saved })
+}
+
+func TestIsBaseName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"run_a1_test_0000.csv": true,
+		"follower_a1.log":      true,
+		".hidden":              true,
+		"":                     false,
+		".":                    false,
+		"..":                   false,
+		"../secret":            false,
+		"dir/file":             false,
+		"/etc/passwd":          false,
+		"dir/":                 false,
+	} {
+		if got := isBaseName(name); got != want {
+			t.Errorf("isBaseName(%q): want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestRunOutputStream(t *testing.T) {
+	o, err := newRunOutput(filepath.Join(t.TempDir(), "follower_a1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Write([]byte("before\n"))
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		o.stream(context.Background(), w)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	o.Write([]byte("after\n"))
+	select {
+	case <-done:
+		t.Fatal("stream ended before the output was closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	o.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not ended by the close of the output")
+	}
+	if got := w.Body.String(); got != "before\nafter\n" {
+		t.Errorf("want the whole output, got %q", got)
+	}
+
+	// a stream started after the close replays the output
+	w = httptest.NewRecorder()
+	o.stream(context.Background(), w)
+	if got := w.Body.String(); got != "before\nafter\n" {
+		t.Errorf("want the whole output after the close, got %q", got)
+	}
+}
+
+func TestRunOutputStreamCanceled(t *testing.T) {
+	o, err := newRunOutput(filepath.Join(t.TempDir(), "follower_a1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		o.stream(ctx, httptest.NewRecorder())
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not ended by the reader going away")
+	}
+}
+
+func TestNewAgentNeedsToken(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.AgentListen, "127.0.0.1:0")
+	if _, err := NewAgent(p); err == nil {
+		t.Error("want an error for an agent without token")
+	}
+}
+
+func TestAgentChecksToken(t *testing.T) {
+	a := startAgent(t)
+	paths := []string{agentPathRun, agentPathOutput, agentPathFiles, agentPathFile + "?name=x", agentPathStatus}
+	for _, token := range []string{"", "Bearer ", "Bearer wrong", testAgentToken + "x", "Bearer " + testAgentToken + "x"} {
+		for _, path := range paths {
+			req, _ := http.NewRequest(http.MethodGet, "http://"+a.Addr()+path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%v with token %q: want %v, got %v", path, token, http.StatusUnauthorized, resp.Status)
+			}
+		}
+	}
+
+	f := &Follower{Id: "a1", Agent: a.Addr()}
+	setAgentToken(t, "wrong")
+	if err := f.checkAgent(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("want the agent to refuse a wrong token, got %v", err)
+	}
+	setAgentToken(t, testAgentToken)
+	if err := f.checkAgent(); err != nil {
+		t.Errorf("check with the token: %v", err)
+	}
+	setAgentToken(t, "")
+	if err := f.startAgentRun("basic", "", nil); err != errNoAgentToken {
+		t.Errorf("run without token: want %v, got %v", errNoAgentToken, err)
+	}
+	if err := f.CheckConfig(); err != errNoAgentToken {
+		t.Errorf("config without token: want %v, got %v", errNoAgentToken, err)
+	}
+}
+
+func TestAgentRejectsBadRequests(t *testing.T) {
+	a := startAgent(t)
+	f := &Follower{Id: "a1", Agent: a.Addr()}
+	setAgentToken(t, testAgentToken)
+
+	for _, c := range []struct {
+		path  string
+		query url.Values
+	}{
+		{agentPathFiles, url.Values{"follower": {"a1"}, "prefix": {""}}},
+		{agentPathFiles, url.Values{"follower": {"a1"}, "prefix": {"run_a1", ""}}},
+		{agentPathFiles, url.Values{"prefix": {"run_a1"}}},
+		{agentPathFile, url.Values{"name": {"../secret"}}},
+		{agentPathFile, url.Values{"name": {""}}},
+	} {
+		if _, err := f.agentRequest(http.DefaultClient, http.MethodGet, c.path, c.query, nil); err == nil || !strings.Contains(err.Error(), "400") {
+			t.Errorf("%v?%v: want %v, got %v", c.path, c.query.Encode(), http.StatusBadRequest, err)
+		}
+	}
+	body := strings.NewReader(`{"follower": "../a1", "db": "basic"}`)
+	if _, err := f.agentRequest(http.DefaultClient, http.MethodPost, agentPathRun, nil, body); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("run of a follower path: want %v, got %v", http.StatusBadRequest, err)
+	}
+}
+
+func TestAgentRunRoundTrip(t *testing.T) {
+	leaderDir := chdir(t)
+	setAgentToken(t, testAgentToken)
+	workload := filepath.Join(leaderDir, "workload")
+	if err := os.WriteFile(workload, []byte("recordcount=10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// two agents on localhost, each running a follower in its own directory
+	a1, a2 := startAgent(t), startAgent(t)
+	followers := []*Follower{{Id: "a1", Agent: a1.Addr()}, {Id: "a2", Agent: a2.Addr()}}
+	for _, f := range followers {
+		if err := f.checkAgent(); err != nil {
+			t.Fatalf("follower %v: %v", f.Id, err)
+		}
+		props := map[string]string{prop.ControlLeader: ":7790", prop.RunID: "test"}
+		if err := f.startAgentRun("basic", workload, props); err != nil {
+			t.Fatalf("follower %v: %v", f.Id, err)
+		}
+	}
+	for _, f := range followers {
+		if err := f.getAgentFiles([]string{"run_" + f.Id + "_test"}); err != nil {
+			t.Fatalf("follower %v: %v", f.Id, err)
+		}
+		data, err := os.ReadFile(filepath.Join(leaderDir, "run_"+f.Id+"_test_0000.csv"))
+		if err != nil {
+			t.Fatalf("follower %v: %v", f.Id, err)
+		}
+		if want := "data of " + f.Id + "\n"; string(data) != want {
+			t.Errorf("follower %v: want %q, got %q", f.Id, want, data)
+		}
+
+		log, err := os.ReadFile(filepath.Join(leaderDir, "follower_"+f.Id+".log"))
+		if err != nil {
+			t.Fatalf("follower %v: %v", f.Id, err)
+		}
+		for _, want := range []string{
+			"run basic -F " + f.Id + " -P ",
+			// the leader address is completed with the host the request came from
+			prop.ControlLeader + "=127.0.0.1:7790",
+			prop.RunID + "=test",
+			"recordcount=10",
+			"[agent] run exited: exit status 0",
+		} {
+			if !strings.Contains(string(log), want) {
+				t.Errorf("follower %v: want %q in the log, got %q", f.Id, want, log)
+			}
+		}
+	}
+
+	// an agent lists the files of the prefixes and the log of the follower only
+	f := followers[0]
+	if err := os.WriteFile(filepath.Join(a1.dir, "other.csv"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := f.agentRequest(http.DefaultClient, http.MethodGet, agentPathFiles, url.Values{"follower": {"a1"}, "prefix": {"run_a1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var files agentFilesResponse
+	if err = json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"follower_a1.log", "run_a1_test_0000.csv"}; !reflect.DeepEqual(files.Files, want) {
+		t.Errorf("want files %v, got %v", want, files.Files)
+	}
+}
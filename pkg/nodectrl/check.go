@@ -0,0 +1,49 @@
+package nodectrl
+
+import "context"
+
+// CheckConfig prepares the transport of the node without connecting: the
+// credentials, the known_hosts file and the jump host
+func (n *Node) CheckConfig() error {
+	ctrl, err := newController(n)
+	if err != nil {
+		return err
+	}
+	return ctrl.Close()
+}
+
+// CheckReachable runs a no-op command on the node through its transport, as
+// the events and the node commands would
+func (n *Node) CheckReachable() error {
+	ctrl, err := newController(n)
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), globalNodeTimeout)
+	defer cancel()
+	_, err = ctrl.Run(ctx, "true")
+	return err
+}
+
+// CheckConfig prepares the SSH client config of the follower, or checks an
+// agent follower has the agent token
+func (f *Follower) CheckConfig() error {
+	if f.Agent != "" {
+		if globalAgentToken == "" {
+			return errNoAgentToken
+		}
+		return nil
+	}
+	_, err := GenerateSSHClientConfig(f.Username, f.KeyFile)
+	return err
+}
+
+// CheckSSH logs in to the follower and runs a no-op command, or asks the
+// agent of an agent follower whether it is up
+func (f *Follower) CheckSSH() error {
+	if f.Agent != "" {
+		return f.checkAgent()
+	}
+	return RunSSHCommand(f.IpAddrStr, f.Username, f.KeyFile, "true")
+}
@@ -0,0 +1,189 @@
+package nodectrl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Node transports, set per node or for the whole cluster file with "transport"
+const (
+	TransportSSH   = "ssh"
+	TransportLocal = "local"
+)
+
+// NodeController runs the commands of a node: on the node over SSH, or as
+// local processes so a whole cluster can run on one machine. A command still
+// running when the context is done is killed.
+type NodeController interface {
+	// Start launches the command in the background and returns its process id
+	Start(ctx context.Context, command string) (string, error)
+	// Run runs the command to completion. The error of a command exiting with
+	// a non-zero status wraps an exit error, see isExitError.
+	Run(ctx context.Context, command string) (CommandOutput, error)
+	// Close releases the connection to the node
+	Close() error
+}
+
+// CommandOutput is what a command printed and how it exited
+type CommandOutput struct {
+	Stdout string
+	Stderr string
+	// ExitStatus is -1 when the command did not exit, e.g. it timed out or
+	// the node was not reachable
+	ExitStatus int
+}
+
+// commandResult fills the exit status of the output and adds the standard
+// error to the error of a failed command
+func commandResult(out CommandOutput, err error) (CommandOutput, error) {
+	var sshErr *ssh.ExitError
+	var execErr *exec.ExitError
+	switch {
+	case err == nil:
+		out.ExitStatus = 0
+	case errors.As(err, &sshErr):
+		out.ExitStatus = sshErr.ExitStatus()
+	case errors.As(err, &execErr):
+		out.ExitStatus = execErr.ExitCode()
+	default:
+		out.ExitStatus = -1
+	}
+	if err != nil && strings.TrimSpace(out.Stderr) != "" {
+		err = fmt.Errorf("%w: %v", err, strings.TrimSpace(out.Stderr))
+	}
+	return out, err
+}
+
+func (c *sshController) Run(ctx context.Context, command string) (CommandOutput, error) {
+	var stdout, stderr bytes.Buffer
+	err := c.session(func(session *ssh.Session) error {
+		session.Stdout = &stdout
+		session.Stderr = &stderr
+		if err := session.Start(command); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- session.Wait()
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+			return ctx.Err()
+		}
+	})
+	return commandResult(CommandOutput{Stdout: stdout.String(), Stderr: stderr.String()}, err)
+}
+
+// Start launches the command detached from the session with nohup, so the
+// session returns at once. The output goes to node_<id>.log in the home
+// directory of the user.
+func (c *sshController) Start(ctx context.Context, command string) (string, error) {
+	// setsid makes the node a process group leader, so stopping it stops
+	// the processes it forked as well
+	out, err := c.Run(ctx, fmt.Sprintf("setsid nohup sh -c %v > node_%v.log 2>&1 < /dev/null & echo $!",
+		shellQuote("exec "+command), c.nodeId))
+	if err != nil {
+		return "", err
+	}
+	return parsePid(out.Stdout)
+}
+
+// shellQuote quotes the string as one word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isExitError returns whether the command ran and exited with a non-zero status
+func isExitError(err error) bool {
+	var sshErr *ssh.ExitError
+	var execErr *exec.ExitError
+	return errors.As(err, &sshErr) || errors.As(err, &execErr)
+}
+
+// localController runs the commands as processes of the local machine
+type localController struct {
+	nodeId string
+}
+
+// Run runs the command in its own process group, so the processes it forks
+// are killed with it when the context is done
+func (c *localController) Run(ctx context.Context, command string) (CommandOutput, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return commandResult(CommandOutput{}, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		err = ctx.Err()
+	}
+	return commandResult(CommandOutput{Stdout: stdout.String(), Stderr: stderr.String()}, err)
+}
+
+func (c *localController) Close() error {
+	return nil
+}
+
+// Start launches the command in its own process group, so it outlives the
+// go-ycsb process and does not get its terminal signals. The output goes to
+// node_<id>.log in the working directory.
+func (c *localController) Start(ctx context.Context, command string) (string, error) {
+	logFile, err := os.OpenFile(fmt.Sprintf("node_%v.log", c.nodeId), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer logFile.Close()
+
+	// exec keeps the process id of the shell, so signals reach the node itself
+	cmd := exec.Command("sh", "-c", "exec "+command)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = cmd.Start(); err != nil {
+		return "", err
+	}
+	go cmd.Wait()
+
+	return strconv.Itoa(cmd.Process.Pid), nil
+}
+
+// newController returns the controller of the node for its transport
+func newController(n *Node) (NodeController, error) {
+	switch n.Transport {
+	case TransportLocal:
+		return &localController{nodeId: n.Id}, nil
+	case TransportSSH, "":
+		c, err := newSSHController(sshAddress(n.IpAddrStr, n.Port), &n.SSHAuth, n.Jump)
+		if err != nil {
+			return nil, err
+		}
+		c.nodeId = n.Id
+		return c, nil
+	}
+	return nil, fmt.Errorf("unsupported transport %q", n.Transport)
+}
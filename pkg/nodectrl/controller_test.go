@@ -0,0 +1,141 @@
+package nodectrl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// chdir moves the test to a temporary working directory, where the local
+// nodes write their logs
+func chdir(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestLocalRun(t *testing.T) {
+	c := &localController{nodeId: "n1"}
+	out, err := c.Run(context.Background(), "echo out; echo err >&2")
+	if err != nil || out.Stdout != "out\n" || out.Stderr != "err\n" || out.ExitStatus != 0 {
+		t.Fatalf("unexpected output %+v %v", out, err)
+	}
+
+	out, err = c.Run(context.Background(), "echo failed >&2; exit 3")
+	if !isExitError(err) || out.ExitStatus != 3 || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("want exit status 3 with the standard error, got %+v %v", out, err)
+	}
+}
+
+// processGone returns whether the process exited, a zombie counting as gone
+func processGone(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return syscall.Kill(pid, 0) != nil
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) > 2 && fields[2] == "Z"
+}
+
+func TestLocalRunKillsGroupOnTimeout(t *testing.T) {
+	c := &localController{nodeId: "n1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	// the background sleep is in the process group of the command
+	out, err := c.Run(ctx, "sleep 30 & echo $!; wait")
+	if !errors.Is(err, context.DeadlineExceeded) || out.ExitStatus != -1 {
+		t.Fatalf("want the command timed out, got %+v %v", out, err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("want the command killed at the timeout, took %v", d)
+	}
+
+	child, err := strconv.Atoi(strings.TrimSpace(out.Stdout))
+	if err != nil {
+		t.Fatalf("want the pid of the child, got %q", out.Stdout)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(child) {
+		if time.Now().After(deadline) {
+			syscall.Kill(child, syscall.SIGKILL)
+			t.Fatalf("want the child %v killed with the group", child)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLocalStart(t *testing.T) {
+	dir := chdir(t)
+	c := &localController{nodeId: "n1"}
+	pid, err := c.Start(context.Background(), "sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(pid)
+	defer syscall.Kill(-n, syscall.SIGKILL)
+
+	// exec keeps the pid of the shell, which leads its own group
+	if pgid, err := syscall.Getpgid(n); err != nil || pgid != n {
+		t.Fatalf("want process %v leading its group, got %v %v", n, pgid, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "node_n1.log")); err != nil {
+		t.Fatalf("want the output in node_n1.log: %v", err)
+	}
+
+	node := &Node{Id: "n1", ctrl: c, pid: pid}
+	alive, err := node.alive(context.Background())
+	if err != nil || !alive {
+		t.Fatalf("want the node alive, got %v %v", alive, err)
+	}
+	syscall.Kill(-n, syscall.SIGTERM)
+	if !node.waitExit(context.Background(), 2*time.Second) {
+		t.Fatalf("want the node gone after SIGTERM")
+	}
+}
+
+func TestLocalNodeLifecycle(t *testing.T) {
+	chdir(t)
+	defer func(dir string, list NodeList) { globalStateDir, globalNodeList = dir, list }(globalStateDir, globalNodeList)
+	globalStateDir = t.TempDir()
+	globalNodeList = NodeList{StartCommand: "sleep 30", Nodes: []Node{{Id: "n1", Transport: TransportLocal}}}
+	globalNodeList.Nodes[0].ctrl = &localController{nodeId: "n1"}
+
+	if _, err := StartNodeById("n1"); err != nil {
+		t.Fatal(err)
+	}
+	pid := globalNodeList.Nodes[0].pid
+	if _, err := SignalNode("n1", "STOP"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := readState("n1"); state == nil || state.State != StatePaused || state.Pid != pid {
+		t.Fatalf("want n1 paused, got %+v", state)
+	}
+	if _, err := SignalNode("n1", "CONT"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StopNodeById("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := readState("n1"); state == nil || state.State != StateStopped || state.Pid != "" {
+		t.Fatalf("want n1 stopped, got %+v", state)
+	}
+	n, _ := strconv.Atoi(pid)
+	if !processGone(n) {
+		t.Fatalf("want the process %v of n1 gone", n)
+	}
+}
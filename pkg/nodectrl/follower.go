@@ -3,11 +3,15 @@ package nodectrl
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"io"
 	"log"
+	"net"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -16,8 +20,13 @@ type Follower struct {
 	IpAddrStr string `json:"IP"`
 	Username  string `json:"username"`
 	KeyFile   string `json:"keyfile"`
+	// Agent is the host:port of the go-ycsb agent running the follower, which
+	// is then driven over HTTP instead of SSH
+	Agent     string `json:"agent"`
 	Started   bool
 	sshClient *ssh.ClientConfig
+	// outputDone is closed when the output of the agent run ends
+	outputDone chan struct{}
 }
 
 type FollowerList struct {
@@ -36,17 +45,47 @@ func FollowersStarted() bool {
 	return anyFollowers
 }
 
-func ParseFollowerList(jsonSource string) error {
-	bytes, _ := os.ReadFile(jsonSource)
+// ReadFollowerList reads and checks the JSON formatted followers file
+func ReadFollowerList(jsonSource string) (*FollowerList, error) {
+	bytes, err := os.ReadFile(jsonSource)
+	if err != nil {
+		return nil, err
+	}
 
 	var templist FollowerList
-	err := json.Unmarshal(bytes, &templist)
-	globalFollowerList = templist
+	if err = json.Unmarshal(bytes, &templist); err != nil {
+		return nil, fmt.Errorf("%v: %v", jsonSource, err)
+	}
 
-	return err
+	seen := make(map[string]bool, len(templist.Followers))
+	for _, f := range templist.Followers {
+		if f.Id == "" {
+			return nil, fmt.Errorf("%v: follower without followerID", jsonSource)
+		}
+		if seen[f.Id] {
+			return nil, fmt.Errorf("%v: duplicate followerID %v", jsonSource, f.Id)
+		}
+		seen[f.Id] = true
+		if f.IpAddrStr == "" && f.Agent == "" {
+			return nil, fmt.Errorf("%v: follower %v without IP or agent", jsonSource, f.Id)
+		}
+	}
+	return &templist, nil
 }
 
-func (f *Follower) runFollowerCommand(command string) error {
+func ParseFollowerList(jsonSource string) error {
+	templist, err := ReadFollowerList(jsonSource)
+	if err != nil {
+		return err
+	}
+	globalFollowerList = *templist
+
+	return nil
+}
+
+// runFollowerCommand runs the command returned by command, which gets the
+// address of the leader as seen from the follower
+func (f *Follower) runFollowerCommand(command func(leaderHost string) string) error {
 	if f.sshClient == nil {
 		sshClient, err := GenerateSSHClientConfig(f.Username, f.KeyFile)
 		if err != nil {
@@ -55,7 +94,7 @@ func (f *Follower) runFollowerCommand(command string) error {
 		f.sshClient = sshClient
 	}
 
-	client, err := ssh.Dial("tcp", f.IpAddrStr, f.sshClient)
+	client, err := ssh.Dial("tcp", sshAddress(f.IpAddrStr, 0), f.sshClient)
 	if err != nil {
 		return err
 	}
@@ -67,19 +106,55 @@ func (f *Follower) runFollowerCommand(command string) error {
 	}
 	defer session.Close()
 
-	err = session.Run(command)
+	leaderHost, _, err := net.SplitHostPort(client.LocalAddr().String())
 	if err != nil {
 		return err
 	}
-	return nil
+	return session.Run(command(leaderHost))
 }
 
-func StartFollowers(dbName, workload string) {
-	for _, follower := range globalFollowerList.Followers {
-		startcmd := fmt.Sprintf(StartFollowerFmtStr, dbName, follower.Id, workload)
-		err := follower.runFollowerCommand(startcmd)
+// StartFollowers launches the run of the workload on every follower in the
+// background, and returns the ids of the followers launched. The properties
+// props returns for the index of the follower in the followers file are
+// passed with -p, a control.leader of the form :port being completed with
+// the address the follower reaches the leader at.
+func StartFollowers(dbName, workload string, props func(i int) map[string]string) []string {
+	var launched []string
+	for i := range globalFollowerList.Followers {
+		follower := &globalFollowerList.Followers[i]
+		followerProps := props(i)
+		keys := make([]string, 0, len(followerProps))
+		for key := range followerProps {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var err error
+		if follower.Agent != "" {
+			err = follower.startAgentRun(dbName, workload, followerProps)
+		} else {
+			err = follower.runFollowerCommand(func(leaderHost string) string {
+				startcmd := fmt.Sprintf(StartFollowerFmtStr, dbName, follower.Id, workload)
+				for _, key := range keys {
+					value := followerProps[key]
+					if key == prop.ControlLeader && strings.HasPrefix(value, ":") {
+						value = net.JoinHostPort(leaderHost, value[1:])
+					}
+					startcmd += " -p " + shellQuote(key+"="+value)
+				}
+				return fmt.Sprintf("nohup %v > follower_%v.log 2>&1 < /dev/null &", startcmd, follower.Id)
+			})
+		}
+		if err != nil {
+			log.Printf("Error starting follower %v, its share of the workload does not run: %v", follower.Id, err)
+		}
 		follower.Started = err == nil
+		measurement.RecordFollowerState(follower.Id, follower.Started)
+		if follower.Started {
+			launched = append(launched, follower.Id)
+		}
 	}
+	return launched
 }
 
 // Download file from sftp server
@@ -108,8 +183,10 @@ func downloadFile(sc sftp.Client, remoteFile, localFile string) (err error) {
 	return nil
 }
 
-func (f *Follower) getFollowerFiles() error {
-	conn, err := ssh.Dial("tcp", f.IpAddrStr, f.sshClient)
+// getFollowerFiles downloads the files of the follower whose name starts
+// with one of the prefixes, followed by _ or .
+func (f *Follower) getFollowerFiles(prefixes []string) error {
+	conn, err := ssh.Dial("tcp", sshAddress(f.IpAddrStr, 0), f.sshClient)
 	if err != nil {
 		return err
 	}
@@ -126,22 +203,43 @@ func (f *Follower) getFollowerFiles() error {
 		return err
 	}
 	for _, fi := range files {
-		if strings.Contains(fi.Name(), f.Id) {
-			downloadFile(*sc, fi.Name(), "./"+fi.Name())
+		if !hasFilePrefix(fi.Name(), prefixes) {
+			continue
+		}
+		if err := downloadFile(*sc, fi.Name(), "./"+fi.Name()); err != nil {
+			log.Printf("Error downloading file %v from follower %v: %v", fi.Name(), f.Id, err)
 		}
 	}
 	return nil
 }
 
-func GetFollowersFiles() {
+func hasFilePrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix+"_") || strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFollowersFiles downloads the files of the run of the followers which
+// finished it, those whose name starts with one of the prefixes of the follower
+func GetFollowersFiles(finished []string, prefixes func(id string) []string) {
+	done := make(map[string]bool, len(finished))
+	for _, id := range finished {
+		done[id] = true
+	}
 	for _, f := range globalFollowerList.Followers {
-		if f.Started {
-			err := f.getFollowerFiles()
-			if err != nil {
-				log.Printf("Error downloading files from follower %v.", f.Id)
-			}
-		} else {
+		if !f.Started {
 			log.Printf("Follower %v was never started and has no files.", f.Id)
+		} else if !done[f.Id] {
+			log.Printf("Follower %v did not finish its run, its files are not downloaded.", f.Id)
+		} else if f.Agent != "" {
+			if err := f.getAgentFiles(prefixes(f.Id)); err != nil {
+				log.Printf("Error downloading files from follower %v: %v", f.Id, err)
+			}
+		} else if err := f.getFollowerFiles(append(prefixes(f.Id), "follower_"+f.Id)); err != nil {
+			log.Printf("Error downloading files from follower %v: %v", f.Id, err)
 		}
 	}
 }
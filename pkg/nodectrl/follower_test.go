@@ -0,0 +1,35 @@
+package nodectrl
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFollowerList(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		// err is a part of the expected error, empty for a valid list
+		err string
+	}{
+		{"valid", `{"followers": [{"followerID": "f1", "IP": "10.0.0.1:22"}, {"followerID": "f2", "IP": "10.0.0.2:22"}]}`, ""},
+		{"invalid json", `{"followers": [`, "list.json"},
+		{"follower without id", `{"followers": [{"IP": "10.0.0.1:22"}]}`, "without followerID"},
+		{"duplicate id", `{"followers": [{"followerID": "f1", "IP": "10.0.0.1:22"}, {"followerID": "f1", "IP": "10.0.0.2:22"}]}`, "duplicate followerID f1"},
+		{"follower without IP", `{"followers": [{"followerID": "f1"}]}`, "follower f1"},
+	} {
+		followers, err := ReadFollowerList(writeJSON(t, tt.content))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err == "" && len(followers.Followers) != 2:
+			t.Errorf("%v: want 2 followers, got %+v", tt.name, followers.Followers)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		}
+	}
+	if _, err := ReadFollowerList(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
@@ -1,121 +1,110 @@
 package nodectrl
 
 import (
-	"bytes"
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/pingcap/go-ycsb/pkg/util"
 	"golang.org/x/crypto/ssh"
+	"net"
 	"os"
-	"regexp"
+	"sync"
 	"time"
 )
 
 type Node struct {
-	Id          string `json:"nodeID"`
-	IpAddrStr   string `json:"IP"`
-	Username    string `json:"username"`
-	KeyFile     string `json:"keyfile"`
-	NodeCommand string `json:"nodecommand"`
-	sshClient   ssh.ClientConfig
-	pid         string
+	Id        string `json:"nodeID"`
+	IpAddrStr string `json:"IP"`
+	// Port is the SSH port, overriding the one of IP, 22 by default
+	Port int `json:"port"`
+	SSHAuth
+	// Jump is the host the node is reached through over SSH
+	Jump        *JumpHost `json:"jump"`
+	NodeCommand string    `json:"nodecommand"`
+	// Transport overrides the transport of the cluster file for the node
+	Transport string `json:"transport"`
+	ctrl      NodeController
+	pid       string
+	// mu serializes the operations on the node, which read and write its
+	// pid and rewrite its state file
+	mu sync.Mutex
 }
 
 type NodeList struct {
 	Nodes        []Node `json:"nodes"`
 	StartCommand string `json:"startcommand"`
+	// Transport is "ssh" (default) or "local", see controller.go
+	Transport string `json:"transport"`
 }
 
-var pidHeader = []string{"nodeid", "pid"}
-
-const (
-	pidFileName = "pid_output.csv"
-)
-
-// updateNodePid updates the node, based on the Node ID, with the PID passed
-func updateNodePid(nodeid, pid string) {
-	for _, node := range globalNodeList.Nodes {
-		if node.Id == nodeid {
-			node.pid = pid
-		}
-	}
-}
-
-// writeNodePids Write the Node Process IDs to a CSV for eventual reading and stopping of the nodes
-func writeNodePids() error {
-	err := os.Remove(pidFileName)
-	file, err := os.Create(pidFileName)
-	if err != nil {
-		return err
-	}
-
-	var values [][]string
-	for _, node := range globalNodeList.Nodes {
-		var tempValues []string
-		tempValues = append(tempValues, node.Id)
-		tempValues = append(tempValues, node.pid)
-		values = append(values)
-	}
-
-	util.RenderCSV(pidHeader, values, file)
-
-	return file.Close()
-}
-
-// readNodePids read the Node Process IDs from the CSV file
-func readNodePids() error {
-	file, err := os.Open(pidFileName)
+// ReadNodeList reads and checks the JSON formatted cluster file, without
+// preparing the SSH connections
+func ReadNodeList(jsonSource string) (*NodeList, error) {
+	bytes, err := os.ReadFile(jsonSource)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	r, err := csv.NewReader(file).ReadAll()
-	if err != nil {
-		return err
+	var templist NodeList
+	if err = json.Unmarshal(bytes, &templist); err != nil {
+		return nil, fmt.Errorf("%v: %v", jsonSource, err)
 	}
 
-	for i, record := range r {
-		if i == 0 {
-			continue
+	seen := make(map[string]bool, len(templist.Nodes))
+	for i := range templist.Nodes {
+		node := &templist.Nodes[i]
+		if node.Id == "" {
+			return nil, fmt.Errorf("%v: node without nodeID", jsonSource)
 		}
+		if seen[node.Id] {
+			return nil, fmt.Errorf("%v: duplicate nodeID %v", jsonSource, node.Id)
+		}
+		seen[node.Id] = true
 
-		updateNodePid(record[0], record[1])
+		if node.Transport == "" {
+			node.Transport = templist.Transport
+		}
+		if node.Transport == "" {
+			node.Transport = TransportSSH
+		}
+		switch node.Transport {
+		case TransportSSH:
+			if node.IpAddrStr == "" {
+				return nil, fmt.Errorf("%v: node %v without IP", jsonSource, node.Id)
+			}
+		case TransportLocal:
+		default:
+			return nil, fmt.Errorf("%v: node %v: unsupported transport %q", jsonSource, node.Id, node.Transport)
+		}
+		if node.Port < 0 || node.Port > 65535 {
+			return nil, fmt.Errorf("%v: node %v: invalid port %v", jsonSource, node.Id, node.Port)
+		}
 	}
-
-	return nil
+	return &templist, nil
 }
 
 // ParseNodeList read the JSON formatted file for the cluster information
 func ParseNodeList(jsonSource string) error {
-	bytes, _ := os.ReadFile(jsonSource)
-
-	var templist NodeList
-	err := json.Unmarshal(bytes, &templist)
-	globalNodeList = templist
+	templist, err := ReadNodeList(jsonSource)
+	if err != nil {
+		return err
+	}
+	globalNodeList = *templist
 
-	for i, node := range globalNodeList.Nodes {
-		var hostKey ssh.PublicKey
-		key, err := os.ReadFile(node.KeyFile)
+	for i := range globalNodeList.Nodes {
+		node := &globalNodeList.Nodes[i]
+		node.ctrl, err = newController(node)
 		if err != nil {
-			return err
-		}
-		signer, err := ssh.ParsePrivateKey(key)
-		if err != nil {
-			return err
-		}
-		globalNodeList.Nodes[i].sshClient = ssh.ClientConfig{
-			Config: ssh.Config{},
-			User:   node.Username,
-			Auth: []ssh.AuthMethod{
-				ssh.PublicKeys(signer),
-			},
-			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			return fmt.Errorf("node %v: %v", node.Id, err)
 		}
 	}
 
-	return err
+	return loadStates()
+}
+
+// Local returns whether the node runs on the local machine
+func (n *Node) Local() bool {
+	return n.Transport == TransportLocal
 }
 
 // NodesParsed returns true if the globalNodeList is not empty
@@ -123,10 +112,23 @@ func NodesParsed() bool {
 	return len(globalNodeList.Nodes) > 0
 }
 
+// NodeIds returns the ids of the nodes of the cluster file, in file order
+func NodeIds() []string {
+	ids := make([]string, 0, len(globalNodeList.Nodes))
+	for i := range globalNodeList.Nodes {
+		ids = append(ids, globalNodeList.Nodes[i].Id)
+	}
+	return ids
+}
+
 // NodesStarted returns true if any of the nodes have a PID set
 func NodesStarted() bool {
-	for _, node := range globalNodeList.Nodes {
-		if node.pid != "" {
+	for i := range globalNodeList.Nodes {
+		node := &globalNodeList.Nodes[i]
+		node.mu.Lock()
+		started := node.pid != ""
+		node.mu.Unlock()
+		if started {
 			return true
 		}
 	}
@@ -135,164 +137,193 @@ func NodesStarted() bool {
 
 // getNodeById returns the node based on the ID passed
 func getNodeById(nodeId string) (*Node, error) {
-	for _, node := range globalNodeList.Nodes {
-		if node.Id == nodeId {
-			return &node, nil
+	for i := range globalNodeList.Nodes {
+		if globalNodeList.Nodes[i].Id == nodeId {
+			return &globalNodeList.Nodes[i], nil
 		}
 	}
 	return nil, errors.New(fmt.Sprintf("Node id [%v] not found", nodeId))
 }
 
-// startNode executes the start command for the referenced node
-func (n *Node) startNode() error {
-	var startCmd string
+// startCommand returns the command starting the node
+func (n *Node) startCommand() string {
 	if n.NodeCommand != "" {
-		startCmd = n.NodeCommand
-	} else {
-		startCmd = globalNodeList.StartCommand
+		return n.NodeCommand
 	}
+	return globalNodeList.StartCommand
+}
 
-	client, err := ssh.Dial("tcp", n.IpAddrStr, &n.sshClient)
+// start executes the start command of the node and records its process
+func (n *Node) start(ctx context.Context, r *NodeResult) error {
+	pid, err := n.ctrl.Start(ctx, n.startCommand())
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	n.pid = pid
+	r.Stdout = fmt.Sprintln("pid", pid)
+	return n.recordState(StateRunning)
+}
 
-	session, err := client.NewSession()
-	if err != nil {
-		return err
-	}
-	defer session.Close()
+// StartNodes starts all the nodes specified by the cluster file, in parallel
+func StartNodes() ([]NodeResult, error) {
+	return doNodes("starting", func(n *Node) (NodeResult, error) {
+		return n.do("start", n.start)
+	})
+}
 
-	var b bytes.Buffer
-	session.Stdout = &b
-	cmdStr := fmt.Sprintf("%v \\'%v %v\\'", "sh -c", "echo $$; exec", startCmd)
-	session.Run(cmdStr)
-	ok, err := regexp.Match("[0-9]+", b.Bytes())
-	if ok {
-		n.pid = b.String()
-	} else {
-		return errors.New(fmt.Sprintf("Error parsing pid for node id %v", n.Id))
-	}
-	return nil
+// StartNodeById starts the node specified by the node id
+func StartNodeById(nodeId string) (NodeResult, error) {
+	return doById(nodeId, "start", (*Node).start)
 }
 
-// StartNodes starts all the nodes specified by the cluster file
-func StartNodes() error {
-	var errMap map[string]error
-	for _, node := range globalNodeList.Nodes {
-		err := node.startNode()
-		if err != nil {
-			errMap[node.Id] = err
-		}
-	}
-	if len(errMap) > 0 {
-		return errors.New(fmt.Sprintf("Error starting nodes: %v", errMap))
-	} else {
-		writeNodePids()
-		return nil
-	}
+// killGroup sends the signal to the process group of the node, falling back
+// to its process if it does not lead a group
+func (n *Node) killGroup(ctx context.Context, signal string, r *NodeResult) error {
+	return n.run(ctx, fmt.Sprintf("kill -%v -%v 2>/dev/null || kill -%v %v", signal, n.pid, signal, n.pid), r)
 }
 
-// StartNodeById starts the node specified by the node id
-func StartNodeById(nodeId string) error {
-	node, err := getNodeById(nodeId)
+// stop terminates the node processes with SIGTERM, and with SIGKILL if the
+// node is still alive after the stop timeout
+func (n *Node) stop(ctx context.Context, r *NodeResult) error {
+	if n.pid == "" {
+		return errors.New(fmt.Sprintf("No process id recorded for node %v", n.Id))
+	}
+
+	alive, err := n.alive(ctx)
 	if err != nil {
 		return err
 	}
-	return node.startNode()
+	if alive {
+		// the process may exit between the liveness check and the signal
+		err = n.killGroup(ctx, "TERM", r)
+		if err != nil && !isExitError(err) {
+			return err
+		}
+		if !n.waitExit(ctx, globalStopTimeout) {
+			fmt.Printf("Node %v did not stop within %v, killing it\n", n.Id, globalStopTimeout)
+			n.killGroup(ctx, "KILL", r)
+			if !n.waitExit(ctx, globalStopTimeout) {
+				return errors.New(fmt.Sprintf("process %v still alive", n.pid))
+			}
+		}
+	}
+
+	n.pid = ""
+	r.ExitStatus = 0
+	return n.recordState(StateStopped)
 }
 
-// stopNode connects to the node and calls the kill command with the process id stored
-func (n *Node) stopNode() error {
-	client, err := ssh.Dial("tcp", n.IpAddrStr, &n.sshClient)
-	if err != nil {
-		return err
+// StopNodes stops all nodes specified by the cluster file, in parallel
+func StopNodes() ([]NodeResult, error) {
+	return doNodes("stopping", func(n *Node) (NodeResult, error) {
+		return n.do("stop", n.stop)
+	})
+}
+
+// StopNodeById stops the node specified by the node id
+func StopNodeById(nodeId string) (NodeResult, error) {
+	return doById(nodeId, "stop", (*Node).stop)
+}
+
+// signal sends the signal to the node process. Without a known process id
+// the processes running the start command of the node are signaled.
+func (n *Node) signal(ctx context.Context, signal string, r *NodeResult) error {
+	if n.pid == "" {
+		return n.run(ctx, pkillCommand(signal, n.startCommand()), r)
 	}
-	defer client.Close()
 
-	session, err := client.NewSession()
+	err := n.run(ctx, fmt.Sprintf("kill -s %v %v", signal, n.pid), r)
 	if err != nil {
 		return err
 	}
-	defer session.Close()
-
-	var b bytes.Buffer
-	session.Stdout = &b
-	cmdStr := fmt.Sprintf("kill %v", n.pid)
-	session.Run(cmdStr)
-	if len(b.Bytes()) > 0 {
-		return errors.New(fmt.Sprintf("Error stopping node %v: %v", n.Id, b.String()))
+	switch signal {
+	case "STOP":
+		return n.recordState(StatePaused)
+	case "CONT":
+		return n.recordState(StateRunning)
+	case "KILL":
+		return n.recordState(StateKilled)
 	}
-
 	return nil
 }
 
-// StopNodes stops all nodes specified by the cluster file
-func StopNodes() error {
-	if !NodesStarted() {
-		readNodePids()
-	}
-
-	var errMap map[string]error
-	for _, node := range globalNodeList.Nodes {
-		err := node.stopNode()
-		if err != nil {
-			if errMap == nil {
-				errMap = make(map[string]error)
-			}
-			errMap[node.Id] = err
-		}
+// processPattern returns the pkill pattern of the command. The first letter
+// is put in brackets, so the pattern does not match the shell running pkill,
+// whose command line contains the pattern itself.
+func processPattern(command string) string {
+	if command == "" {
+		return command
 	}
-	if len(errMap) > 0 {
-		return errors.New(fmt.Sprintf("Error stopping nodes: %v", errMap))
-	} else {
-		return nil
+	first := command[0]
+	if (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z') || (first >= '0' && first <= '9') || first == '/' || first == '.' {
+		return "[" + command[:1] + "]" + command[1:]
 	}
+	return command
 }
 
-// StopNodeById stops the node specified by the node id
-func StopNodeById(nodeId string) error {
-	node, err := getNodeById(nodeId)
-	if err != nil {
-		return err
-	}
-	return node.stopNode()
+// pkillCommand returns the command sending the signal to the processes
+// running the command
+func pkillCommand(signal, command string) string {
+	return fmt.Sprintf("pkill -%v -f %v", signal, shellQuote(processPattern(command)))
 }
 
-// runNodeCmd executes the command passed on the referenced node
-func (n *Node) runNodeCmd(command string) error {
-	if &n.sshClient == nil {
-		sshClient, err := GenerateSSHClientConfig(n.Username, n.KeyFile)
+// SignalNode sends the signal (e.g. KILL, TERM, STOP, CONT) to the node specified by the node id
+func SignalNode(nodeId, signal string) (NodeResult, error) {
+	return doById(nodeId, "signal "+signal, func(n *Node, ctx context.Context, r *NodeResult) error {
+		return n.signal(ctx, signal, r)
+	})
+}
+
+// RestartNodeById stops the node specified by the node id and starts it again with its start command
+func RestartNodeById(nodeId string) (NodeResult, error) {
+	return doById(nodeId, "restart", func(n *Node, ctx context.Context, r *NodeResult) error {
+		var err error
+		if n.pid != "" {
+			err = n.stop(ctx, r)
+		} else {
+			err = n.signal(ctx, "TERM", r)
+		}
 		if err != nil {
 			return err
 		}
-		n.sshClient = *sshClient
-	}
+		n.pid = ""
+		return n.start(ctx, r)
+	})
+}
 
-	client, err := ssh.Dial("tcp", n.IpAddrStr, &n.sshClient)
+// NodeHost returns the host of the node specified by the node id, without the port
+func NodeHost(nodeId string) (string, error) {
+	node, err := getNodeById(nodeId)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer client.Close()
-
-	session, err := client.NewSession()
+	if node.IpAddrStr == "" {
+		return "", fmt.Errorf("node %v without IP", nodeId)
+	}
+	host, _, err := net.SplitHostPort(node.IpAddrStr)
 	if err != nil {
-		return err
+		// an IP without port
+		return node.IpAddrStr, nil
 	}
-	defer session.Close()
-
-	return session.Run(command)
+	return host, nil
+}
 
+// RunNodeCommand executes the command passed on the node specified by id
+func RunNodeCommand(nodeId, command string) (NodeResult, error) {
+	return doById(nodeId, "run", func(n *Node, ctx context.Context, r *NodeResult) error {
+		return n.run(ctx, command, r)
+	})
 }
 
-// RunNodeCommand executes the command passed on the node specified by id—
-func RunNodeCommand(nodeId, command string) error {
+// doById runs the operation on the node specified by the node id, see do
+func doById(nodeId, operation string, f func(n *Node, ctx context.Context, r *NodeResult) error) (NodeResult, error) {
 	node, err := getNodeById(nodeId)
 	if err != nil {
-		return err
+		return NodeResult{Node: nodeId, Operation: operation, Start: time.Now(), ExitStatus: -1, Err: err}, err
 	}
-	return node.runNodeCmd(command)
+	return node.do(operation, func(ctx context.Context, r *NodeResult) error {
+		return f(node, ctx, r)
+	})
 }
 
 // RunSSHCommand run a command over ssh connection
@@ -315,7 +346,7 @@ func RunSSHCommand(ipAddr, userName, keyFile, command string) error {
 		return err
 	}
 
-	client, err := ssh.Dial("tcp", ipAddr, sshClient)
+	client, err := ssh.Dial("tcp", sshAddress(ipAddr, 0), sshClient)
 	if err != nil {
 		return err
 	}
@@ -330,26 +361,19 @@ func RunSSHCommand(ipAddr, userName, keyFile, command string) error {
 	return session.Run(command)
 }
 
-// generateSSHClientConfig create the ssh client config from the username and keyfile provided
+// GenerateSSHClientConfig create the ssh client config from the username and keyfile provided
 func GenerateSSHClientConfig(userName, keyFile string) (*ssh.ClientConfig, error) {
-	var hostKey ssh.PublicKey
-	key, err := os.ReadFile(keyFile)
-	if err != nil {
-		return nil, err
-	}
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		return nil, err
+	auth := SSHAuth{Username: userName, KeyFile: keyFile}
+	return auth.clientConfig()
+}
+
+// CloseConnections closes the SSH connections kept to the nodes
+func CloseConnections() {
+	for i := range globalNodeList.Nodes {
+		if ctrl := globalNodeList.Nodes[i].ctrl; ctrl != nil {
+			ctrl.Close()
+		}
 	}
-	return &ssh.ClientConfig{
-		Config: ssh.Config{},
-		User:   userName,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.FixedHostKey(hostKey),
-		Timeout:         2 * time.Second,
-	}, nil
 }
 
 var globalNodeList NodeList
@@ -0,0 +1,113 @@
+package nodectrl
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessPattern(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		want    string
+	}{
+		{"", ""},
+		{"redis-server --port 7000", "[r]edis-server --port 7000"},
+		{"/opt/db/bin/server", "[/]opt/db/bin/server"},
+		{"./server", "[.]/server"},
+		{"9p-server", "[9]p-server"},
+		{"Server", "[S]erver"},
+		// other first letters are not bracketed
+		{"-x", "-x"},
+		{"[s]erver", "[s]erver"},
+	} {
+		if got := processPattern(tt.command); got != tt.want {
+			t.Errorf("processPattern(%q): want %q, got %q", tt.command, tt.want, got)
+		}
+	}
+}
+
+func TestPkillCommandQuoting(t *testing.T) {
+	for _, command := range []string{
+		"server --name db1",
+		"sh -c 'exec server'",
+		`server --msg "it's up" $HOME; echo injected`,
+	} {
+		cmd := pkillCommand("TERM", command)
+		if !strings.HasPrefix(cmd, "pkill -TERM -f ") {
+			t.Fatalf("unexpected command %q", cmd)
+		}
+		// the shell hands the pattern to pkill as one argument, unexpanded
+		arg := strings.TrimPrefix(cmd, "pkill -TERM -f ")
+		out, err := exec.Command("sh", "-c", "printf '%s\\n' "+arg).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(string(out), "\n"); got != processPattern(command) {
+			t.Errorf("%q: want the pattern %q, got %q", command, processPattern(command), got)
+		}
+	}
+}
+
+func TestSignalWithoutPid(t *testing.T) {
+	chdir(t)
+	c := &localController{nodeId: "n1"}
+	// the pattern matches the command line of the process exec'd by the start command
+	pid, err := c.Start(context.Background(), "sleep 30.125")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Node{Id: "n1", NodeCommand: "sleep 30.125", ctrl: c}
+	var r NodeResult
+	if err = n.signal(context.Background(), "TERM", &r); err != nil {
+		t.Fatalf("want the node signaled through its start command: %v", err)
+	}
+	n.pid = pid
+	if !n.waitExit(context.Background(), 2*time.Second) {
+		c.Run(context.Background(), "kill -KILL "+pid)
+		t.Fatalf("want the node stopped by pkill")
+	}
+}
+
+// writeJSON writes the content to a file of a new directory
+func writeJSON(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "list.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadNodeList(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		// err is a part of the expected error, empty for a valid list
+		err string
+	}{
+		{"valid", `{"nodes": [{"nodeID": "n1", "IP": "10.0.0.1:22"}, {"nodeID": "n2", "IP": "10.0.0.2:22"}]}`, ""},
+		{"invalid json", `{"nodes": [`, "list.json"},
+		{"node without id", `{"nodes": [{"IP": "10.0.0.1:22"}]}`, "without nodeID"},
+		{"duplicate id", `{"nodes": [{"nodeID": "n1", "IP": "10.0.0.1:22"}, {"nodeID": "n1", "IP": "10.0.0.2:22"}]}`, "duplicate nodeID n1"},
+		{"node without IP", `{"nodes": [{"nodeID": "n1"}]}`, "node n1"},
+	} {
+		nodes, err := ReadNodeList(writeJSON(t, tt.content))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err == "" && len(nodes.Nodes) != 2:
+			t.Errorf("%v: want 2 nodes, got %+v", tt.name, nodes.Nodes)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		}
+	}
+	if _, err := ReadNodeList(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
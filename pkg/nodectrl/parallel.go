@@ -0,0 +1,137 @@
+package nodectrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// NodeResult is the outcome of an operation on one node: the output and exit
+// status of the last command it ran on the node
+type NodeResult struct {
+	Node      string
+	Operation string
+	Start     time.Time
+	Duration  time.Duration
+	// ExitStatus is -1 when the command did not exit, e.g. it timed out or
+	// the node was not reachable
+	ExitStatus int
+	Stdout     string
+	Stderr     string
+	Err        error
+}
+
+func (r *NodeResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[node %v] %v: exit status %v in %v", r.Node, r.Operation, r.ExitStatus,
+		r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		fmt.Fprintf(&b, ", error: %v", r.Err)
+	}
+	for _, output := range []struct {
+		name string
+		text string
+	}{{"stdout", r.Stdout}, {"stderr", r.Stderr}} {
+		text := strings.TrimRight(output.text, "\n")
+		if text == "" {
+			continue
+		}
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(&b, "\n[node %v] %v| %v", r.Node, output.name, line)
+		}
+	}
+	return b.String()
+}
+
+// run runs the command on the node and keeps its output in the result
+func (n *Node) run(ctx context.Context, command string, r *NodeResult) error {
+	out, err := n.ctrl.Run(ctx, command)
+	r.Stdout += out.Stdout
+	r.Stderr += out.Stderr
+	r.ExitStatus = out.ExitStatus
+	return err
+}
+
+// do runs the operation on the node within the node timeout, and logs its
+// result to the run output
+func (n *Node) do(operation string, f func(ctx context.Context, r *NodeResult) error) (NodeResult, error) {
+	// an operation waits for the one in progress on the node, overlapping
+	// events and undo actions included, before its timeout starts
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), globalNodeTimeout)
+	defer cancel()
+
+	r := NodeResult{Node: n.Id, Operation: operation, Start: time.Now()}
+	err := f(ctx, &r)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v: %w", globalNodeTimeout, err)
+	}
+	if err != nil && r.ExitStatus == 0 {
+		r.ExitStatus = -1
+	}
+	r.Duration = time.Since(r.Start)
+	r.Err = err
+	fmt.Println(r.String())
+	return r, err
+}
+
+// forEachNode calls f for every node of the cluster file, running at most
+// nodes.parallelism calls at once
+func forEachNode(f func(i int, n *Node)) {
+	limit := globalParallelism
+	if limit <= 0 || limit > len(globalNodeList.Nodes) {
+		limit = len(globalNodeList.Nodes)
+	}
+	tokens := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range globalNodeList.Nodes {
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			f(i, &globalNodeList.Nodes[i])
+		}(i)
+	}
+	wg.Wait()
+}
+
+// doNodes runs the operation on every node of the cluster file in parallel,
+// and returns the results in file order. The error maps the failed nodes to
+// their errors.
+func doNodes(name string, operation func(n *Node) (NodeResult, error)) ([]NodeResult, error) {
+	results := make([]NodeResult, len(globalNodeList.Nodes))
+	forEachNode(func(i int, n *Node) {
+		results[i], _ = operation(n)
+	})
+
+	errMap := make(map[string]error)
+	for _, r := range results {
+		if r.Err != nil {
+			errMap[r.Node] = r.Err
+		}
+	}
+	if len(errMap) > 0 {
+		return results, errors.New(fmt.Sprintf("Error %v nodes: %v", name, errMap))
+	}
+	return results, nil
+}
+
+// Parallelism returns how many nodes are operated on at once, 0 for all of them
+func Parallelism() int {
+	return globalParallelism
+}
+
+var (
+	globalParallelism = int(prop.NodesParallelismDefault)
+	globalNodeTimeout = time.Duration(prop.NodesTimeoutDefault) * time.Second
+)
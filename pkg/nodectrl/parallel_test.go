@@ -0,0 +1,64 @@
+package nodectrl
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNodeOperationsSerialized(t *testing.T) {
+	chdir(t)
+	defer func(dir string, list NodeList) { globalStateDir, globalNodeList = dir, list }(globalStateDir, globalNodeList)
+	globalStateDir = t.TempDir()
+	globalNodeList = NodeList{StartCommand: "sleep 30", Nodes: []Node{{Id: "n1", Transport: TransportLocal}}}
+	globalNodeList.Nodes[0].ctrl = &localController{nodeId: "n1"}
+	if _, err := StartNodeById("n1"); err != nil {
+		t.Fatal(err)
+	}
+	defer StopNodeById("n1")
+
+	// the directory is only free while no other command runs on the node,
+	// and the pauses, resumes and restarts rewrite the pid and state file
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 8; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			_, err := RunNodeCommand("n1", "mkdir busy && sleep 0.01 && rmdir busy")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := SignalNode("n1", "STOP")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := SignalNode("n1", "CONT")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			NodesStatus()
+			errs <- nil
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("want the operations on the node serialized: %v", err)
+		}
+	}
+
+	if _, err := SignalNode("n1", "CONT"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestartNodeById("n1"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := readState("n1")
+	if err != nil || state.State != StateRunning || state.Pid != globalNodeList.Nodes[0].pid {
+		t.Fatalf("want the state file of the restarted node, got %+v %v", state, err)
+	}
+}
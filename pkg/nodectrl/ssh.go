@@ -0,0 +1,289 @@
+package nodectrl
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultSSHPort = 22
+	sshDialTimeout = 2 * time.Second
+)
+
+// SSHAuth holds the credentials to log in to a host. The key file, the
+// password and the ssh-agent are tried in this order.
+type SSHAuth struct {
+	Username string `json:"username"`
+	KeyFile  string `json:"keyfile"`
+	Password string `json:"password"`
+	// Agent uses the keys of the ssh-agent listening on SSH_AUTH_SOCK
+	Agent bool `json:"agent"`
+}
+
+// JumpHost is the bastion a node is reached through
+type JumpHost struct {
+	IpAddrStr string `json:"IP"`
+	Port      int    `json:"port"`
+	SSHAuth
+}
+
+// hostKeyPolicy verifies the host keys of every SSH connection
+type hostKeyPolicy struct {
+	sync.Mutex
+	knownHosts string
+	insecure   bool
+	callback   ssh.HostKeyCallback
+}
+
+// SetHostKeyPolicy sets the known_hosts file the host keys are verified
+// against, ~/.ssh/known_hosts when empty. With insecure the host keys are
+// not verified at all.
+func SetHostKeyPolicy(knownHosts string, insecure bool) {
+	globalHostKeys.Lock()
+	defer globalHostKeys.Unlock()
+	globalHostKeys.knownHosts = knownHosts
+	globalHostKeys.insecure = insecure
+	globalHostKeys.callback = nil
+}
+
+func (p *hostKeyPolicy) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.callback != nil {
+		return p.callback, nil
+	}
+
+	if p.insecure {
+		log.Printf("WARNING: SSH host keys are not verified (ssh.insecure)")
+		p.callback = ssh.InsecureIgnoreHostKey()
+		return p.callback, nil
+	}
+
+	path := p.knownHosts
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts: %v, set ssh.knownhosts or ssh.insecure=true", err)
+	}
+	p.callback = callback
+	return p.callback, nil
+}
+
+// authMethods returns the SSH auth methods of the credentials
+func (a *SSHAuth) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if a.KeyFile != "" {
+		key, err := os.ReadFile(a.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if a.Password != "" {
+		methods = append(methods, ssh.Password(a.Password))
+	}
+	if a.Agent {
+		signers, err := agentSigners()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeysCallback(signers))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH credentials, set keyfile, password or agent")
+	}
+	return methods, nil
+}
+
+// clientConfig returns the SSH client config of the credentials, verifying
+// the host keys with the host key policy
+func (a *SSHAuth) clientConfig() (*ssh.ClientConfig, error) {
+	methods, err := a.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := globalHostKeys.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		Config:          ssh.Config{},
+		User:            a.Username,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+// agentSigners returns the signers of the ssh-agent, which is connected to once
+func agentSigners() (func() ([]ssh.Signer, error), error) {
+	globalAgent.Lock()
+	defer globalAgent.Unlock()
+	if globalAgent.client == nil {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, errors.New("agent auth needs SSH_AUTH_SOCK")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, err
+		}
+		globalAgent.client = agent.NewClient(conn)
+	}
+	return globalAgent.client.Signers, nil
+}
+
+// sshAddress returns the host:port to dial, the port defaulting to the one
+// of the address and then to 22
+func sshAddress(ipAddr string, port int) string {
+	host, addrPort, err := net.SplitHostPort(ipAddr)
+	if err != nil {
+		host = ipAddr
+		addrPort = strconv.Itoa(defaultSSHPort)
+	}
+	if port > 0 {
+		addrPort = strconv.Itoa(port)
+	}
+	return net.JoinHostPort(host, addrPort)
+}
+
+// sshController runs the commands on the node over a persistent SSH
+// connection, dialed on first use and again when it breaks
+type sshController struct {
+	nodeId string
+	addr   string
+	config *ssh.ClientConfig
+	// jump is the controller of the jump host, nil to dial the node directly
+	jump *sshController
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+func newSSHController(addr string, auth *SSHAuth, jump *JumpHost) (*sshController, error) {
+	config, err := auth.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	c := &sshController{addr: addr, config: config}
+	if jump != nil {
+		if jump.IpAddrStr == "" {
+			return nil, errors.New("jump host without IP")
+		}
+		c.jump, err = newSSHController(sshAddress(jump.IpAddrStr, jump.Port), &jump.SSHAuth, nil)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %v: %v", jump.IpAddrStr, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *sshController) dial() (*ssh.Client, error) {
+	if c.jump == nil {
+		return ssh.Dial("tcp", c.addr, c.config)
+	}
+
+	jumpClient, err := c.jump.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := jumpClient.Dial("tcp", c.addr)
+	if err != nil {
+		c.jump.reset(jumpClient)
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, c.addr, c.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// connect returns the connection of the node, dialing it if there is none
+func (c *sshController) connect() (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		client, err := c.dial()
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+// reset drops the connection if it is still the current one
+func (c *sshController) reset(client *ssh.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == client {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// session runs f in a new session. A broken connection is dialed again once.
+func (c *sshController) session(f func(*ssh.Session) error) error {
+	for attempt := 0; ; attempt++ {
+		client, err := c.connect()
+		if err != nil {
+			return err
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			c.reset(client)
+			if attempt == 0 {
+				continue
+			}
+			return err
+		}
+		defer session.Close()
+		return f(session)
+	}
+}
+
+// Close closes the connections of the node and of its jump host
+func (c *sshController) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+		c.client = nil
+	}
+	if c.jump != nil {
+		c.jump.Close()
+	}
+	return err
+}
+
+var (
+	globalHostKeys hostKeyPolicy
+	globalAgent    struct {
+		sync.Mutex
+		client agent.ExtendedAgent
+	}
+)
@@ -0,0 +1,136 @@
+package nodectrl
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSSHAddress(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		port int
+		want string
+	}{
+		{"10.0.0.1", 0, "10.0.0.1:22"},
+		{"10.0.0.1", 2222, "10.0.0.1:2222"},
+		{"10.0.0.1:2200", 0, "10.0.0.1:2200"},
+		{"10.0.0.1:2200", 2222, "10.0.0.1:2222"},
+		{"node1", 0, "node1:22"},
+		{"::1", 0, "[::1]:22"},
+		{"[::1]:2200", 0, "[::1]:2200"},
+	} {
+		if got := sshAddress(tt.addr, tt.port); got != tt.want {
+			t.Errorf("sshAddress(%q, %v): want %v, got %v", tt.addr, tt.port, tt.want, got)
+		}
+	}
+}
+
+// newSigner returns a new key, and writes it to a key file when path is set
+func newSigner(t *testing.T, path string) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "" {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signer
+}
+
+func TestAuthMethods(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	newSigner(t, keyFile)
+	badKeyFile := filepath.Join(dir, "bad")
+	if err := os.WriteFile(badKeyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	for _, tt := range []struct {
+		name string
+		auth SSHAuth
+		// err is a part of the expected error, empty when the auth is valid
+		err     string
+		methods int
+	}{
+		{name: "no credentials", auth: SSHAuth{Username: "ycsb"}, err: "no SSH credentials"},
+		{name: "key file", auth: SSHAuth{KeyFile: keyFile}, methods: 1},
+		{name: "password", auth: SSHAuth{Password: "secret"}, methods: 1},
+		{name: "key file and password", auth: SSHAuth{KeyFile: keyFile, Password: "secret"}, methods: 2},
+		{name: "missing key file", auth: SSHAuth{KeyFile: filepath.Join(dir, "missing")}, err: "no such file"},
+		{name: "invalid key file", auth: SSHAuth{KeyFile: badKeyFile, Password: "secret"}, err: "no key found"},
+		{name: "agent without socket", auth: SSHAuth{Agent: true}, err: "SSH_AUTH_SOCK"},
+	} {
+		methods, err := tt.auth.authMethods()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		case len(methods) != tt.methods:
+			t.Errorf("%v: want %v methods, got %v", tt.name, tt.methods, len(methods))
+		}
+	}
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	known, unknown := newSigner(t, "").PublicKey(), newSigner(t, "").PublicKey()
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("10.0.0.1:22")}, known) + "\n"
+	if err := os.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the known_hosts file accepts the key of the host only
+	p := &hostKeyPolicy{knownHosts: knownHosts}
+	callback, err := p.hostKeyCallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = callback("10.0.0.1:22", remote, known); err != nil {
+		t.Errorf("want the known key accepted, got %v", err)
+	}
+	if err = callback("10.0.0.1:22", remote, unknown); err == nil {
+		t.Error("want a changed key refused")
+	}
+	if err = callback("10.0.0.2:22", remote, known); err == nil {
+		t.Error("want an unknown host refused")
+	}
+
+	// insecure accepts any key, even without known_hosts file
+	p = &hostKeyPolicy{knownHosts: filepath.Join(t.TempDir(), "missing"), insecure: true}
+	if callback, err = p.hostKeyCallback(); err != nil {
+		t.Fatal(err)
+	}
+	if err = callback("10.0.0.2:22", remote, unknown); err != nil {
+		t.Errorf("want any key accepted when insecure, got %v", err)
+	}
+
+	p = &hostKeyPolicy{knownHosts: filepath.Join(t.TempDir(), "missing")}
+	if _, err = p.hostKeyCallback(); err == nil || !strings.Contains(err.Error(), "ssh.insecure") {
+		t.Errorf("want an error pointing to ssh.insecure without known_hosts file, got %v", err)
+	}
+}
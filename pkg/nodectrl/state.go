@@ -0,0 +1,226 @@
+package nodectrl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Node states recorded in the state files
+const (
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateKilled  = "killed"
+	StateStopped = "stopped"
+)
+
+const (
+	livenessInterval = 200 * time.Millisecond
+)
+
+// NodeState is the process of a node as last recorded, so the nodes started
+// by one invocation can be stopped or checked by the next one
+type NodeState struct {
+	Id      string    `json:"nodeID"`
+	Pid     string    `json:"pid"`
+	State   string    `json:"state"`
+	Command string    `json:"command"`
+	Updated time.Time `json:"updated"`
+}
+
+// stateFile returns the path of the state file of the node
+func stateFile(nodeId string) string {
+	return filepath.Join(globalStateDir, fmt.Sprintf("node_%v.json", nodeId))
+}
+
+// writeFileAtomic writes the file through a temporary file renamed over it,
+// so a reader never sees a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readState reads the state file of the node, nil if there is none
+func readState(nodeId string) (*NodeState, error) {
+	bytes, err := os.ReadFile(stateFile(nodeId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state NodeState
+	if err = json.Unmarshal(bytes, &state); err != nil {
+		return nil, fmt.Errorf("%v: %v", stateFile(nodeId), err)
+	}
+	return &state, nil
+}
+
+// recordState writes the state of the node process to its state file
+func (n *Node) recordState(state string) error {
+	if err := os.MkdirAll(globalStateDir, 0755); err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(NodeState{
+		Id:      n.Id,
+		Pid:     n.pid,
+		State:   state,
+		Command: n.startCommand(),
+		Updated: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(stateFile(n.Id), bytes)
+}
+
+// loadStates restores the process ids of the nodes from their state files
+func loadStates() error {
+	for i := range globalNodeList.Nodes {
+		node := &globalNodeList.Nodes[i]
+		state, err := readState(node.Id)
+		if err != nil {
+			return err
+		}
+		if state != nil {
+			node.pid = state.Pid
+		}
+	}
+	return nil
+}
+
+// parsePid checks the process id printed by a start command
+func parsePid(out string) (string, error) {
+	pid := strings.TrimSpace(out)
+	if n, err := strconv.Atoi(pid); err != nil || n <= 0 {
+		return "", fmt.Errorf("error parsing pid from %q", out)
+	}
+	return pid, nil
+}
+
+// alive returns whether the process of the node is running. An error means
+// the liveness could not be checked.
+func (n *Node) alive(ctx context.Context) (bool, error) {
+	if n.pid == "" {
+		return false, nil
+	}
+	// kill -0 fails for a process which does not exist, and ps tells a
+	// zombie left by a local node from a running one
+	_, err := n.ctrl.Run(ctx, fmt.Sprintf("kill -0 %v && ! ps -o stat= -p %v | grep -q Z", n.pid, n.pid))
+	if err == nil {
+		return true, nil
+	}
+	if isExitError(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// waitExit polls the liveness of the node until its process is gone, the
+// timeout expires or the context is done, and returns whether it is gone
+func (n *Node) waitExit(ctx context.Context, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		alive, err := n.alive(ctx)
+		if err == nil && !alive {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(livenessInterval):
+		}
+	}
+}
+
+// NodeStatus is the recorded state of a node and whether its process is alive
+type NodeStatus struct {
+	NodeState
+	Transport string
+	Host      string
+	// Alive is "yes", "no", or the error checking it
+	Alive string
+}
+
+// NodesStatus returns the status of every node of the cluster file, the
+// liveness of the nodes being checked in parallel
+func NodesStatus() []NodeStatus {
+	statuses := make([]NodeStatus, len(globalNodeList.Nodes))
+	forEachNode(func(i int, node *Node) {
+		node.mu.Lock()
+		defer node.mu.Unlock()
+
+		status := NodeStatus{
+			NodeState: NodeState{Id: node.Id, Pid: node.pid, Command: node.startCommand()},
+			Transport: node.Transport,
+			Host:      node.IpAddrStr,
+		}
+
+		state, err := readState(node.Id)
+		switch {
+		case err != nil:
+			status.State = err.Error()
+		case state == nil:
+			status.State = "unknown"
+		default:
+			status.NodeState = *state
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), globalNodeTimeout)
+		defer cancel()
+		alive, err := node.alive(ctx)
+		switch {
+		case err != nil:
+			status.Alive = err.Error()
+		case alive:
+			status.Alive = "yes"
+		default:
+			status.Alive = "no"
+		}
+		statuses[i] = status
+	})
+	return statuses
+}
+
+// Configure applies the SSH host key, state directory, parallelism, timeout
+// and agent token properties to the nodes and followers
+func Configure(p *properties.Properties) {
+	SetHostKeyPolicy(p.GetString(prop.SSHKnownHosts, ""), p.GetBool(prop.SSHInsecure, prop.SSHInsecureDefault))
+	globalStateDir = p.GetString(prop.NodesStateDir, prop.NodesStateDirDefault)
+	globalStopTimeout = time.Duration(p.GetInt64(prop.NodesStopTimeout, prop.NodesStopTimeoutDefault)) * time.Second
+	globalParallelism = p.GetInt(prop.NodesParallelism, prop.NodesParallelismDefault)
+	globalNodeTimeout = time.Duration(p.GetInt64(prop.NodesTimeout, prop.NodesTimeoutDefault)) * time.Second
+	globalAgentToken = p.GetString(prop.AgentToken, "")
+}
+
+var (
+	globalStateDir    = prop.NodesStateDirDefault
+	globalStopTimeout = time.Duration(prop.NodesStopTimeoutDefault) * time.Second
+)
@@ -0,0 +1,100 @@
+package nodectrl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node_n1.json")
+	for _, content := range []string{"first", "second, longer than the first"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("want %q, got %q %v", content, data, err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("want no temporary file left, got %v %v", entries, err)
+	}
+
+	if err = writeFileAtomic(filepath.Join(dir, "missing", "node_n1.json"), []byte("x")); err == nil {
+		t.Fatalf("want an error in a missing directory")
+	}
+}
+
+func TestRecordAndReadState(t *testing.T) {
+	defer func(dir string) { globalStateDir = dir }(globalStateDir)
+	globalStateDir = filepath.Join(t.TempDir(), "state")
+
+	state, err := readState("n1")
+	if state != nil || err != nil {
+		t.Fatalf("want no state before it is recorded, got %v %v", state, err)
+	}
+
+	n := &Node{Id: "n1", NodeCommand: "sleep 30", pid: "1234"}
+	if err = n.recordState(StatePaused); err != nil {
+		t.Fatal(err)
+	}
+	state, err = readState("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Id != "n1" || state.Pid != "1234" || state.State != StatePaused || state.Command != "sleep 30" || state.Updated.IsZero() {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	if err = os.WriteFile(stateFile("n2"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readState("n2"); err == nil || !strings.Contains(err.Error(), "node_n2.json") {
+		t.Fatalf("want the corrupt state file reported, got %v", err)
+	}
+}
+
+func TestLoadStates(t *testing.T) {
+	defer func(dir string, list NodeList) { globalStateDir, globalNodeList = dir, list }(globalStateDir, globalNodeList)
+	globalStateDir = t.TempDir()
+	globalNodeList = NodeList{Nodes: []Node{{Id: "n1", pid: "42"}, {Id: "n2"}}}
+	if err := globalNodeList.Nodes[0].recordState(StateRunning); err != nil {
+		t.Fatal(err)
+	}
+
+	globalNodeList.Nodes[0].pid = ""
+	if err := loadStates(); err != nil {
+		t.Fatal(err)
+	}
+	if globalNodeList.Nodes[0].pid != "42" || globalNodeList.Nodes[1].pid != "" {
+		t.Fatalf("want the pid of n1 restored only, got %+v", globalNodeList.Nodes)
+	}
+	if !NodesStarted() {
+		t.Fatalf("want the nodes started")
+	}
+}
+
+func TestParsePid(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want string
+		ok   bool
+	}{
+		{"1234\n", "1234", true},
+		{"  77 ", "77", true},
+		{"", "", false},
+		{"0", "", false},
+		{"-5", "", false},
+		{"12 34", "", false},
+		{"nohup: ignoring input\n1234", "", false},
+	} {
+		got, err := parsePid(tt.out)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parsePid(%q): want %q ok %v, got %q %v", tt.out, tt.want, tt.ok, got, err)
+		}
+	}
+}
@@ -18,6 +18,8 @@ const (
 	InsertStart        = "insertstart"
 	InsertCount        = "insertcount"
 	InsertStartDefault = int64(0)
+	// TransactionInsertStart is the first key a run inserts, recordcount by default
+	TransactionInsertStart = "transactioninsertstart"
 
 	OperationCount     = "operationcount"
 	RecordCount        = "recordcount"
@@ -95,6 +97,9 @@ const (
 
 	DebugPprof        = "debug.pprof"
 	DebugPprofDefault = ":6060"
+	// Serve Prometheus metrics on /metrics of the debug.pprof listener
+	Prometheus        = "debug.prometheus"
+	PrometheusDefault = false
 
 	Verbose         = "verbose"
 	VerboseDefault  = false
@@ -109,6 +114,15 @@ const (
 
 	LogInterval     = "measurement.interval"
 	MeasurementType = "measurement.type"
+	// "op", "intended", "both"
+	MeasurementLatency = "measurement.latency"
+	// Number of raw measurements a worker thread buffers before handing them to the writer
+	RawFlushSize        = "measurement.raw.flushsize"
+	RawFlushSizeDefault = int(1024)
+	// File prefix of the interval metrics and events timeline, empty disables it
+	Timeline              = "measurement.timeline"
+	TimelineFormat        = "measurement.timeline.format"
+	TimelineFormatDefault = "csv"
 
 	Command = "command"
 
@@ -120,7 +134,82 @@ const (
 	LoadDurationDef = int(60)
 	Cluster         = "cluster"
 	Events          = "events"
+	EventsSeed      = "events.seed"
+	EventsClock     = "events.clock"
+	LoadProfile     = "loadprofile"
 	Checker         = "checker"
 	FollowerName    = "follower"
+	RunID           = "runid"
+	RunIDLayout     = "20060102T150405.000000"
 	FollowerList    = "followerlist"
+
+	// Issue operations from an arrival process instead of closed-loop workers
+	LoadModeOpenLoop = "openloop"
+	// "poisson", "constant"
+	OpenLoopArrival        = "openloop.arrival"
+	OpenLoopArrivalDefault = "poisson"
+	// Capacity of the queue between the arrivals and the executors
+	OpenLoopQueueSize        = "openloop.queuesize"
+	OpenLoopQueueSizeDefault = int(1000)
+	// Queueing delay in milliseconds above which a request counts as late
+	OpenLoopLateThreshold        = "openloop.latethreshold"
+	OpenLoopLateThresholdDefault = int64(100)
+
+	// known_hosts file verifying the SSH host keys, ~/.ssh/known_hosts by default
+	SSHKnownHosts = "ssh.knownhosts"
+	// Skip the SSH host key verification
+	SSHInsecure        = "ssh.insecure"
+	SSHInsecureDefault = false
+
+	// Directory of the node state files
+	NodesStateDir        = "nodes.statedir"
+	NodesStateDirDefault = "nodestate"
+	// Seconds a node gets to exit after SIGTERM before it is killed
+	NodesStopTimeout        = "nodes.stoptimeout"
+	NodesStopTimeoutDefault = int64(10)
+	// Nodes started, stopped or acted on at once, 0 for all of them
+	NodesParallelism        = "nodes.parallelism"
+	NodesParallelismDefault = int(16)
+	// Seconds an operation on one node may take before it is aborted
+	NodesTimeout        = "nodes.timeout"
+	NodesTimeoutDefault = int64(60)
+
+	// Address the leader serves the control channel of the followers on
+	ControlListen        = "control.listen"
+	ControlListenDefault = ":7790"
+	// host:port of the leader control channel, set on the followers by the leader
+	ControlLeader = "control.leader"
+	// Secret every request of the followers on the control channel carries,
+	// drawn by the leader for each run when not set
+	ControlToken = "control.token"
+	// Seconds the leader waits for the followers at the start barrier
+	ControlBarrierTimeout        = "control.barriertimeout"
+	ControlBarrierTimeoutDefault = int64(300)
+	// Milliseconds between the opening of the barrier and the shared start time
+	ControlStartDelay        = "control.startdelay"
+	ControlStartDelayDefault = int64(1000)
+	// Milliseconds between the heartbeats of a follower
+	ControlHeartbeat        = "control.heartbeat"
+	ControlHeartbeatDefault = int64(1000)
+	// Seconds without heartbeat after which a follower or the leader is lost
+	ControlHeartbeatTimeout        = "control.heartbeattimeout"
+	ControlHeartbeatTimeoutDefault = int64(10)
+	// Seconds the leader waits for the followers to finish after its own run
+	ControlDoneTimeout        = "control.donetimeout"
+	ControlDoneTimeoutDefault = int64(600)
+	// Whether the leader splits the workload between itself and the followers
+	ControlPartition        = "control.partition"
+	ControlPartitionDefault = true
+	// Timestamp exchanges a follower makes to estimate its clock offset to the leader
+	ControlClockSamples        = "control.clocksamples"
+	ControlClockSamplesDefault = int(8)
+
+	// Address the agent command serves the leaders on
+	AgentListen        = "agent.listen"
+	AgentListenDefault = "127.0.0.1:7791"
+	// Secret shared by the agents and their leaders, which every request to an agent carries
+	AgentToken = "agent.token"
+	// Working directory of the runs of the agent
+	AgentDir        = "agent.dir"
+	AgentDirDefault = "."
 )
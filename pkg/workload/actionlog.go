@@ -0,0 +1,161 @@
+package workload
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+)
+
+var actionLogHeader = []string{"Start", "End", "NodeID", "Action", "Operation", "ExitStatus", "Stdout", "Stderr",
+	"Error", "Expect", "Met"}
+
+// ActionExpect is what an action must produce. An action which does not meet
+// its expectation fails and marks the run invalid.
+type ActionExpect struct {
+	// ExitCode is the exit status the action must end with
+	ExitCode *int `json:"exitcode"`
+	// Output is a regular expression the standard output must match
+	Output string `json:"output"`
+
+	output *regexp.Regexp
+}
+
+func (e *ActionExpect) validate() error {
+	if e.ExitCode == nil && e.Output == "" {
+		return errors.New("expect needs an exitcode or an output")
+	}
+	if e.Output != "" {
+		var err error
+		if e.output, err = regexp.Compile(e.Output); err != nil {
+			return fmt.Errorf("expect output: %v", err)
+		}
+	}
+	return nil
+}
+
+// check returns why the result does not meet the expectation, nil if it does
+func (e *ActionExpect) check(r nodectrl.NodeResult) error {
+	if e.ExitCode != nil && r.ExitStatus != *e.ExitCode {
+		return fmt.Errorf("expected exit status %v, got %v", *e.ExitCode, r.ExitStatus)
+	}
+	if e.output != nil && !e.output.MatchString(r.Stdout) {
+		return fmt.Errorf("expected output matching %q", e.Output)
+	}
+	return nil
+}
+
+func (e *ActionExpect) String() string {
+	var parts []string
+	if e.ExitCode != nil {
+		parts = append(parts, fmt.Sprintf("exitcode=%v", *e.ExitCode))
+	}
+	if e.Output != "" {
+		parts = append(parts, fmt.Sprintf("output=%v", e.Output))
+	}
+	return strings.Join(parts, " ")
+}
+
+// actionLog records the result of every action run against the nodes to
+// <csvfilename>_<follower>_<runid>_actions.csv, next to the raw output, and
+// the actions which did not meet their expectation.
+type actionLog struct {
+	sync.Mutex
+	file      *os.File
+	csvWriter *csv.Writer
+	// unmet describes the actions which did not meet their expectation
+	unmet []string
+}
+
+// open creates the log file, which is kept open until close
+func (l *actionLog) open(p *properties.Properties) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.file != nil {
+		return nil
+	}
+	l.unmet = nil
+
+	f, err := os.Create(measurement.RawFilePrefix(p) + "_actions.csv")
+	if err != nil {
+		return err
+	}
+	l.file = f
+	l.csvWriter = csv.NewWriter(f)
+	l.csvWriter.Write(actionLogHeader)
+	l.csvWriter.Flush()
+	return nil
+}
+
+// record writes the result of the action. expectErr is why the action did
+// not meet its expectation, nil if it did or has none.
+func (l *actionLog) record(a Action, r nodectrl.NodeResult, expectErr error) {
+	l.Lock()
+	defer l.Unlock()
+	if expectErr != nil {
+		l.unmet = append(l.unmet, fmt.Sprintf("action %v on node %v: %v", a, a.NodeID, expectErr))
+	}
+	if l.csvWriter == nil {
+		return
+	}
+
+	errStr, expect, met := "", "", ""
+	if r.Err != nil {
+		errStr = r.Err.Error()
+	}
+	if a.Expect != nil {
+		expect = a.Expect.String()
+		met = strconv.FormatBool(expectErr == nil)
+	}
+	l.csvWriter.Write([]string{
+		strconv.FormatInt(r.Start.UnixNano(), 10),
+		strconv.FormatInt(r.Start.Add(r.Duration).UnixNano(), 10),
+		a.NodeID,
+		a.String(),
+		r.Operation,
+		strconv.Itoa(r.ExitStatus),
+		r.Stdout,
+		r.Stderr,
+		errStr,
+		expect,
+		met,
+	})
+	// flushed at once, so the log is complete up to a crash of the run
+	l.csvWriter.Flush()
+}
+
+func (l *actionLog) close() {
+	l.Lock()
+	defer l.Unlock()
+	if l.file == nil {
+		return
+	}
+	l.csvWriter.Flush()
+	l.file.Close()
+	l.file = nil
+	l.csvWriter = nil
+}
+
+// CloseActionLog closes the actions log of the run
+func CloseActionLog() {
+	globalActionLog.close()
+}
+
+// InvalidRun returns why the run is invalid: the actions which did not meet
+// their expectation. It is empty for a valid run.
+func InvalidRun() []string {
+	globalActionLog.Lock()
+	defer globalActionLog.Unlock()
+	return append([]string(nil), globalActionLog.unmet...)
+}
+
+var globalActionLog actionLog
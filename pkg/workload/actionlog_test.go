@@ -0,0 +1,118 @@
+package workload
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func exitCode(code int) *int {
+	return &code
+}
+
+func TestActionExpect(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		expect ActionExpect
+		result nodectrl.NodeResult
+		// err is a part of the expected validation error
+		err string
+		met bool
+	}{
+		{name: "empty", expect: ActionExpect{}, err: "exitcode or an output"},
+		{name: "invalid output", expect: ActionExpect{Output: "("}, err: "expect output"},
+		{name: "exit code met", expect: ActionExpect{ExitCode: exitCode(0)}, result: nodectrl.NodeResult{ExitStatus: 0}, met: true},
+		{name: "exit code unmet", expect: ActionExpect{ExitCode: exitCode(0)}, result: nodectrl.NodeResult{ExitStatus: 1}},
+		{name: "exit code of a failure", expect: ActionExpect{ExitCode: exitCode(1)}, result: nodectrl.NodeResult{ExitStatus: 1}, met: true},
+		{name: "unreachable node", expect: ActionExpect{ExitCode: exitCode(0)},
+			result: nodectrl.NodeResult{ExitStatus: -1, Err: errors.New("unreachable")}},
+		{name: "output met", expect: ActionExpect{Output: "^stopped"}, result: nodectrl.NodeResult{Stdout: "stopped db1\n"}, met: true},
+		{name: "output unmet", expect: ActionExpect{Output: "^stopped"}, result: nodectrl.NodeResult{Stdout: "not stopped\n"}},
+		{name: "both met", expect: ActionExpect{ExitCode: exitCode(0), Output: "db1"},
+			result: nodectrl.NodeResult{Stdout: "stopped db1\n"}, met: true},
+		{name: "output met, exit code unmet", expect: ActionExpect{ExitCode: exitCode(0), Output: "db1"},
+			result: nodectrl.NodeResult{ExitStatus: 2, Stdout: "stopped db1\n"}},
+	} {
+		err := tt.expect.validate()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+			continue
+		}
+		if err = tt.expect.check(tt.result); (err == nil) != tt.met {
+			t.Errorf("%v: want met %v, got %v", tt.name, tt.met, err)
+		}
+	}
+}
+
+func TestActionLog(t *testing.T) {
+	localCluster(t, 1, "sleep 30")
+	p := properties.NewProperties()
+	p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "run"))
+	p.Set(prop.RunID, "test")
+	if err := globalActionLog.open(p); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseActionLog()
+
+	actions := []Action{
+		{NodeID: "n1", Command: "echo stopped", Expect: &ActionExpect{Output: "^stopped"}},
+		{NodeID: "n1", Command: "echo failed >&2; exit 3", Expect: &ActionExpect{ExitCode: exitCode(0)}},
+		{NodeID: "n1", Command: "true"},
+	}
+	for i := range actions {
+		if err := actions[i].validate(); err != nil {
+			t.Fatal(err)
+		}
+		executeAction(actions[i])
+	}
+	CloseActionLog()
+
+	// the action not meeting its expectation makes the run invalid
+	invalid := InvalidRun()
+	if len(invalid) != 1 || !strings.Contains(invalid[0], "expected exit status 0, got 3") {
+		t.Errorf("want the failed action reported, got %v", invalid)
+	}
+
+	f, err := os.Open(measurement.RawFilePrefix(p) + "_actions.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(actionLogHeader, ",") {
+		t.Fatalf("want the header and 3 actions, got %v", rows)
+	}
+	for i, want := range [][]string{
+		// NodeID, Action, ExitStatus, Stdout, Stderr, Expect, Met
+		{"n1", "echo stopped", "0", "stopped\n", "", "output=^stopped", "true"},
+		{"n1", "echo failed >&2; exit 3", "3", "", "failed\n", "exitcode=0", "false"},
+		{"n1", "true", "0", "", "", "", ""},
+	} {
+		row := rows[i+1]
+		got := []string{row[2], row[3], row[5], row[6], row[7], row[9], row[10]}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("action %v: want %q, got %q", i+1, want, got)
+		}
+		if row[0] > row[1] {
+			t.Errorf("action %v: want the start before the end, got %v and %v", i+1, row[0], row[1])
+		}
+	}
+}
@@ -70,6 +70,7 @@ type core struct {
 	keyChooser                   ycsb.Generator
 	fieldChooser                 ycsb.Generator
 	transactionInsertKeySequence *generator.AcknowledgedCounter
+	transactionInsertStart       int64
 	scanLength                   ycsb.Generator
 	orderedInserts               bool
 	recordCount                  int64
@@ -401,21 +402,31 @@ func (c *core) DoBatchTransaction(ctx context.Context, batchSize int, db ycsb.DB
 
 func (c *core) nextKeyNum(state *coreState) int64 {
 	r := state.r
-	keyNum := int64(0)
+	keyNum := int64(-1)
 	if _, ok := c.keyChooser.(*generator.Exponential); ok {
-		keyNum = -1
 		for keyNum < 0 {
-			keyNum = c.transactionInsertKeySequence.Last() - c.keyChooser.Next(r)
+			keyNum = c.skipOtherParts(c.transactionInsertKeySequence.Last() - c.keyChooser.Next(r))
 		}
 	} else {
-		keyNum = math.MaxInt64
-		for keyNum > c.transactionInsertKeySequence.Last() {
-			keyNum = c.keyChooser.Next(r)
+		for keyNum < 0 || keyNum > c.transactionInsertKeySequence.Last() {
+			keyNum = c.skipOtherParts(c.keyChooser.Next(r))
 		}
 	}
 	return keyNum
 }
 
+// skipOtherParts shifts the keys between the recordcount and the
+// transactioninsertstart below the recordcount. In a partitioned run these
+// keys are inserted by the other parts, which may not have inserted them yet,
+// so a part sees the keys loaded and its own inserts as one contiguous key
+// space. A key shifted below 0 is negative and drawn again.
+func (c *core) skipOtherParts(keyNum int64) int64 {
+	if keyNum >= c.recordCount && keyNum < c.transactionInsertStart {
+		return keyNum - (c.transactionInsertStart - c.recordCount)
+	}
+	return keyNum
+}
+
 func (c *core) doTransactionRead(ctx context.Context, db ycsb.DB, state *coreState) error {
 	r := state.r
 	keyNum := c.nextKeyNum(state)
@@ -656,7 +667,8 @@ func (coreCreator) Create(p *properties.Properties) (ycsb.Workload, error) {
 	c.keySequence = generator.NewCounter(insertStart)
 	c.operationChooser = createOperationGenerator(p)
 
-	c.transactionInsertKeySequence = generator.NewAcknowledgedCounter(c.recordCount)
+	c.transactionInsertStart = p.GetInt64(prop.TransactionInsertStart, c.recordCount)
+	c.transactionInsertKeySequence = generator.NewAcknowledgedCounter(c.transactionInsertStart)
 	switch requestDistrib {
 	case "uniform":
 		c.keyChooser = generator.NewUniform(insertStart, insertStart+insertCount-1)
@@ -0,0 +1,198 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+var eventOutcomeHeader = []string{"Event", "Time(s)", "Firings", "Succeeded", "Failed", "Undone", "Canceled", "Error"}
+
+// eventOutcome counts what happened to the firings of one event.
+type eventOutcome struct {
+	firings   int
+	succeeded int
+	failed    int
+	undone    int
+	canceled  bool
+	lastErr   error
+}
+
+// eventEngine fires the events of a run. It is stopped with the run context
+// or by StopEventWorkload, which waits for the actions in flight.
+type eventEngine struct {
+	events EventList
+	ctx    context.Context
+	cancel context.CancelFunc
+	// running counts the goroutines of the engine, which run the actions
+	running sync.WaitGroup
+
+	startTime time.Time
+	// triggers holds the channel each triggered event waits for, see trigger.go
+	triggers       []<-chan struct{}
+	metricTriggers []*metricTrigger
+	// removeListener removes the interval listener of the metric triggers
+	removeListener func()
+
+	mu       sync.Mutex
+	outcomes []eventOutcome
+}
+
+func newEventEngine(ctx context.Context, events EventList) *eventEngine {
+	en := &eventEngine{
+		events:   events,
+		triggers: make([]<-chan struct{}, len(events)),
+		outcomes: make([]eventOutcome, len(events)),
+	}
+	en.ctx, en.cancel = context.WithCancel(ctx)
+	return en
+}
+
+// start launches the events once the clock channel is closed.
+func (en *eventEngine) start(clock <-chan struct{}, seed int64) {
+	en.running.Add(1)
+	go func() {
+		defer en.running.Done()
+
+		select {
+		case <-en.ctx.Done():
+			en.mu.Lock()
+			for i := range en.outcomes {
+				en.outcomes[i].canceled = true
+			}
+			en.mu.Unlock()
+			return
+		case <-clock:
+		}
+
+		en.startTime = time.Now()
+		for i, event := range en.events {
+			fmt.Printf("Spinning off event {Relative Time:%v, Action Count:%v}\n",
+				event.RelativeTime, len(event.Actions))
+			en.running.Add(1)
+			go func(index int, e Event) {
+				defer en.running.Done()
+				e.run(en, index, rand.New(rand.NewSource(seed+int64(index))))
+			}(i, event)
+		}
+	}()
+}
+
+// sleepUntil waits until the time relative to the engine clock, and returns
+// false if the engine is stopped first.
+func (en *eventEngine) sleepUntil(at time.Duration) bool {
+	d := time.Until(en.startTime.Add(at))
+	if d <= 0 {
+		select {
+		case <-en.ctx.Done():
+			return false
+		default:
+			return true
+		}
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-en.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// undo runs the undo actions at the time relative to the engine clock. When
+// the engine is stopped first they run right away, so the run does not end
+// with the event still applied.
+func (en *eventEngine) undo(index int, at time.Duration, actions []Action) {
+	if len(actions) == 0 {
+		return
+	}
+	en.running.Add(1)
+	go func() {
+		defer en.running.Done()
+		en.sleepUntil(at)
+		fmt.Printf("%v Undoing event {Relative Time:%v, Action Count:%v}\n",
+			time.Now(), en.events[index].RelativeTime, len(actions))
+		for _, err := range executeActions(actions) {
+			en.mu.Lock()
+			if err == nil {
+				en.outcomes[index].undone++
+			} else {
+				en.outcomes[index].failed++
+				en.outcomes[index].lastErr = err
+			}
+			en.mu.Unlock()
+		}
+	}()
+}
+
+func (en *eventEngine) canceledEvent(index int) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.outcomes[index].canceled = true
+}
+
+func (en *eventEngine) record(index int, err error) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	if err == nil {
+		en.outcomes[index].succeeded++
+	} else {
+		en.outcomes[index].failed++
+		en.outcomes[index].lastErr = err
+	}
+}
+
+func (en *eventEngine) fired(index int) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.outcomes[index].firings++
+}
+
+// stop cancels the pending events and waits for the actions in flight.
+func (en *eventEngine) stop() {
+	if en.removeListener != nil {
+		en.removeListener()
+	}
+	en.cancel()
+	en.running.Wait()
+}
+
+func (en *eventEngine) report(outputStyle string) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+
+	lines := make([][]string, 0, len(en.outcomes))
+	for i, o := range en.outcomes {
+		errStr := ""
+		if o.lastErr != nil {
+			errStr = o.lastErr.Error()
+		}
+		lines = append(lines, []string{
+			strconv.Itoa(i + 1),
+			en.events[i].when(),
+			strconv.Itoa(o.firings),
+			strconv.Itoa(o.succeeded),
+			strconv.Itoa(o.failed),
+			strconv.Itoa(o.undone),
+			strconv.FormatBool(o.canceled),
+			errStr,
+		})
+	}
+
+	fmt.Println("Event outcomes:")
+	switch outputStyle {
+	case util.OutputStyleJson:
+		util.RenderJson(eventOutcomeHeader, lines)
+	case util.OutputStyleTable:
+		util.RenderTable(eventOutcomeHeader, lines)
+	default:
+		util.RenderString("%-6s - %s\n", eventOutcomeHeader, lines)
+	}
+}
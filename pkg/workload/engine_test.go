@@ -0,0 +1,83 @@
+package workload
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEngineStartCanceledBeforeClock(t *testing.T) {
+	events := EventList{{RelativeTime: 0}, {RelativeTime: 1}}
+	en := newEventEngine(context.Background(), events)
+	// the clock never starts
+	en.start(make(chan struct{}), 1)
+	en.stop()
+
+	for i, o := range en.outcomes {
+		if o.firings != 0 || !o.canceled {
+			t.Errorf("event %v: want canceled without firing, got %+v", i+1, o)
+		}
+	}
+}
+
+func TestSleepUntil(t *testing.T) {
+	en := newEventEngine(context.Background(), nil)
+	en.startTime = time.Now()
+	if !en.sleepUntil(-time.Second) {
+		t.Error("want a time in the past reached right away")
+	}
+	if !en.sleepUntil(10 * time.Millisecond) {
+		t.Error("want the time reached")
+	}
+	if elapsed := time.Since(en.startTime); elapsed < 10*time.Millisecond {
+		t.Errorf("want to sleep until 10ms, woke up at %v", elapsed)
+	}
+
+	// the stop wakes up the sleepers, and the times reached are refused after it
+	done := make(chan bool)
+	go func() { done <- en.sleepUntil(time.Hour) }()
+	time.Sleep(20 * time.Millisecond)
+	en.stop()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("want the sleep canceled by the stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the sleep ended by the stop")
+	}
+	if en.sleepUntil(-time.Second) {
+		t.Error("want a stopped engine not reaching any time")
+	}
+}
+
+func TestUndoOnStop(t *testing.T) {
+	localCluster(t, 1, "sleep 30")
+	events := EventList{{RelativeTime: 0, Duration: 3600}}
+	en := newEventEngine(context.Background(), events)
+	en.startTime = time.Now()
+
+	// an hour from now, the undo runs right away when the engine stops
+	en.undo(0, time.Hour, []Action{{NodeID: "n1", Command: "true"}, {NodeID: "n1", Command: "false"}})
+	en.undo(0, time.Hour, nil)
+	time.Sleep(20 * time.Millisecond)
+	en.mu.Lock()
+	if o := en.outcomes[0]; o.undone != 0 || o.failed != 0 {
+		t.Errorf("want nothing undone before the time, got %+v", o)
+	}
+	en.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		en.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the stop not waiting for the time of the undo")
+	}
+	if o := en.outcomes[0]; o.undone != 1 || o.failed != 1 || o.lastErr == nil || o.canceled {
+		t.Errorf("want the undo actions run by the stop, got %+v", o)
+	}
+}
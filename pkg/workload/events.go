@@ -1,23 +1,77 @@
 package workload
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
 	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
 	"log"
 	"os"
 	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/magiconair/properties"
 )
 
 type Action struct {
 	NodeID  string `json:"nodeid"`
 	Command string `json:"cmd"`
+	// Type selects a fault injection primitive, see faults.go
+	Type string `json:"type"`
+	// Recover runs the recovery of the fault instead of injecting it
+	Recover bool `json:"recover"`
+	// Sudo runs the fault injection commands with sudo -n
+	Sudo bool `json:"sudo"`
+	// kill
+	Signal string `json:"signal"`
+	// netem-delay
+	Interface string `json:"interface"`
+	Delay     string `json:"delay"`
+	Jitter    string `json:"jitter"`
+	// partition
+	Peers []string `json:"peers"`
+	// disk-slow
+	Cgroup    string `json:"cgroup"`
+	Device    string `json:"device"`
+	ReadBPS   int64  `json:"rbps"`
+	WriteBPS  int64  `json:"wbps"`
+	ReadIOPS  int64  `json:"riops"`
+	WriteIOPS int64  `json:"wiops"`
+	// Expect is the exit status or output the action must produce, see actionlog.go
+	Expect *ActionExpect `json:"expect"`
 }
 
 type Event struct {
-	RelativeTime int      `json:"time"`
+	// RelativeTime in seconds from the start of the workload, fractions allowed
+	RelativeTime float64  `json:"time"`
 	Actions      []Action `json:"actions"`
+	// Duration in seconds after which the actions are undone, 0 keeps them
+	Duration float64 `json:"duration"`
+	// Undo replaces the recovery of the fault actions run after Duration
+	Undo []Action `json:"undo"`
+	// Every fires the event periodically, in seconds
+	Every float64 `json:"every"`
+	// EveryMax draws the period between Every and EveryMax seconds
+	EveryMax float64 `json:"everymax"`
+	// Repeat is the number of firings with Every, 0 fires until the run ends
+	Repeat int `json:"repeat"`
+	// AfterOps fires the event once that many operations were done, Time
+	// then delays it from that point, see trigger.go
+	AfterOps int64 `json:"afterops"`
+	// AfterPercent fires the event once that percentage of the operation count was done
+	AfterPercent float64 `json:"afterpercent"`
+	// ErrorRate fires the event when the share of failed operations in an interval exceeds it
+	ErrorRate float64 `json:"errorrate"`
+	// P99Above fires the event when the 99th percentile latency in microseconds exceeds it
+	P99Above int64 `json:"p99above"`
+	// Intervals is the number of consecutive intervals a metric condition must hold, default 1
+	Intervals int `json:"intervals"`
+	// Operation restricts the metric conditions to one operation, e.g. READ
+	Operation string `json:"operation"`
 }
 
 type EventList []Event
@@ -41,41 +95,156 @@ func (s EventList) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
-// ParseEventList reads the events file to internal data structure
-func ParseEventList(jsonSource string) error {
+// ReadEventList reads and checks the events file, sorted by RelativeTime
+func ReadEventList(jsonSource string) (EventList, error) {
 	bytes, err := os.ReadFile(jsonSource)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var tempList EventWorkload
 	err = json.Unmarshal(bytes, &tempList)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for i := range tempList.Events {
+		err = tempList.Events[i].validate()
+		if err != nil {
+			return nil, fmt.Errorf("event at %vs: %v", tempList.Events[i].RelativeTime, err)
+		}
 	}
 
-	globalEventWorkload = tempList
-	sort.Sort(globalEventWorkload.Events)
+	sort.Stable(tempList.Events)
+	return tempList.Events, nil
+}
+
+// ParseEventList reads the events file to internal data structure
+func ParseEventList(jsonSource string) error {
+	events, err := ReadEventList(jsonSource)
+	if err != nil {
+		return err
+	}
 
+	globalEventWorkload = EventWorkload{Events: events}
 	return nil
 }
 
-// executeAllActions runs the command on the node specified for all actions in the event's list
-func (e *Event) executeAllActions() {
-	fmt.Printf("Executing node actions (Count:%v)\n", len(e.Actions))
-	for _, a := range e.Actions {
-		fmt.Printf("[executeAllActions] Pre Command Call (%v:%v)\n", a.NodeID, a.Command)
-		err := nodectrl.RunNodeCommand(a.NodeID, a.Command)
-		fmt.Printf("[executeAllActions] Post Command Call (%v:%v)\n", a.NodeID, a.Command)
+// CheckEvents checks the events against the cluster node ids and the run
+// properties, and returns a problem per line, numbered as in the outcome report.
+func CheckEvents(events EventList, nodeIds []string, p *properties.Properties) []string {
+	known := make(map[string]bool, len(nodeIds))
+	for _, id := range nodeIds {
+		known[id] = true
+	}
+
+	var problems []string
+	for i, e := range events {
+		for _, actions := range [][]Action{e.Actions, e.Undo} {
+			for _, a := range actions {
+				ids := append([]string{a.NodeID}, a.Peers...)
+				for j, id := range ids {
+					// the random node is drawn for the node of the action only
+					if id == RandomNode && j == 0 && len(nodeIds) > 0 {
+						continue
+					}
+					if !known[id] {
+						problems = append(problems, fmt.Sprintf("event %v: unknown node %q", i+1, id))
+					}
+				}
+			}
+		}
+		if e.AfterPercent > 0 && operationCount(p) <= 0 {
+			problems = append(problems, fmt.Sprintf("event %v: afterpercent needs an operationcount", i+1))
+		}
+		if e.metricTriggered() && !measurement.HistogramCollected(p) {
+			problems = append(problems, fmt.Sprintf("event %v: errorrate and p99above need histogram in measurement.type", i+1))
+		}
+	}
+	return problems
+}
+
+// executeAction runs the action, keeps track of the faults it injects or
+// recovers and records its result to the actions log. An action with an
+// expectation fails when it does not meet it, whatever its exit status.
+func executeAction(a Action) error {
+	fmt.Printf("[executeAction] Pre Command Call (%v:%v)\n", a.NodeID, a)
+	r, err := a.execute()
+	globalFaults.record(a, err)
+	if a.Expect != nil {
+		err = a.Expect.check(r)
+	}
+	globalActionLog.record(a, r, err)
+	measurement.RecordEvent(a.NodeID, a.String(), err)
+	fmt.Printf("[executeAction] Post Command Call (%v:%v)\n", a.NodeID, a)
+	if err != nil {
+		log.Printf("ERROR [executeAction] (%v:%v) - %v\n", a.NodeID, a, err.Error())
+	}
+	return err
+}
+
+// executeActions runs the actions, those of one node in order and the nodes
+// in parallel, at most nodes.parallelism at once. The errors are returned in
+// the order of the actions.
+func executeActions(actions []Action) []error {
+	errs := make([]error, len(actions))
+	var nodes []string
+	byNode := make(map[string][]int)
+	for i, a := range actions {
+		if _, ok := byNode[a.NodeID]; !ok {
+			nodes = append(nodes, a.NodeID)
+		}
+		byNode[a.NodeID] = append(byNode[a.NodeID], i)
+	}
+
+	limit := nodectrl.Parallelism()
+	if limit <= 0 || limit > len(nodes) {
+		limit = len(nodes)
+	}
+	tokens := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(indexes []int) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			for _, i := range indexes {
+				errs[i] = executeAction(actions[i])
+			}
+		}(byNode[node])
+	}
+	wg.Wait()
+	return errs
+}
+
+// RecoverFaults runs the recovery of every fault injected by the events and
+// not recovered yet, so the cluster is left as it was found.
+func RecoverFaults() error {
+	var failed []string
+	recoveries := globalFaults.pending()
+	for _, r := range recoveries {
+		fmt.Printf("Recovering fault (%v:%v)\n", r.NodeID, r)
+	}
+	for i, err := range executeActions(recoveries) {
 		if err != nil {
-			log.Printf("ERROR [executeAllActions] (%v:%v) - %v\n", a.NodeID, a.Command, err.Error())
+			failed = append(failed, fmt.Sprintf("%v: %v", recoveries[i].NodeID, err))
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error recovering faults: %v", strings.Join(failed, "; "))
+	}
+	return nil
 }
 
 // StartEventWorkload spins off multiple go routines to execute the events
-// at the time relative to the start of the workload
-func StartEventWorkload(jsonSource string) error {
+// at the time relative to the clock start: the end of the warm-up, or the
+// start of the workload with events.clock "load". The events are canceled
+// with the context. The events.seed drives the random periods and nodes, the
+// same seed reproduces the same schedule.
+func StartEventWorkload(ctx context.Context, jsonSource string, p *properties.Properties) error {
 	var err error
 	if globalEventWorkload.Events == nil || len(globalEventWorkload.Events) <= 0 {
 		err = ParseEventList(jsonSource)
@@ -84,21 +253,52 @@ func StartEventWorkload(jsonSource string) error {
 		return err
 	}
 
-	for _, event := range globalEventWorkload.Events {
-		fmt.Printf("Spinning off event {Relative Time:%v, Action Count:%v}\n",
-			event.RelativeTime, len(event.Actions))
-		timer := time.NewTimer(time.Duration(event.RelativeTime) * time.Second)
-		go func(e Event, t *time.Timer) {
-			<-t.C
-			fmt.Printf("%v Executing event {Relative Time:%v, Action Count:%v}\n",
-				time.Now(), e.RelativeTime, len(e.Actions))
-			e.executeAllActions()
-			t.Stop()
-			return
-		}(event, timer)
+	clockStart, err := measurement.ClockStart(p)
+	if err != nil {
+		return err
 	}
 
+	seed := p.GetInt64(prop.EventsSeed, time.Now().UnixNano())
+	fmt.Printf("Events seed: %v\n", seed)
+
+	en := newEventEngine(ctx, globalEventWorkload.Events)
+	if err = en.setTriggers(operationCount(p)); err != nil {
+		return err
+	}
+	if err = globalActionLog.open(p); err != nil {
+		return fmt.Errorf("creating the actions log: %v", err)
+	}
+	globalEventEngine = en
+	globalEventEngine.start(clockStart, seed)
 	return nil
 }
 
+// operationCount returns the number of operations the run is configured for.
+func operationCount(p *properties.Properties) int64 {
+	if p.GetBool(prop.DoTransactions, true) {
+		return p.GetInt64(prop.OperationCount, 0)
+	}
+	if _, ok := p.Get(prop.InsertCount); ok {
+		return p.GetInt64(prop.InsertCount, 0)
+	}
+	return p.GetInt64(prop.RecordCount, 0)
+}
+
+// StopEventWorkload cancels the events which did not fire yet and waits for
+// the actions in flight. Pending undo actions run before it returns.
+func StopEventWorkload() {
+	if globalEventEngine != nil {
+		globalEventEngine.stop()
+	}
+}
+
+// ReportEvents prints the outcome of every event of the run.
+func ReportEvents(outputStyle string) {
+	if globalEventEngine != nil {
+		globalEventEngine.report(outputStyle)
+	}
+}
+
 var globalEventWorkload EventWorkload
+var globalFaults faultTracker
+var globalEventEngine *eventEngine
@@ -0,0 +1,80 @@
+package workload
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestReadEventList(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		content string
+		// err is a part of the expected error, empty for a valid list
+		err string
+	}{
+		{"sorted by time", `{"events": [
+			{"time": 2, "actions": [{"nodeid": "n1", "cmd": "true"}]},
+			{"time": 1, "actions": [{"nodeid": "n2", "cmd": "true"}]}
+		]}`, ""},
+		{"invalid json", `{"events": [`, "unexpected end"},
+		{"invalid event", `{"events": [{"time": -1, "actions": [{"nodeid": "n1", "cmd": "true"}]}]}`, "event at -1s"},
+	} {
+		path := filepath.Join(dir, "events.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		events, err := ReadEventList(path)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err == "" && (len(events) != 2 || events[0].RelativeTime != 1):
+			t.Errorf("%v: want the events sorted by time, got %+v", tt.name, events)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		}
+	}
+	if _, err := ReadEventList(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
+
+func TestCheckEvents(t *testing.T) {
+	nodes := []string{"n1", "n2"}
+	for _, tt := range []struct {
+		name  string
+		event Event
+		props map[string]string
+		want  []string
+	}{
+		{"known nodes", Event{Actions: []Action{{NodeID: "n1"}, {NodeID: "n2", Type: ActionPartition, Peers: []string{"n1"}}}}, nil, nil},
+		{"unknown node", Event{Actions: []Action{{NodeID: "n3"}}}, nil, []string{`event 1: unknown node "n3"`}},
+		{"unknown peer", Event{Actions: []Action{{NodeID: "n1", Type: ActionPartition, Peers: []string{"n4"}}}}, nil,
+			[]string{`event 1: unknown node "n4"`}},
+		{"unknown undo node", Event{Actions: []Action{{NodeID: "n1"}}, Undo: []Action{{NodeID: "n5"}}}, nil,
+			[]string{`event 1: unknown node "n5"`}},
+		{"afterpercent without operation count", Event{AfterPercent: 50}, nil,
+			[]string{"event 1: afterpercent needs an operationcount"}},
+		{"afterpercent of the operations", Event{AfterPercent: 50}, map[string]string{prop.OperationCount: "100"}, nil},
+		{"afterpercent of the records loaded", Event{AfterPercent: 50},
+			map[string]string{prop.DoTransactions: "false", prop.RecordCount: "100"}, nil},
+		{"metric without histograms", Event{P99Above: 1000}, nil,
+			[]string{"event 1: errorrate and p99above need histogram in measurement.type"}},
+		{"metric with histograms", Event{ErrorRate: 0.1}, map[string]string{prop.MeasurementType: "raw,histogram"}, nil},
+	} {
+		p := properties.NewProperties()
+		for k, v := range tt.props {
+			p.Set(k, v)
+		}
+		if got := CheckEvents(EventList{tt.event}, nodes, p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: want problems %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
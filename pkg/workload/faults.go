@@ -0,0 +1,353 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+)
+
+// Action types. An action without a type runs its cmd on the node.
+const (
+	ActionCommand    = "cmd"
+	ActionKill       = "kill"
+	ActionPause      = "pause"
+	ActionResume     = "resume"
+	ActionRestart    = "restart"
+	ActionNetemDelay = "netem-delay"
+	ActionPartition  = "partition"
+	ActionDiskSlow   = "disk-slow"
+)
+
+const (
+	defaultSignal    = "KILL"
+	defaultInterface = "eth0"
+)
+
+// The values of the actions put in their shell commands, which must not be
+// read as shell syntax or command options
+var (
+	interfacePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.@-]*$`)
+	devicePattern    = regexp.MustCompile(`^[0-9]+:[0-9]+$`)
+	cgroupPattern    = regexp.MustCompile(`^[A-Za-z0-9_./@-]+$`)
+	hostPattern      = regexp.MustCompile(`^[A-Za-z0-9:][A-Za-z0-9_.:-]*$`)
+)
+
+var validSignals = map[string]bool{
+	"HUP": true, "INT": true, "QUIT": true, "KILL": true, "TERM": true, "USR1": true, "USR2": true,
+}
+
+// normalizeSignal returns the signal name without the SIG prefix.
+func normalizeSignal(signal string) string {
+	return strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+}
+
+// validate checks the parameters of the action and fills in the defaults.
+func (a *Action) validate() error {
+	if a.NodeID == "" {
+		return errors.New("action without nodeid")
+	}
+	if a.Type == "" {
+		a.Type = ActionCommand
+	}
+	if a.Expect != nil {
+		if err := a.Expect.validate(); err != nil {
+			return fmt.Errorf("%v action on node %v: %v", a.Type, a.NodeID, err)
+		}
+	}
+
+	switch a.Type {
+	case ActionCommand:
+		if a.Command == "" {
+			return fmt.Errorf("%v action on node %v needs a cmd", a.Type, a.NodeID)
+		}
+		if a.Recover {
+			return fmt.Errorf("%v action on node %v has no recovery", a.Type, a.NodeID)
+		}
+	case ActionKill:
+		if a.Signal == "" {
+			a.Signal = defaultSignal
+		}
+		a.Signal = normalizeSignal(a.Signal)
+		if !validSignals[a.Signal] {
+			return fmt.Errorf("kill action on node %v: unsupported signal %v, use pause/resume to stop and continue a node", a.NodeID, a.Signal)
+		}
+	case ActionPause, ActionResume:
+	case ActionRestart:
+		if a.Recover {
+			return fmt.Errorf("%v action on node %v has no recovery", a.Type, a.NodeID)
+		}
+	case ActionNetemDelay:
+		if a.Interface == "" {
+			a.Interface = defaultInterface
+		}
+		if !interfacePattern.MatchString(a.Interface) {
+			return fmt.Errorf("netem-delay action on node %v: invalid interface %q", a.NodeID, a.Interface)
+		}
+		if a.Recover {
+			break
+		}
+		if d, err := time.ParseDuration(a.Delay); err != nil || d <= 0 {
+			return fmt.Errorf("netem-delay action on node %v needs a positive delay, e.g. \"100ms\"", a.NodeID)
+		}
+		if a.Jitter != "" {
+			if d, err := time.ParseDuration(a.Jitter); err != nil || d < 0 {
+				return fmt.Errorf("netem-delay action on node %v: invalid jitter %q", a.NodeID, a.Jitter)
+			}
+		}
+	case ActionPartition:
+		if len(a.Peers) == 0 {
+			return fmt.Errorf("partition action on node %v needs the peers to cut off", a.NodeID)
+		}
+		for _, peer := range a.Peers {
+			if peer == RandomNode {
+				return fmt.Errorf("partition action on node %v: the peers must be node ids, %v only picks the node of the action", a.NodeID, RandomNode)
+			}
+			if peer == a.NodeID {
+				return fmt.Errorf("partition action on node %v lists itself as a peer", a.NodeID)
+			}
+		}
+		sort.Strings(a.Peers)
+	case ActionDiskSlow:
+		if a.Cgroup == "" || a.Device == "" {
+			return fmt.Errorf("disk-slow action on node %v needs the cgroup and the device (major:minor)", a.NodeID)
+		}
+		if !devicePattern.MatchString(a.Device) {
+			return fmt.Errorf("disk-slow action on node %v: invalid device %q, use major:minor", a.NodeID, a.Device)
+		}
+		if !cgroupPattern.MatchString(a.Cgroup) || strings.Contains("/"+a.Cgroup+"/", "/../") {
+			return fmt.Errorf("disk-slow action on node %v: invalid cgroup %q", a.NodeID, a.Cgroup)
+		}
+		if !a.Recover && a.ReadBPS <= 0 && a.WriteBPS <= 0 && a.ReadIOPS <= 0 && a.WriteIOPS <= 0 {
+			return fmt.Errorf("disk-slow action on node %v needs a rbps, wbps, riops or wiops limit", a.NodeID)
+		}
+	default:
+		return fmt.Errorf("unsupported action type %q on node %v", a.Type, a.NodeID)
+	}
+	return nil
+}
+
+// isFault returns whether the action injects a fault which has to be recovered.
+func (a *Action) isFault() bool {
+	switch a.Type {
+	case ActionKill, ActionPause, ActionNetemDelay, ActionPartition, ActionDiskSlow:
+		return !a.Recover
+	}
+	return false
+}
+
+// recovery returns the action undoing the fault injected by the action.
+func (a Action) recovery() Action {
+	r := a
+	r.Recover = true
+	// the expectation is the one of the injection
+	r.Expect = nil
+	if a.Type == ActionPause {
+		r.Type = ActionResume
+		r.Recover = false
+	}
+	return r
+}
+
+// faultKey identifies the fault an action injects or recovers, so a recovery
+// clears the matching fault.
+func (a *Action) faultKey() string {
+	t := a.Type
+	switch t {
+	case ActionResume:
+		t = ActionPause
+	case ActionRestart:
+		// a restarted node is running again
+		t = ActionKill
+	}
+	switch t {
+	case ActionNetemDelay:
+		return fmt.Sprintf("%v/%v/%v", a.NodeID, t, a.Interface)
+	case ActionPartition:
+		return fmt.Sprintf("%v/%v/%v", a.NodeID, t, strings.Join(a.Peers, ","))
+	case ActionDiskSlow:
+		return fmt.Sprintf("%v/%v/%v/%v", a.NodeID, t, a.Cgroup, a.Device)
+	}
+	return fmt.Sprintf("%v/%v", a.NodeID, t)
+}
+
+// String describes the action for the logs and the timeline.
+func (a Action) String() string {
+	var s string
+	switch a.Type {
+	case ActionCommand, "":
+		return a.Command
+	case ActionKill:
+		s = fmt.Sprintf("%v -%v", a.Type, a.Signal)
+	case ActionNetemDelay:
+		s = fmt.Sprintf("%v %v %v %v", a.Type, a.Interface, a.Delay, a.Jitter)
+	case ActionPartition:
+		s = fmt.Sprintf("%v %v", a.Type, strings.Join(a.Peers, ","))
+	case ActionDiskSlow:
+		s = fmt.Sprintf("%v %v %v", a.Type, a.Cgroup, a.Device)
+	default:
+		s = a.Type
+	}
+	s = strings.TrimSpace(s)
+	if a.Recover {
+		s = "recover " + s
+	}
+	return s
+}
+
+func (a *Action) sudo(command string) string {
+	if a.Sudo {
+		return "sudo -n " + command
+	}
+	return command
+}
+
+// tcDuration formats a duration in microseconds for tc.
+func tcDuration(s string) string {
+	d, _ := time.ParseDuration(s)
+	return fmt.Sprintf("%dus", d.Microseconds())
+}
+
+// netemCommand returns the tc command adding the delay to the interface,
+// or removing it.
+func (a *Action) netemCommand() string {
+	if a.Recover {
+		return a.sudo(fmt.Sprintf("tc qdisc del dev %v root netem", a.Interface))
+	}
+	cmd := fmt.Sprintf("tc qdisc replace dev %v root netem delay %v", a.Interface, tcDuration(a.Delay))
+	if a.Jitter != "" {
+		cmd += " " + tcDuration(a.Jitter)
+	}
+	return a.sudo(cmd)
+}
+
+// peerHosts returns the hosts of the peers of a partition action, from the
+// cluster file.
+func (a *Action) peerHosts() ([]string, error) {
+	hosts := make([]string, 0, len(a.Peers))
+	for _, peer := range a.Peers {
+		host, err := nodectrl.NodeHost(peer)
+		if err != nil {
+			return nil, err
+		}
+		if !hostPattern.MatchString(host) {
+			return nil, fmt.Errorf("node %v: invalid host %q", peer, host)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// partitionCommand returns the iptables command adding or deleting the rules
+// which drop the traffic between the node and the hosts of its peers.
+func (a *Action) partitionCommand(hosts []string) string {
+	flag := "-A"
+	if a.Recover {
+		flag = "-D"
+	}
+	var cmds []string
+	for _, host := range hosts {
+		cmds = append(cmds,
+			a.sudo(fmt.Sprintf("iptables %v INPUT -s %v -j DROP", flag, host)),
+			a.sudo(fmt.Sprintf("iptables %v OUTPUT -d %v -j DROP", flag, host)))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// diskSlowCommand returns the command setting the io.max limits of the cgroup
+// v2 the node runs in, or lifting them.
+func (a *Action) diskSlowCommand() string {
+	limits := []string{a.Device}
+	for _, l := range []struct {
+		key   string
+		value int64
+	}{{"rbps", a.ReadBPS}, {"wbps", a.WriteBPS}, {"riops", a.ReadIOPS}, {"wiops", a.WriteIOPS}} {
+		if a.Recover {
+			limits = append(limits, l.key+"=max")
+		} else if l.value > 0 {
+			limits = append(limits, fmt.Sprintf("%v=%v", l.key, l.value))
+		}
+	}
+	return fmt.Sprintf("echo '%v' | %v", strings.Join(limits, " "),
+		a.sudo(fmt.Sprintf("tee /sys/fs/cgroup/%v/io.max > /dev/null", strings.TrimPrefix(a.Cgroup, "/"))))
+}
+
+// execute runs the action against its node and returns the result of the
+// node operation.
+func (a *Action) execute() (nodectrl.NodeResult, error) {
+	switch a.Type {
+	case ActionCommand, "":
+		return nodectrl.RunNodeCommand(a.NodeID, a.Command)
+	case ActionKill:
+		if a.Recover {
+			return nodectrl.StartNodeById(a.NodeID)
+		}
+		return nodectrl.SignalNode(a.NodeID, a.Signal)
+	case ActionPause:
+		if a.Recover {
+			return nodectrl.SignalNode(a.NodeID, "CONT")
+		}
+		return nodectrl.SignalNode(a.NodeID, "STOP")
+	case ActionResume:
+		return nodectrl.SignalNode(a.NodeID, "CONT")
+	case ActionRestart:
+		return nodectrl.RestartNodeById(a.NodeID)
+	case ActionNetemDelay:
+		return nodectrl.RunNodeCommand(a.NodeID, a.netemCommand())
+	case ActionPartition:
+		hosts, err := a.peerHosts()
+		if err != nil {
+			return nodectrl.NodeResult{Node: a.NodeID, Operation: a.String(), Start: time.Now(), ExitStatus: -1, Err: err}, err
+		}
+		return nodectrl.RunNodeCommand(a.NodeID, a.partitionCommand(hosts))
+	case ActionDiskSlow:
+		return nodectrl.RunNodeCommand(a.NodeID, a.diskSlowCommand())
+	}
+	err := fmt.Errorf("unsupported action type %q", a.Type)
+	return nodectrl.NodeResult{Node: a.NodeID, Operation: a.String(), Start: time.Now(), ExitStatus: -1, Err: err}, err
+}
+
+// faultTracker remembers the injected faults which were not recovered yet.
+type faultTracker struct {
+	sync.Mutex
+	active map[string]Action
+}
+
+func (t *faultTracker) record(a Action, err error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.active == nil {
+		t.active = make(map[string]Action)
+	}
+
+	key := a.faultKey()
+	if a.isFault() {
+		// a failed injection may have been partially applied, so it is recovered too
+		t.active[key] = a
+	} else if err == nil {
+		delete(t.active, key)
+	}
+}
+
+// pending returns the recovery actions of the faults still active.
+func (t *faultTracker) pending() []Action {
+	t.Lock()
+	defer t.Unlock()
+
+	keys := make([]string, 0, len(t.active))
+	for key := range t.active {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	recoveries := make([]Action, 0, len(keys))
+	for _, key := range keys {
+		recoveries = append(recoveries, t.active[key].recovery())
+	}
+	return recoveries
+}
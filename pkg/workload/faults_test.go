@@ -0,0 +1,248 @@
+package workload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// localCluster parses a cluster file of local nodes n1 to n<count> running
+// the start command, in a temporary working directory for their logs and
+// state files
+func localCluster(t *testing.T, count int, startCommand string) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nodectrl.StopNodes()
+		os.Chdir(wd)
+	})
+
+	nodes := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		nodes = append(nodes, fmt.Sprintf(`{"nodeID": "n%d"}`, i))
+	}
+	cluster := filepath.Join(dir, "cluster.json")
+	content := fmt.Sprintf(`{"transport": "local", "startcommand": %q, "nodes": [%v]}`, startCommand, strings.Join(nodes, ","))
+	if err = os.WriteFile(cluster, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := properties.NewProperties()
+	p.Set(prop.NodesStateDir, filepath.Join(dir, "state"))
+	p.Set(prop.NodesStopTimeout, "1")
+	nodectrl.Configure(p)
+	if err = nodectrl.ParseNodeList(cluster); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActionValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		action Action
+		// err is a part of the expected error, empty for a valid action
+		err  string
+		want Action
+	}{
+		{name: "command by default", action: Action{NodeID: "n1", Command: "true"},
+			want: Action{NodeID: "n1", Command: "true", Type: ActionCommand}},
+		{name: "no node", action: Action{Command: "true"}, err: "without nodeid"},
+		{name: "command without cmd", action: Action{NodeID: "n1"}, err: "needs a cmd"},
+		{name: "command recovery", action: Action{NodeID: "n1", Command: "true", Recover: true}, err: "no recovery"},
+		{name: "kill defaults to KILL", action: Action{NodeID: "n1", Type: ActionKill},
+			want: Action{NodeID: "n1", Type: ActionKill, Signal: "KILL"}},
+		{name: "kill signal normalized", action: Action{NodeID: "n1", Type: ActionKill, Signal: "sigterm"},
+			want: Action{NodeID: "n1", Type: ActionKill, Signal: "TERM"}},
+		{name: "kill with STOP", action: Action{NodeID: "n1", Type: ActionKill, Signal: "STOP"}, err: "unsupported signal"},
+		{name: "restart recovery", action: Action{NodeID: "n1", Type: ActionRestart, Recover: true}, err: "no recovery"},
+		{name: "netem default interface", action: Action{NodeID: "n1", Type: ActionNetemDelay, Delay: "100ms"},
+			want: Action{NodeID: "n1", Type: ActionNetemDelay, Delay: "100ms", Interface: "eth0"}},
+		{name: "netem without delay", action: Action{NodeID: "n1", Type: ActionNetemDelay}, err: "positive delay"},
+		{name: "netem recovery without delay", action: Action{NodeID: "n1", Type: ActionNetemDelay, Recover: true},
+			want: Action{NodeID: "n1", Type: ActionNetemDelay, Recover: true, Interface: "eth0"}},
+		{name: "netem bad interface", action: Action{NodeID: "n1", Type: ActionNetemDelay, Delay: "1s", Interface: "eth0; reboot"}, err: "invalid interface"},
+		{name: "netem option interface", action: Action{NodeID: "n1", Type: ActionNetemDelay, Delay: "1s", Interface: "-x"}, err: "invalid interface"},
+		{name: "netem bad jitter", action: Action{NodeID: "n1", Type: ActionNetemDelay, Delay: "1s", Jitter: "x"}, err: "invalid jitter"},
+		{name: "partition peers sorted", action: Action{NodeID: "n1", Type: ActionPartition, Peers: []string{"n3", "n2"}},
+			want: Action{NodeID: "n1", Type: ActionPartition, Peers: []string{"n2", "n3"}}},
+		{name: "partition without peers", action: Action{NodeID: "n1", Type: ActionPartition}, err: "needs the peers"},
+		{name: "partition with itself", action: Action{NodeID: "n1", Type: ActionPartition, Peers: []string{"n1"}}, err: "itself"},
+		{name: "disk-slow without device", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db"}, err: "cgroup and the device"},
+		{name: "disk-slow without limit", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:0"}, err: "limit"},
+		{name: "disk-slow bad device", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:0 rbps=1", ReadBPS: 1}, err: "invalid device"},
+		{name: "disk-slow bad cgroup", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db/$(reboot)", Device: "8:0", ReadBPS: 1}, err: "invalid cgroup"},
+		{name: "disk-slow cgroup outside", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db/../..", Device: "8:0", ReadBPS: 1}, err: "invalid cgroup"},
+		{name: "disk-slow recovery", action: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:0", Recover: true},
+			want: Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:0", Recover: true}},
+		{name: "bad expectation", action: Action{NodeID: "n1", Command: "true", Expect: &ActionExpect{}}, err: "exitcode or an output"},
+		{name: "unknown type", action: Action{NodeID: "n1", Type: "reboot"}, err: "unsupported action type"},
+	} {
+		a := tt.action
+		err := a.validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		case tt.err == "" && !reflect.DeepEqual(a, tt.want):
+			t.Errorf("%v: want %+v, got %+v", tt.name, tt.want, a)
+		}
+	}
+}
+
+func TestActionCommands(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		action Action
+		hosts  []string
+		want   string
+	}{
+		{name: "netem delay", action: Action{Type: ActionNetemDelay, Interface: "eth1", Delay: "100ms", Jitter: "1.5ms"},
+			want: "tc qdisc replace dev eth1 root netem delay 100000us 1500us"},
+		{name: "netem recovery", action: Action{Type: ActionNetemDelay, Interface: "eth1", Recover: true, Sudo: true},
+			want: "sudo -n tc qdisc del dev eth1 root netem"},
+		{name: "partition", action: Action{Type: ActionPartition, Sudo: true}, hosts: []string{"10.0.0.2", "fe80::1"},
+			want: "sudo -n iptables -A INPUT -s 10.0.0.2 -j DROP && sudo -n iptables -A OUTPUT -d 10.0.0.2 -j DROP && " +
+				"sudo -n iptables -A INPUT -s fe80::1 -j DROP && sudo -n iptables -A OUTPUT -d fe80::1 -j DROP"},
+		{name: "partition recovery", action: Action{Type: ActionPartition, Recover: true}, hosts: []string{"db2"},
+			want: "iptables -D INPUT -s db2 -j DROP && iptables -D OUTPUT -d db2 -j DROP"},
+		{name: "disk-slow", action: Action{Type: ActionDiskSlow, Cgroup: "/system.slice/db.service", Device: "8:0", ReadBPS: 1048576, WriteIOPS: 100, Sudo: true},
+			want: "echo '8:0 rbps=1048576 wiops=100' | sudo -n tee /sys/fs/cgroup/system.slice/db.service/io.max > /dev/null"},
+		{name: "disk-slow recovery", action: Action{Type: ActionDiskSlow, Cgroup: "db", Device: "8:0", Recover: true},
+			want: "echo '8:0 rbps=max wbps=max riops=max wiops=max' | tee /sys/fs/cgroup/db/io.max > /dev/null"},
+	} {
+		var got string
+		switch tt.action.Type {
+		case ActionNetemDelay:
+			got = tt.action.netemCommand()
+		case ActionPartition:
+			got = tt.action.partitionCommand(tt.hosts)
+		case ActionDiskSlow:
+			got = tt.action.diskSlowCommand()
+		}
+		if got != tt.want {
+			t.Errorf("%v: want %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	for host, valid := range map[string]bool{
+		"10.0.0.2": true, "db-2.example.com": true, "fe80::1": true,
+		"": false, "-x": false, "10.0.0.2; reboot": false, "$(reboot)": false, "a b": false,
+	} {
+		if got := hostPattern.MatchString(host); got != valid {
+			t.Errorf("host %q: want valid %v, got %v", host, valid, got)
+		}
+	}
+}
+
+func TestFaultKey(t *testing.T) {
+	pause := Action{NodeID: "n1", Type: ActionPause}
+	netem := Action{NodeID: "n1", Type: ActionNetemDelay, Interface: "eth0", Delay: "10ms"}
+	partition := Action{NodeID: "n1", Type: ActionPartition, Peers: []string{"n2", "n3"}}
+	for _, tt := range []struct {
+		name string
+		a, b Action
+		same bool
+	}{
+		{"resume clears pause", pause, Action{NodeID: "n1", Type: ActionResume}, true},
+		{"pause recovery", pause, pause.recovery(), true},
+		{"restart clears kill", Action{NodeID: "n1", Type: ActionKill}, Action{NodeID: "n1", Type: ActionRestart}, true},
+		{"other node", pause, Action{NodeID: "n2", Type: ActionPause}, false},
+		{"netem recovery", netem, netem.recovery(), true},
+		{"netem other interface", netem, Action{NodeID: "n1", Type: ActionNetemDelay, Interface: "eth1"}, false},
+		{"partition recovery", partition, partition.recovery(), true},
+		{"partition other peers", partition, Action{NodeID: "n1", Type: ActionPartition, Peers: []string{"n2"}}, false},
+		{"disk-slow other device", Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:0"},
+			Action{NodeID: "n1", Type: ActionDiskSlow, Cgroup: "db", Device: "8:16"}, false},
+	} {
+		if same := tt.a.faultKey() == tt.b.faultKey(); same != tt.same {
+			t.Errorf("%v: %v and %v, want same key %v", tt.name, tt.a.faultKey(), tt.b.faultKey(), tt.same)
+		}
+	}
+
+	recovery := pause.recovery()
+	if recovery.Type != ActionResume || recovery.Recover {
+		t.Errorf("want a pause recovered by a resume, got %+v", recovery)
+	}
+}
+
+func TestFaultTracker(t *testing.T) {
+	kill := Action{NodeID: "n2", Type: ActionKill, Signal: "KILL"}
+	pause := Action{NodeID: "n1", Type: ActionPause}
+	netem := Action{NodeID: "n1", Type: ActionNetemDelay, Interface: "eth0", Delay: "10ms", Expect: &ActionExpect{Output: "x"}}
+
+	var tracker faultTracker
+	tracker.record(kill, nil)
+	tracker.record(pause, nil)
+	// a failed injection may be partially applied
+	tracker.record(netem, fmt.Errorf("tc failed"))
+	// commands are not faults
+	tracker.record(Action{NodeID: "n1", Type: ActionCommand, Command: "true"}, nil)
+
+	pending := tracker.pending()
+	if len(pending) != 3 {
+		t.Fatalf("want the 3 faults pending, got %+v", pending)
+	}
+	// sorted by fault key
+	if pending[0].Type != ActionNetemDelay || pending[1].Type != ActionResume || pending[2].Type != ActionKill {
+		t.Fatalf("want the recoveries in fault key order, got %+v", pending)
+	}
+	if !pending[0].Recover || pending[0].Expect != nil {
+		t.Fatalf("want a recovery without the expectation of the injection, got %+v", pending[0])
+	}
+
+	// a failed recovery leaves the fault pending
+	tracker.record(Action{NodeID: "n1", Type: ActionResume}, fmt.Errorf("unreachable"))
+	if len(tracker.pending()) != 3 {
+		t.Fatalf("want the pause still pending after a failed resume")
+	}
+	tracker.record(Action{NodeID: "n1", Type: ActionResume}, nil)
+	tracker.record(netem.recovery(), nil)
+	pending = tracker.pending()
+	if len(pending) != 1 || pending[0].NodeID != "n2" || !pending[0].Recover {
+		t.Fatalf("want only the kill pending, got %+v", pending)
+	}
+}
+
+func TestRecoverFaultsOnShutdown(t *testing.T) {
+	localCluster(t, 2, "sleep 30")
+	defer func() { globalFaults = faultTracker{} }()
+
+	// n1 was killed and never restarted by the events
+	globalFaults.record(Action{NodeID: "n1", Type: ActionKill, Signal: "KILL"}, nil)
+	if err := RecoverFaults(); err != nil {
+		t.Fatal(err)
+	}
+	if pending := globalFaults.pending(); len(pending) != 0 {
+		t.Fatalf("want no fault left, got %+v", pending)
+	}
+	statuses := nodectrl.NodesStatus()
+	if statuses[0].State != nodectrl.StateRunning || statuses[0].Alive != "yes" {
+		t.Fatalf("want n1 started again, got %+v", statuses[0])
+	}
+	if statuses[1].State != "unknown" {
+		t.Fatalf("want n2 left alone, got %+v", statuses[1])
+	}
+
+	// a recovery which fails is reported, and stays pending
+	globalFaults.record(Action{NodeID: "n9", Type: ActionPause}, nil)
+	if err := RecoverFaults(); err == nil || !strings.Contains(err.Error(), "n9") {
+		t.Fatalf("want the failed recovery of n9 reported, got %v", err)
+	}
+	if len(globalFaults.pending()) != 1 {
+		t.Fatalf("want the pause of n9 still pending")
+	}
+}
@@ -0,0 +1,74 @@
+package workload
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Partition returns the properties which make the client part, out of
+// parts, run its share of the workload of p, so the parts together run the
+// workload of p. A load splits the insert range, insertstart and insertcount
+// or the recordcount, into contiguous key ranges; a run splits the
+// operationcount and gives each part its own range of the keys it inserts
+// after the recordcount, which the other parts do not read. The target
+// throughput is split as well.
+func Partition(p *properties.Properties, part, parts int) map[string]string {
+	slice := make(map[string]string)
+	if parts <= 1 {
+		return slice
+	}
+
+	if p.GetBool(prop.DoTransactions, true) {
+		recordCount := p.GetInt64(prop.RecordCount, prop.RecordCountDefault)
+		if recordCount == 0 {
+			// as the core workload does
+			recordCount = math.MaxInt32
+		}
+		// without an operationcount the keys after the recordcount are split
+		insertRange := math.MaxInt64 - recordCount
+		if count := p.GetInt64(prop.OperationCount, 0); count > 0 {
+			_, share := split(count, part, parts)
+			slice[prop.OperationCount] = strconv.FormatInt(share, 10)
+			// an operation inserts one key at most
+			insertRange = count
+		}
+		offset, _ := split(insertRange, part, parts)
+		slice[prop.TransactionInsertStart] = strconv.FormatInt(recordCount+offset, 10)
+	} else {
+		insertStart := p.GetInt64(prop.InsertStart, prop.InsertStartDefault)
+		insertCount := p.GetInt64(prop.InsertCount, p.GetInt64(prop.RecordCount, prop.RecordCountDefault)-insertStart)
+		if insertCount > 0 {
+			offset, share := split(insertCount, part, parts)
+			slice[prop.InsertStart] = strconv.FormatInt(insertStart+offset, 10)
+			slice[prop.InsertCount] = strconv.FormatInt(share, 10)
+		}
+	}
+
+	if target := p.GetInt64(prop.Target, 0); target > 0 {
+		_, share := split(target, part, parts)
+		if share == 0 {
+			// a target of 0 is unthrottled
+			share = 1
+		}
+		slice[prop.Target] = strconv.FormatInt(share, 10)
+	}
+	return slice
+}
+
+// split returns the offset and the size of the part-th of parts contiguous
+// shares of count, the first count % parts shares being one larger
+func split(count int64, part, parts int) (int64, int64) {
+	n, i := int64(parts), int64(part)
+	share, rem := count/n, count%n
+	offset := i*share + i
+	if i >= rem {
+		offset = i*share + rem
+	} else {
+		share++
+	}
+	return offset, share
+}
@@ -0,0 +1,195 @@
+package workload
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestSplit(t *testing.T) {
+	for _, c := range []struct {
+		count   int64
+		parts   int
+		offsets []int64
+		shares  []int64
+	}{
+		{10, 1, []int64{0}, []int64{10}},
+		{10, 2, []int64{0, 5}, []int64{5, 5}},
+		{10, 3, []int64{0, 4, 7}, []int64{4, 3, 3}},
+		{11, 4, []int64{0, 3, 6, 9}, []int64{3, 3, 3, 2}},
+		{2, 3, []int64{0, 1, 2}, []int64{1, 1, 0}},
+		{0, 2, []int64{0, 0}, []int64{0, 0}},
+	} {
+		var next int64
+		for part := 0; part < c.parts; part++ {
+			offset, share := split(c.count, part, c.parts)
+			if offset != c.offsets[part] || share != c.shares[part] {
+				t.Errorf("split(%v, %v, %v): want %v, %v, got %v, %v",
+					c.count, part, c.parts, c.offsets[part], c.shares[part], offset, share)
+			}
+			// the shares are contiguous and cover the count
+			if offset != next {
+				t.Errorf("split(%v, %v, %v): want offset %v after the previous share, got %v", c.count, part, c.parts, next, offset)
+			}
+			next = offset + share
+		}
+		if next != c.count {
+			t.Errorf("split(%v, _, %v): want the shares to cover %v, got %v", c.count, c.parts, c.count, next)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	maxInsert := func(part int) string {
+		offset, _ := split(math.MaxInt64-1000, part, 3)
+		return strconv.FormatInt(1000+offset, 10)
+	}
+	for _, c := range []struct {
+		name  string
+		props map[string]string
+		parts int
+		want  []map[string]string
+	}{
+		{
+			name:  "one part",
+			props: map[string]string{prop.RecordCount: "1000", prop.OperationCount: "10", prop.Target: "100"},
+			parts: 1,
+			want:  []map[string]string{{}},
+		},
+		{
+			name:  "run",
+			props: map[string]string{prop.RecordCount: "1000", prop.OperationCount: "10"},
+			parts: 3,
+			want: []map[string]string{
+				{prop.OperationCount: "4", prop.TransactionInsertStart: "1000"},
+				{prop.OperationCount: "3", prop.TransactionInsertStart: "1004"},
+				{prop.OperationCount: "3", prop.TransactionInsertStart: "1007"},
+			},
+		},
+		{
+			name:  "run without operationcount",
+			props: map[string]string{prop.RecordCount: "1000"},
+			parts: 3,
+			want: []map[string]string{
+				{prop.TransactionInsertStart: maxInsert(0)},
+				{prop.TransactionInsertStart: maxInsert(1)},
+				{prop.TransactionInsertStart: maxInsert(2)},
+			},
+		},
+		{
+			name:  "run without recordcount",
+			props: map[string]string{prop.OperationCount: "4"},
+			parts: 2,
+			want: []map[string]string{
+				{prop.OperationCount: "2", prop.TransactionInsertStart: strconv.Itoa(math.MaxInt32)},
+				{prop.OperationCount: "2", prop.TransactionInsertStart: strconv.Itoa(math.MaxInt32 + 2)},
+			},
+		},
+		{
+			name:  "load of the recordcount",
+			props: map[string]string{prop.DoTransactions: "false", prop.RecordCount: "10", prop.Target: "5"},
+			parts: 3,
+			want: []map[string]string{
+				{prop.InsertStart: "0", prop.InsertCount: "4", prop.Target: "2"},
+				{prop.InsertStart: "4", prop.InsertCount: "3", prop.Target: "2"},
+				{prop.InsertStart: "7", prop.InsertCount: "3", prop.Target: "1"},
+			},
+		},
+		{
+			name:  "load of an insert range",
+			props: map[string]string{prop.DoTransactions: "false", prop.RecordCount: "100", prop.InsertStart: "50", prop.InsertCount: "5"},
+			parts: 2,
+			want: []map[string]string{
+				{prop.InsertStart: "50", prop.InsertCount: "3"},
+				{prop.InsertStart: "53", prop.InsertCount: "2"},
+			},
+		},
+		{
+			name:  "target lower than the parts",
+			props: map[string]string{prop.RecordCount: "10", prop.OperationCount: "2", prop.Target: "1"},
+			parts: 2,
+			want: []map[string]string{
+				{prop.OperationCount: "1", prop.TransactionInsertStart: "10", prop.Target: "1"},
+				{prop.OperationCount: "1", prop.TransactionInsertStart: "11", prop.Target: "1"},
+			},
+		},
+	} {
+		p := properties.NewProperties()
+		for key, value := range c.props {
+			p.Set(key, value)
+		}
+		for part := 0; part < c.parts; part++ {
+			if got := Partition(p, part, c.parts); !reflect.DeepEqual(got, c.want[part]) {
+				t.Errorf("%v: part %v: want %v, got %v", c.name, part, c.want[part], got)
+			}
+		}
+	}
+}
+
+func TestCoreInsertsFromPartition(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.RecordCount, "1000")
+	p.Set(prop.OperationCount, "10")
+	for part, want := range []int64{1000, 1005} {
+		pp := properties.NewProperties()
+		pp.Merge(p)
+		for key, value := range Partition(p, part, 2) {
+			pp.Set(key, value)
+		}
+		w, err := coreCreator{}.Create(pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.(*core).transactionInsertKeySequence.Next(nil); got != want {
+			t.Errorf("part %v: want the first key inserted %v, got %v", part, want, got)
+		}
+	}
+}
+
+func TestCoreReadsSkipOtherParts(t *testing.T) {
+	for _, distribution := range []string{"zipfian", "latest", "exponential"} {
+		p := properties.NewProperties()
+		p.Set(prop.RecordCount, "1000")
+		p.Set(prop.OperationCount, "10")
+		p.Set(prop.InsertProportion, "0.5")
+		p.Set(prop.RequestDistribution, distribution)
+		pp := properties.NewProperties()
+		pp.Merge(p)
+		// the second part inserts from 1005, the first from 1000
+		for key, value := range Partition(p, 1, 2) {
+			pp.Set(key, value)
+		}
+		w, err := coreCreator{}.Create(pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := w.(*core)
+		state := &coreState{r: rand.New(rand.NewSource(1))}
+
+		// the keys of the second part are read once inserted
+		for _, inserted := range []int64{0, 2} {
+			for i := int64(0); i < inserted; i++ {
+				c.transactionInsertKeySequence.Acknowledge(c.transactionInsertKeySequence.Next(nil))
+			}
+			own := 0
+			for i := 0; i < 10000; i++ {
+				keyNum := c.nextKeyNum(state)
+				if keyNum < 0 || keyNum >= 1005+inserted || (keyNum >= 1000 && keyNum < 1005) {
+					t.Fatalf("%v: %v inserted: want a key loaded or inserted by the part, got %v", distribution, inserted, keyNum)
+				}
+				if keyNum >= 1005 {
+					own++
+				}
+			}
+			if inserted > 0 && distribution != "zipfian" && own == 0 {
+				t.Errorf("%v: want the keys inserted by the part read", distribution)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/nodectrl"
+)
+
+// RandomNode as the nodeid of an action picks a random node of the cluster
+// every time the event fires. All the random actions of one firing, and
+// their undo actions, target the same node.
+const RandomNode = "*"
+
+// seconds converts a time in seconds from the events file to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// validate checks the schedule and the actions of the event.
+func (e *Event) validate() error {
+	if e.RelativeTime < 0 || e.Duration < 0 || e.Every < 0 || e.Repeat < 0 {
+		return errors.New("time, duration, every and repeat must not be negative")
+	}
+	if e.EveryMax != 0 && e.EveryMax < e.Every {
+		return errors.New("everymax must not be lower than every")
+	}
+	if e.Repeat > 1 && e.Every <= 0 {
+		return errors.New("repeat needs every")
+	}
+	if e.Duration > 0 && e.Every > 0 && e.Duration >= e.Every {
+		return errors.New("duration must be shorter than every, the undo would overlap the next firing")
+	}
+	if err := e.validateTrigger(); err != nil {
+		return err
+	}
+
+	for i := range e.Actions {
+		if err := e.Actions[i].validate(); err != nil {
+			return err
+		}
+	}
+	for i := range e.Undo {
+		if err := e.Undo[i].validate(); err != nil {
+			return fmt.Errorf("undo: %v", err)
+		}
+	}
+	return nil
+}
+
+// firings returns how many times the event fires, 0 meaning until the end of the run.
+func (e *Event) firings() int {
+	if e.Every <= 0 {
+		return 1
+	}
+	return e.Repeat
+}
+
+// period returns the time to the next firing, drawn between every and
+// everymax for a random schedule.
+func (e *Event) period(r *rand.Rand) time.Duration {
+	if e.EveryMax > e.Every {
+		return seconds(e.Every + r.Float64()*(e.EveryMax-e.Every))
+	}
+	return seconds(e.Every)
+}
+
+// resolve replaces the random node of the actions by the node drawn.
+func resolve(actions []Action, node string) []Action {
+	resolved := make([]Action, len(actions))
+	for i, a := range actions {
+		if a.NodeID == RandomNode {
+			a.NodeID = node
+		}
+		resolved[i] = a
+	}
+	return resolved
+}
+
+// undoActions returns the actions run after the duration of the event: the
+// undo list of the event if there is one, else the recovery of its faults.
+func (e *Event) undoActions(fired []Action, node string) []Action {
+	if len(e.Undo) > 0 {
+		return resolve(e.Undo, node)
+	}
+	var undo []Action
+	for _, a := range fired {
+		if a.isFault() {
+			undo = append(undo, a.recovery())
+		}
+	}
+	return undo
+}
+
+// drawNode picks the random node of a firing.
+func drawNode(r *rand.Rand) (string, error) {
+	ids := nodectrl.NodeIds()
+	if len(ids) == 0 {
+		return "", errors.New("no node to pick a random node from")
+	}
+	return ids[r.Intn(len(ids))], nil
+}
+
+// run fires the event at its times relative to the start of the engine
+// clock, until its firings are done or the engine is stopped. A triggered
+// event counts its times from the trigger instead. Each event draws from its
+// own seeded source, so a schedule is reproduced exactly by the seed whatever
+// the order the goroutines run in.
+func (e Event) run(en *eventEngine, index int, r *rand.Rand) {
+	triggeredAt, ok := en.waitTrigger(index)
+	if !ok {
+		en.canceledEvent(index)
+		return
+	}
+	at := triggeredAt + seconds(e.RelativeTime)
+	for n := 1; ; n++ {
+		if !en.sleepUntil(at) {
+			en.canceledEvent(index)
+			return
+		}
+
+		node, err := drawNode(r)
+		fired := resolve(e.Actions, node)
+		fmt.Printf("%v Executing event {Relative Time:%v, Firing:%v, Action Count:%v}\n",
+			time.Now(), at.Round(time.Millisecond).Seconds(), n, len(fired))
+		if err != nil && e.hasRandomNode() {
+			fmt.Printf("Skipping event {Relative Time:%v}: %v\n", at.Seconds(), err)
+			en.record(index, err)
+		} else {
+			for _, err := range executeActions(fired) {
+				en.record(index, err)
+			}
+			if e.Duration > 0 {
+				en.undo(index, at+seconds(e.Duration), e.undoActions(fired, node))
+			}
+		}
+		en.fired(index)
+
+		if total := e.firings(); total > 0 && n >= total {
+			return
+		}
+		at += e.period(r)
+	}
+}
+
+func (e *Event) hasRandomNode() bool {
+	for _, actions := range [][]Action{e.Actions, e.Undo} {
+		for _, a := range actions {
+			if a.NodeID == RandomNode {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package workload
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+)
+
+func TestEventValidate(t *testing.T) {
+	action := []Action{{NodeID: "n1", Command: "true"}}
+	for _, tt := range []struct {
+		name  string
+		event Event
+		err   string
+	}{
+		{"fractional times", Event{RelativeTime: 0.25, Duration: 0.5, Every: 1.5, EveryMax: 2.5, Repeat: 3, Actions: action}, ""},
+		{"negative time", Event{RelativeTime: -1, Actions: action}, "negative"},
+		{"everymax below every", Event{Every: 2, EveryMax: 1, Actions: action}, "everymax"},
+		{"repeat without every", Event{Repeat: 2, Actions: action}, "repeat needs every"},
+		{"duration overlapping", Event{Every: 1, Duration: 1, Actions: action}, "shorter than every"},
+		{"random peer", Event{Actions: []Action{{NodeID: "*", Type: ActionPartition, Peers: []string{"*"}}}}, "peers must be node ids"},
+		{"random peer in undo", Event{Duration: 1, Actions: action,
+			Undo: []Action{{NodeID: "n1", Type: ActionPartition, Peers: []string{"n2", "*"}, Recover: true}}}, "undo"},
+	} {
+		err := tt.event.validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestCheckEventsRandomNode(t *testing.T) {
+	events := EventList{
+		{Actions: []Action{{NodeID: "*", Type: ActionPartition, Peers: []string{"n2"}}}},
+		{Actions: []Action{{NodeID: "n1", Type: ActionPartition, Peers: []string{"*"}}}},
+		{Actions: []Action{{NodeID: "*", Type: ActionKill}}},
+	}
+	problems := CheckEvents(events, []string{"n1", "n2"}, properties.NewProperties())
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "event 2:") {
+		t.Fatalf("want only the random peer of event 2 reported, got %v", problems)
+	}
+	problems = CheckEvents(events[2:], nil, properties.NewProperties())
+	if len(problems) != 1 {
+		t.Fatalf("want the random node reported without a cluster, got %v", problems)
+	}
+}
+
+func TestEventFirings(t *testing.T) {
+	for _, tt := range []struct {
+		event Event
+		want  int
+	}{
+		{Event{RelativeTime: 1}, 1},
+		// repeat without every is rejected, a single firing otherwise
+		{Event{Repeat: 1}, 1},
+		{Event{Every: 1, Repeat: 3}, 3},
+		{Event{Every: 1}, 0},
+	} {
+		if got := tt.event.firings(); got != tt.want {
+			t.Errorf("%+v: want %v firings, got %v", tt.event, tt.want, got)
+		}
+	}
+
+	fixed := Event{Every: 1.5}
+	random := Event{Every: 1, EveryMax: 3}
+	r := rand.New(rand.NewSource(1))
+	varied := false
+	for i := 0; i < 100; i++ {
+		if got := fixed.period(r); got != 1500*time.Millisecond {
+			t.Fatalf("want a fixed period of 1.5s, got %v", got)
+		}
+		got := random.period(r)
+		if got < time.Second || got > 3*time.Second {
+			t.Fatalf("want a period between every and everymax, got %v", got)
+		}
+		varied = varied || got != random.period(r)
+	}
+	if !varied {
+		t.Fatalf("want the periods drawn between every and everymax")
+	}
+}
+
+// drawSchedule returns the random nodes and periods of the firings of the
+// event index drawn from the seed, the way the engine seeds the events
+func drawSchedule(t *testing.T, seed int64, index int, e Event, firings int) ([]string, []time.Duration) {
+	r := rand.New(rand.NewSource(seed + int64(index)))
+	var nodes []string
+	var periods []time.Duration
+	for i := 0; i < firings; i++ {
+		node, err := drawNode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+		periods = append(periods, e.period(r))
+	}
+	return nodes, periods
+}
+
+func TestSeededScheduleReproducible(t *testing.T) {
+	localCluster(t, 5, "sleep 30")
+	e := Event{Every: 1, EveryMax: 10}
+
+	nodes, periods := drawSchedule(t, 42, 0, e, 20)
+	again, againPeriods := drawSchedule(t, 42, 0, e, 20)
+	if !reflect.DeepEqual(nodes, again) || !reflect.DeepEqual(periods, againPeriods) {
+		t.Fatalf("want the same schedule from the same seed, got %v %v and %v %v", nodes, periods, again, againPeriods)
+	}
+	other, otherPeriods := drawSchedule(t, 43, 0, e, 20)
+	if reflect.DeepEqual(nodes, other) && reflect.DeepEqual(periods, otherPeriods) {
+		t.Fatalf("want another schedule from another seed, got %v", other)
+	}
+	// the next event draws from its own source
+	next, _ := drawSchedule(t, 42, 1, e, 20)
+	if reflect.DeepEqual(nodes, next) {
+		t.Fatalf("want the events drawing from their own sources, got %v", next)
+	}
+}
+
+func TestEngineFiresFractionalSchedule(t *testing.T) {
+	localCluster(t, 3, "sleep 30")
+	events := EventList{
+		// three firings 50ms apart, from 20ms on
+		{RelativeTime: 0.02, Every: 0.05, Repeat: 3, Actions: []Action{{NodeID: "*", Command: "true"}}},
+		// until the end of the run, every 20 to 40ms
+		{RelativeTime: 0.01, Every: 0.02, EveryMax: 0.04, Actions: []Action{{NodeID: "n1", Command: "true"}}},
+		// past the end of the run
+		{RelativeTime: 0.5, Actions: []Action{{NodeID: "n2", Command: "true"}}},
+	}
+	for i := range events {
+		if err := events[i].validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	en := newEventEngine(context.Background(), events)
+	start := make(chan struct{})
+	close(start)
+	en.start(start, 7)
+	time.Sleep(300 * time.Millisecond)
+	en.stop()
+
+	repeated, periodic, late := en.outcomes[0], en.outcomes[1], en.outcomes[2]
+	if repeated.firings != 3 || repeated.succeeded != 3 || repeated.canceled {
+		t.Errorf("want the 3 repeats fired, got %+v", repeated)
+	}
+	if periodic.firings < 5 || periodic.firings > 30 || periodic.failed != 0 || !periodic.canceled {
+		t.Errorf("want the periodic event fired until the end of the run, got %+v", periodic)
+	}
+	if late.firings != 0 || !late.canceled {
+		t.Errorf("want the event past the end of the run canceled, got %+v", late)
+	}
+}
@@ -0,0 +1,180 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+)
+
+const errorSuffix = "_ERROR"
+
+// clientOps are measured by the client itself and are not operations of the
+// database, so they do not count in the metric conditions.
+var clientOps = map[string]bool{measurement.OpQueue: true, measurement.OpDropped: true, measurement.OpLate: true}
+
+// metricTriggered returns whether the event waits for a metric condition.
+func (e *Event) metricTriggered() bool {
+	return e.ErrorRate > 0 || e.P99Above > 0
+}
+
+// when describes the time of the event for the outcome report.
+func (e *Event) when() string {
+	var trigger string
+	switch {
+	case e.AfterOps > 0:
+		trigger = fmt.Sprintf("%v ops", e.AfterOps)
+	case e.AfterPercent > 0:
+		trigger = fmt.Sprintf("%v%% ops", e.AfterPercent)
+	case e.ErrorRate > 0 && e.P99Above > 0:
+		trigger = fmt.Sprintf("errorrate %v or p99 %vus", e.ErrorRate, e.P99Above)
+	case e.ErrorRate > 0:
+		trigger = fmt.Sprintf("errorrate %v", e.ErrorRate)
+	case e.P99Above > 0:
+		trigger = fmt.Sprintf("p99 %vus", e.P99Above)
+	default:
+		return fmt.Sprintf("%v", e.RelativeTime)
+	}
+	return fmt.Sprintf("%v after %v", e.RelativeTime, trigger)
+}
+
+// validateTrigger checks the progress and metric conditions of the event.
+func (e *Event) validateTrigger() error {
+	if e.AfterOps < 0 || e.AfterPercent < 0 || e.ErrorRate < 0 || e.P99Above < 0 || e.Intervals < 0 {
+		return errors.New("afterops, afterpercent, errorrate, p99above and intervals must not be negative")
+	}
+	if e.AfterPercent > 100 {
+		return errors.New("afterpercent must not be above 100")
+	}
+	if e.ErrorRate > 1 {
+		return errors.New("errorrate is a fraction between 0 and 1")
+	}
+
+	triggers := 0
+	for _, set := range []bool{e.AfterOps > 0, e.AfterPercent > 0, e.metricTriggered()} {
+		if set {
+			triggers++
+		}
+	}
+	if triggers > 1 {
+		return errors.New("afterops, afterpercent and the metric conditions exclude each other")
+	}
+	if (e.Intervals > 0 || e.Operation != "") && !e.metricTriggered() {
+		return errors.New("intervals and operation need errorrate or p99above")
+	}
+	return nil
+}
+
+// metricTrigger follows the metric conditions of an event over the intervals.
+type metricTrigger struct {
+	event *Event
+	fired chan struct{}
+	// held counts the consecutive intervals the condition held
+	held int
+	done bool
+}
+
+// holds returns whether the metric condition of the event holds for the
+// interval: the error rate or the 99th percentile latency of any operation
+// is above its limit.
+func (t *metricTrigger) holds(stats map[string]measurement.IntervalStats) bool {
+	var total, failed int64
+	for op, s := range stats {
+		if strings.HasPrefix(op, measurement.IntendedPrefix) || clientOps[op] {
+			continue
+		}
+		base := strings.TrimSuffix(op, errorSuffix)
+		if t.event.Operation != "" && !strings.EqualFold(base, t.event.Operation) {
+			continue
+		}
+
+		total += s.Count
+		if base != op {
+			failed += s.Count
+		} else if t.event.P99Above > 0 && s.Count > 0 && s.P99 > t.event.P99Above {
+			return true
+		}
+	}
+	return t.event.ErrorRate > 0 && total > 0 && float64(failed)/float64(total) > t.event.ErrorRate
+}
+
+// setTriggers prepares the triggers of the events. The percentages are
+// taken of the operation count of the run.
+func (en *eventEngine) setTriggers(opCount int64) error {
+	en.triggers = make([]<-chan struct{}, len(en.events))
+	for i := range en.events {
+		e := &en.events[i]
+		switch {
+		case e.AfterOps > 0:
+			en.triggers[i] = measurement.OperationsDone(e.AfterOps)
+		case e.AfterPercent > 0:
+			if opCount <= 0 {
+				return fmt.Errorf("event at %vs: afterpercent needs an operationcount", e.RelativeTime)
+			}
+			count := int64(math.Ceil(float64(opCount) * e.AfterPercent / 100))
+			en.triggers[i] = measurement.OperationsDone(count)
+		case e.metricTriggered():
+			if !measurement.IntervalStatsAvailable() {
+				return fmt.Errorf("event at %vs: errorrate and p99above need the histograms, add histogram to measurement.type", e.RelativeTime)
+			}
+			t := &metricTrigger{event: e, fired: make(chan struct{})}
+			en.metricTriggers = append(en.metricTriggers, t)
+			en.triggers[i] = t.fired
+		}
+	}
+
+	if len(en.metricTriggers) > 0 {
+		en.removeListener = measurement.AddIntervalListener(en.observeInterval)
+	}
+	return nil
+}
+
+// observeInterval checks the metric conditions at the end of an interval.
+func (en *eventEngine) observeInterval(stats map[string]measurement.IntervalStats) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+
+	for _, t := range en.metricTriggers {
+		if t.done {
+			continue
+		}
+		if t.holds(stats) {
+			t.held++
+		} else {
+			t.held = 0
+		}
+
+		intervals := t.event.Intervals
+		if intervals == 0 {
+			intervals = 1
+		}
+		if t.held >= intervals {
+			t.done = true
+			close(t.fired)
+		}
+	}
+}
+
+// waitTrigger waits for the trigger of the event, and returns the time it
+// fired relative to the engine clock, or false if the engine is stopped first.
+func (en *eventEngine) waitTrigger(index int) (time.Duration, bool) {
+	trigger := en.triggers[index]
+	if trigger == nil {
+		return 0, true
+	}
+	select {
+	case <-en.ctx.Done():
+		return 0, false
+	case <-trigger:
+	}
+
+	at := time.Since(en.startTime)
+	if at < 0 {
+		at = 0
+	}
+	fmt.Printf("%v Triggered event %v after %v operations\n", time.Now(), index+1, measurement.Operations())
+	return at, true
+}
@@ -0,0 +1,190 @@
+package workload
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestEventValidateTrigger(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		event Event
+		err   string
+	}{
+		{"after ops", Event{AfterOps: 100}, ""},
+		{"metric conditions", Event{ErrorRate: 0.1, P99Above: 1000, Intervals: 2, Operation: "READ"}, ""},
+		{"negative", Event{AfterOps: -1}, "negative"},
+		{"percent above 100", Event{AfterPercent: 101}, "above 100"},
+		{"error rate above 1", Event{ErrorRate: 2}, "fraction"},
+		{"two triggers", Event{AfterOps: 10, P99Above: 1000}, "exclude"},
+		{"intervals without metric", Event{AfterOps: 10, Intervals: 2}, "need errorrate"},
+	} {
+		err := tt.event.validateTrigger()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: want an error with %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestMetricTriggerHolds(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		event Event
+		stats map[string]measurement.IntervalStats
+		want  bool
+	}{
+		{"error rate above", Event{ErrorRate: 0.2},
+			map[string]measurement.IntervalStats{"READ": {Count: 70}, "READ_ERROR": {Count: 30}}, true},
+		{"error rate below", Event{ErrorRate: 0.5},
+			map[string]measurement.IntervalStats{"READ": {Count: 70}, "READ_ERROR": {Count: 30}}, false},
+		{"error rate of the operation", Event{ErrorRate: 0.2, Operation: "update"},
+			map[string]measurement.IntervalStats{"READ_ERROR": {Count: 30}, "UPDATE": {Count: 10}}, false},
+		{"no operation", Event{ErrorRate: 0.2}, map[string]measurement.IntervalStats{}, false},
+		{"p99 above", Event{P99Above: 1000},
+			map[string]measurement.IntervalStats{"READ": {Count: 1, P99: 2000}}, true},
+		{"p99 of errors", Event{P99Above: 1000},
+			map[string]measurement.IntervalStats{"READ_ERROR": {Count: 1, P99: 2000}}, false},
+		{"p99 of an idle operation", Event{P99Above: 1000},
+			map[string]measurement.IntervalStats{"READ": {P99: 2000}}, false},
+		{"p99 of another operation", Event{P99Above: 1000, Operation: "READ"},
+			map[string]measurement.IntervalStats{"READ": {Count: 1, P99: 500}, "UPDATE": {Count: 1, P99: 2000}}, false},
+		{"intended and client latencies", Event{P99Above: 1000},
+			map[string]measurement.IntervalStats{
+				measurement.IntendedPrefix + "READ": {Count: 1, P99: 2000},
+				measurement.OpQueue:                 {Count: 1, P99: 2000},
+			}, false},
+	} {
+		tr := &metricTrigger{event: &tt.event}
+		if got := tr.holds(tt.stats); got != tt.want {
+			t.Errorf("%v: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// initHistograms starts the measurement of a workload collecting the histograms
+func initHistograms() {
+	p := properties.NewProperties()
+	p.Set(prop.MeasurementType, "histogram")
+	measurement.InitMeasure(p)
+}
+
+func TestObserveIntervalHeldIntervals(t *testing.T) {
+	initHistograms()
+	events := EventList{{P99Above: 1000, Intervals: 2}, {ErrorRate: 0.5}}
+	en := newEventEngine(context.Background(), events)
+	if err := en.setTriggers(0); err != nil {
+		t.Fatal(err)
+	}
+	defer en.stop()
+
+	slow := map[string]measurement.IntervalStats{"READ": {Count: 1, P99: 2000}}
+	fast := map[string]measurement.IntervalStats{"READ": {Count: 1, P99: 500}}
+	for i, stats := range []map[string]measurement.IntervalStats{slow, fast, slow, slow, slow} {
+		en.observeInterval(stats)
+		fired := false
+		select {
+		case <-en.triggers[0]:
+			fired = true
+		default:
+		}
+		// the condition holds for the second time in a row at the 4th interval
+		if want := i >= 3; fired != want {
+			t.Fatalf("interval %v: want fired %v, got %v", i, want, fired)
+		}
+	}
+	select {
+	case <-en.triggers[1]:
+		t.Error("want the error rate trigger not fired")
+	default:
+	}
+}
+
+func TestSetTriggers(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set(prop.MeasurementType, "raw")
+	p.Set(prop.CSVFileName, filepath.Join(t.TempDir(), "raw"))
+	measurement.RawInitMeasure(p)
+	defer measurement.RawClose()
+
+	en := newEventEngine(context.Background(), EventList{{AfterPercent: 10}})
+	if err := en.setTriggers(0); err == nil || !strings.Contains(err.Error(), "operationcount") {
+		t.Errorf("want afterpercent refused without an operation count, got %v", err)
+	}
+	en = newEventEngine(context.Background(), EventList{{P99Above: 1000}})
+	if err := en.setTriggers(100); err == nil || !strings.Contains(err.Error(), "histogram") {
+		t.Errorf("want the metric conditions refused without the histograms, got %v", err)
+	}
+
+	// the listener of the metric triggers is removed when the engine stops
+	initHistograms()
+	en = newEventEngine(context.Background(), EventList{{P99Above: 1000}})
+	if err := en.setTriggers(100); err != nil {
+		t.Fatal(err)
+	}
+	en.stop()
+	start := time.Now()
+	measurement.Measure("READ", start, start.Add(10*time.Millisecond), "", nil)
+	measurement.IntervalOutput()
+	if en.metricTriggers[0].held != 0 {
+		t.Error("want the stopped engine not observing the intervals")
+	}
+}
+
+func TestWaitTrigger(t *testing.T) {
+	initHistograms()
+	events := EventList{{}, {AfterOps: 10}, {AfterPercent: 50}}
+	en := newEventEngine(context.Background(), events)
+	if err := en.setTriggers(40); err != nil {
+		t.Fatal(err)
+	}
+	en.startTime = time.Now()
+
+	if at, ok := en.waitTrigger(0); !ok || at != 0 {
+		t.Errorf("want an event without trigger fired right away, got %v %v", at, ok)
+	}
+
+	waited := make(chan bool, 2)
+	for _, index := range []int{1, 2} {
+		go func(index int) {
+			_, ok := en.waitTrigger(index)
+			waited <- ok
+		}(index)
+	}
+	measurement.AddOperations(9)
+	select {
+	case <-waited:
+		t.Fatal("want no trigger fired after 9 operations")
+	case <-time.After(20 * time.Millisecond):
+	}
+	measurement.AddOperations(1)
+	select {
+	case ok := <-waited:
+		if !ok {
+			t.Error("want the trigger of 10 operations fired")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the trigger of 10 operations fired")
+	}
+
+	// 50% of 40 operations is not reached before the engine stops
+	en.stop()
+	select {
+	case ok := <-waited:
+		if ok {
+			t.Error("want the stopped engine reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the wait ended by the stop")
+	}
+}
@@ -13,7 +13,10 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
 )
 
 // History client operation history mapped by key
@@ -115,8 +118,11 @@ func (h *History) WriteFile(path string) error {
 	return w.Flush()
 }
 
-// ReadFile reads csv log file and create operations in history
-func (h *History) ReadFile(path string) error {
+// ReadFile reads csv log file and create operations in history. The offset
+// is added to the timestamps to bring them to the clock of the history, and
+// the operations are widened by the uncertainty of the offset on both ends,
+// all in nanoseconds.
+func (h *History) ReadFile(path string, offset, uncertainty int64) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
@@ -143,14 +149,18 @@ func (h *History) ReadFile(path string) error {
 
 		operation := new(operation)
 
+		// intended latencies duplicate the operations of throttled runs
+		if strings.HasPrefix(record[0], measurement.IntendedPrefix) {
+			continue
+		}
+
 		switch record[0] {
 		case "Operation":
 			continue
-		case "READ":
+		case "READ", "BATCH_READ":
 			operation.input = nil
 			operation.output = record[4]
-		case "UPDATE":
-		case "INSERT":
+		case "UPDATE", "INSERT", "BATCH_UPDATE", "BATCH_INSERT":
 			operation.input = record[4]
 			operation.output = nil
 		default:
@@ -164,7 +174,7 @@ func (h *History) ReadFile(path string) error {
 			log.Fatal(err)
 			return err
 		}
-		operation.start = start
+		operation.start = start + offset - uncertainty
 
 		// get end time
 		end, err := strconv.ParseInt(record[2], 10, 64)
@@ -172,7 +182,7 @@ func (h *History) ReadFile(path string) error {
 			log.Fatal(err)
 			return err
 		}
-		operation.end = end
+		operation.end = end + offset + uncertainty
 
 		h.AddOperation(id, operation)
 	}
@@ -0,0 +1,90 @@
+package ycsbchecker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeHistory(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "raw.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFileAlignsTimestamps(t *testing.T) {
+	path := writeHistory(t, `Operation,Start,End,Key,Value(s),Thread,RunID
+UPDATE,1000,2000,k1,v1,0,test
+READ,3000,4000,k1,v1,0,test
+Intended-READ,2500,4000,k1,v1,0,test
+READ,5000,6000,k2,,0,test
+`)
+	for _, c := range []struct {
+		offset, uncertainty int64
+	}{
+		{0, 0},
+		{1000000, 0},
+		{-500, 100},
+		{2000000000, 250000},
+	} {
+		h := NewHistory()
+		if err := h.ReadFile(path, c.offset, c.uncertainty); err != nil {
+			t.Fatal(err)
+		}
+		if len(h.operations) != 3 || len(h.shard["k1"]) != 2 || len(h.shard["k2"]) != 1 {
+			t.Fatalf("offset %v: want 3 operations on k1 and k2 without the intended latency, got %v", c.offset, h.operations)
+		}
+		// the operations are widened to [start+offset-u, end+offset+u]
+		for i, want := range []operation{
+			{input: "v1", start: 1000 + c.offset - c.uncertainty, end: 2000 + c.offset + c.uncertainty},
+			{output: "v1", start: 3000 + c.offset - c.uncertainty, end: 4000 + c.offset + c.uncertainty},
+			{output: "", start: 5000 + c.offset - c.uncertainty, end: 6000 + c.offset + c.uncertainty},
+		} {
+			if got := h.operations[i]; !got.equal(want) {
+				t.Errorf("offset %v ± %v: want %v, got %v", c.offset, c.uncertainty, want, got)
+			}
+		}
+	}
+}
+
+func TestReadFileUncertaintyMakesConcurrent(t *testing.T) {
+	// the write of a run and the read of another run whose clock is 1000ns
+	// behind: aligned, the read starts 500ns after the write ended
+	write := writeHistory(t, "UPDATE,1000,2000,k1,v1,0,test\n")
+	read := writeHistory(t, "READ,1500,3000,k1,v0,0,test\n")
+
+	for _, c := range []struct {
+		uncertainty int64
+		concurrent  bool
+	}{
+		{0, false},
+		{250, false},
+		{500, true},
+		{1000, true},
+	} {
+		h := NewHistory()
+		if err := h.ReadFile(write, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.ReadFile(read, 1000, c.uncertainty); err != nil {
+			t.Fatal(err)
+		}
+		w, r := h.operations[0], h.operations[1]
+		if got := w.concurrent(*r); got != c.concurrent {
+			t.Errorf("uncertainty %v: want concurrent %v, got %v (%v and %v)", c.uncertainty, c.concurrent, got, w, r)
+		}
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	h := NewHistory()
+	if err := h.ReadFile(filepath.Join(t.TempDir(), "missing.csv"), 0, 0); err == nil {
+		t.Error("want an error for a missing file")
+	}
+	if err := h.ReadFile(writeHistory(t, "READ,1,2\n"), 0, 0); err == nil {
+		t.Error("want an error for a record without key and value")
+	}
+}
@@ -6,15 +6,17 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
 )
 
-// RunChecker calls the designated checker
-func RunChecker(checkType, prefix string) error {
+// RunChecker calls the designated checker on the history of the raw files,
+// its report being named after prefix
+func RunChecker(checkType, prefix string, files []measurement.RawFile) error {
 	var err error
 	switch checkType {
 	case "linearizable":
-		err = runLinearizable(prefix)
+		err = runLinearizable(prefix, files)
 	default:
 		return nil
 	}
@@ -22,28 +24,16 @@ func RunChecker(checkType, prefix string) error {
 }
 
 // runLinearizable runs the ailidani-paxi Linearizable checker
-func runLinearizable(prefix string) error {
+func runLinearizable(prefix string, files []measurement.RawFile) error {
 	history := NewHistory()
 
-	f, err := os.Open(".")
-	if err != nil {
-		return err
-	}
-
-	fileList, err := f.Readdir(0)
-	if err != nil {
-		return err
-	}
-
+	var err error
 	fileErrors := 0
-	for _, currentFile := range fileList {
-		fname := currentFile.Name()
-		if strings.Contains(fname, prefix) {
-			err = history.ReadFile(fname)
-			if err != nil {
-				fileErrors += 1
-				log.Printf("[LINEARIZABLE] Error reading file %v {%v}", fname, err.Error())
-			}
+	for _, file := range files {
+		err = history.ReadFile(file.Name, int64(file.Offset), int64(file.Uncertainty))
+		if err != nil {
+			fileErrors += 1
+			log.Printf("[LINEARIZABLE] Error reading file %v {%v}", file.Name, err.Error())
 		}
 	}
 
@@ -51,14 +41,13 @@ func runLinearizable(prefix string) error {
 		err = errors.New(fmt.Sprintf("[ERROR] Linearizable check returned errors for %v files\n", fileErrors))
 	} else {
 		anomalies := history.Linearizable()
+		fmt.Printf("Linearizable check of %v files returned %v anomalies\n", len(files), anomalies)
 		nf, err2 := os.Create(prefix + "_Linearizable_Checker.txt")
-		if err2 != nil {
+		if err2 == nil {
 			defer nf.Close()
 			nw := bufio.NewWriter(nf)
 			fmt.Fprintf(nw, "Linearizable check returned %v anomalies\n", anomalies)
 			nw.Flush()
-		} else {
-			fmt.Printf("Linearizable check returned %v anomalies\n", anomalies)
 		}
 		err = err2
 	}